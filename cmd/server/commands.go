@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/yourdatasucks/lettersmith/internal/config"
+	"github.com/yourdatasucks/lettersmith/internal/reps"
+	"github.com/yourdatasucks/lettersmith/internal/secrets"
+)
+
+const commandUsage = `Usage: lettersmith [command]
+
+Without a command, lettersmith starts the server.
+
+Commands:
+  config print            Print the effective configuration with secrets redacted
+  districts import FILE...
+                          Import Census ZCTA to congressional or state legislative
+                          district relationship files
+  representatives backfill
+                          Refetch stored OpenStates legislators to correct their
+                          state and level
+  secrets keygen          Print a new master key for SECRETS_MASTER_KEY
+  secrets encrypt NAME    Encrypt the secret read from stdin for the variable NAME
+`
+
+// runCommand runs a command-line tool instead of the server and returns the
+// exit code.
+func runCommand(args []string) int {
+	switch {
+	case len(args) == 2 && args[0] == "config" && args[1] == "print":
+		return printConfig(os.Stdout)
+
+	case len(args) >= 3 && args[0] == "districts" && args[1] == "import":
+		return importDistricts(args[2:])
+
+	case len(args) == 2 && args[0] == "representatives" && args[1] == "backfill":
+		return backfillRepresentatives()
+
+	case len(args) == 2 && args[0] == "secrets" && args[1] == "keygen":
+		key, err := secrets.GenerateKey()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to generate key: %v\n", err)
+			return 1
+		}
+		fmt.Println(key)
+		return 0
+
+	case len(args) == 3 && args[0] == "secrets" && args[1] == "encrypt":
+		return encryptSecret(args[2], os.Stdin)
+
+	case len(args) == 1 && (args[0] == "help" || args[0] == "-h" || args[0] == "--help"):
+		fmt.Print(commandUsage)
+		return 0
+
+	default:
+		fmt.Fprint(os.Stderr, commandUsage)
+		return 2
+	}
+}
+
+// importDistricts loads ZCTA relationship files into the database, each
+// replacing the districts of its kind. The server must have run once so that
+// the tables exist.
+func importDistricts(paths []string) int {
+	_, db, ok := loadCommandConfig()
+	if !ok {
+		return 1
+	}
+	defer db.Close()
+
+	service := reps.NewService(db)
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open %s: %v\n", path, err)
+			return 1
+		}
+		kind, count, err := service.ImportDistrictRelationships(file)
+		file.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to import %s: %v\n", path, err)
+			return 1
+		}
+		fmt.Printf("Imported %d ZCTA to %s district relationships from %s\n", count, kind, path)
+	}
+	return 0
+}
+
+// backfillRepresentatives refetches the OpenStates legislators synced before
+// their state and level were taken from their jurisdiction. Rows whose
+// jurisdiction was already stored are corrected by a migration.
+func backfillRepresentatives() int {
+	cfg, db, ok := loadCommandConfig()
+	if !ok {
+		return 1
+	}
+	defer db.Close()
+
+	if cfg.Representatives.OpenStatesAPIKey == "" {
+		fmt.Fprintln(os.Stderr, "OPENSTATES_API_KEY is not configured")
+		return 1
+	}
+
+	count, err := reps.NewService(db).BackfillFromOpenStates(context.Background(), newOpenStatesClient(cfg, db))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to backfill representatives: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Refreshed %d representatives from OpenStates\n", count)
+	return 0
+}
+
+// loadCommandConfig connects to the database and returns the configuration
+// the server would use: the environment, the env file and config file, and
+// the settings saved from the UI. Errors are printed; the caller closes the
+// database.
+func loadCommandConfig() (*config.Config, *sql.DB, bool) {
+	box, err := secrets.LoadBox(os.Getenv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load master key: %v\n", err)
+		return nil, nil, false
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return nil, nil, false
+	}
+
+	db, err := sql.Open("postgres", cfg.DatabaseURL())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to database: %v\n", err)
+		return nil, nil, false
+	}
+
+	manager := config.NewManager(config.NewSettingsStore(db, box), config.EnvFile(), config.ConfigFile())
+	if err := manager.Reload(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: saved settings are not included: %v\n", err)
+	}
+	return manager.Current(), db, true
+}
+
+// encryptSecret prints NAME=<encrypted value> for pasting into an env file.
+// The value is read from stdin so it stays out of the shell history.
+func encryptSecret(name string, in io.Reader) int {
+	if !config.IsSecret(name) {
+		fmt.Fprintf(os.Stderr, "%s is not a secret setting\n", name)
+		return 2
+	}
+
+	box, err := secrets.LoadBox(os.Getenv)
+	if err == nil && box == nil {
+		err = secrets.ErrNoKey
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load master key: %v\n", err)
+		return 1
+	}
+
+	data, err := io.ReadAll(in)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read secret: %v\n", err)
+		return 1
+	}
+	value := strings.TrimRight(string(data), "\r\n")
+	if value == "" {
+		fmt.Fprintln(os.Stderr, "No secret given on stdin")
+		return 2
+	}
+
+	encrypted, err := box.Encrypt(name, value)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to encrypt: %v\n", err)
+		return 1
+	}
+	fmt.Printf("%s=%s\n", name, encrypted)
+	return 0
+}
+
+// printConfig writes the configuration the server would run with: the config
+// file, environment, env file and, when the database is reachable, the saved
+// settings. Problems are reported on stderr.
+func printConfig(out io.Writer) int {
+	box, err := secrets.LoadBox(os.Getenv)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load master key: %v\n", err)
+		return 1
+	}
+
+	// Validation errors are reported below, along with the configuration.
+	cfg, err := config.Load()
+	var invalid *config.ValidationError
+	if err != nil && !errors.As(err, &invalid) {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return 1
+	}
+
+	db, err := sql.Open("postgres", cfg.DatabaseURL())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to database: %v\n", err)
+		return 1
+	}
+	defer db.Close()
+
+	manager := config.NewManager(config.NewSettingsStore(db, box), config.EnvFile(), config.ConfigFile())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: database unavailable, saved settings are not included: %v\n", err)
+	} else if err := manager.Reload(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: saved settings are not included: %v\n", err)
+	}
+
+	if err := manager.WriteYAML(out); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to print configuration: %v\n", err)
+		return 1
+	}
+
+	issues := manager.Current().Validate()
+	for _, issue := range issues {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", issue.Severity, issue)
+	}
+	if config.HasErrors(issues) {
+		return 1
+	}
+	return 0
+}
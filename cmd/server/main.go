@@ -2,33 +2,79 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/yourdatasucks/lettersmith/internal/ai"
+	"github.com/yourdatasucks/lettersmith/internal/auth"
+	"github.com/yourdatasucks/lettersmith/internal/campaigns"
 	"github.com/yourdatasucks/lettersmith/internal/config"
 	"github.com/yourdatasucks/lettersmith/internal/email"
 	"github.com/yourdatasucks/lettersmith/internal/geocoding"
+	"github.com/yourdatasucks/lettersmith/internal/letters"
+	"github.com/yourdatasucks/lettersmith/internal/lint"
+	"github.com/yourdatasucks/lettersmith/internal/openstates"
+	"github.com/yourdatasucks/lettersmith/internal/prompts"
 	"github.com/yourdatasucks/lettersmith/internal/reps"
+	"github.com/yourdatasucks/lettersmith/internal/secrets"
+	"github.com/yourdatasucks/lettersmith/internal/targeting"
+	"github.com/yourdatasucks/lettersmith/internal/users"
 
 	_ "github.com/lib/pq"
 )
 
 var geocoderInstance *geocoding.ZipGeocoder
 
+// configManager holds the configuration in effect. Handlers are given its
+// current value on every request so reloads apply without a restart.
+var configManager *config.Manager
+
+// clients are built from the configuration and rebuilt on every reload.
+var clients atomic.Pointer[serviceClients]
+
+// bootstrapToken authorizes creating the first administrator. It is empty once
+// an administrator exists.
+var bootstrapToken string
+
+var loginLimiter = auth.NewLoginLimiter(5, 15*time.Minute)
+
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
+	// Everything logged goes through the redactor, which masks the secrets
+	// of the configuration in effect.
+	redactor := secrets.NewRedactor(os.Stderr)
+	log.SetOutput(redactor)
+
 	cfg, err := config.Load()
-	if err != nil {
+	var invalid *config.ValidationError
+	if errors.As(err, &invalid) {
+		logConfigIssues(invalid.Issues)
+		log.Fatal("Refusing to start with an invalid configuration")
+	} else if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
+	redactor.Set(cfg.SecretValues())
+
+	box, err := secrets.LoadBox(os.Getenv)
+	if err != nil {
+		log.Fatalf("Failed to load master key: %v", err)
+	}
 
 	dbURL := cfg.DatabaseURL()
 	db, err := sql.Open("postgres", dbURL)
@@ -51,6 +97,35 @@ func main() {
 		log.Println("Database migrations completed successfully")
 	}
 
+	settingsStore := config.NewSettingsStore(db, box)
+	if box == nil {
+		log.Printf("Warning: %s is not set; API keys and passwords can only be set in the environment", secrets.MasterKeyEnv)
+	} else if n, err := settingsStore.EncryptStored(); err != nil {
+		log.Printf("Warning: failed to encrypt saved secrets: %v", err)
+	} else if n > 0 {
+		log.Printf("Encrypted %d saved secrets", n)
+	}
+
+	configManager = config.NewManager(settingsStore, config.EnvFile(), config.ConfigFile())
+	configManager.Subscribe("redaction", func(cfg *config.Config) {
+		redactor.Set(cfg.SecretValues())
+	})
+	configManager.Subscribe("clients", rebuildClients)
+	if err := configManager.Reload(); err != nil {
+		log.Printf("Warning: %v", err)
+		redactor.Set(configManager.Current().SecretValues())
+		rebuildClients(configManager.Current())
+	}
+	cfg = configManager.Current()
+	issues := cfg.Validate()
+	logConfigIssues(issues)
+	if config.HasErrors(issues) {
+		log.Fatal("Refusing to start with an invalid configuration")
+	}
+	go configManager.Watch(context.Background(), 10*time.Second, func(err error) {
+		log.Printf("Warning: configuration %v", err)
+	})
+
 	geocodingConfig := &geocoding.GeocodingConfig{
 		CustomCensusBureauURL: cfg.CensusBureauURL,
 	}
@@ -65,6 +140,27 @@ func main() {
 
 	geocoderInstance = geocoder
 
+	if n, err := campaigns.NewService(db).ResetInterrupted(); err != nil {
+		log.Printf("Warning: %v", err)
+	} else if n > 0 {
+		log.Printf("Marked %d campaigns interrupted by a restart as completed with errors", n)
+	}
+	go runCampaignScheduler(db)
+	go runRepresentativeSync(db)
+
+	authService := auth.NewService(db, time.Duration(cfg.Auth.SessionTTLHours)*time.Hour)
+	if required, err := authService.BootstrapRequired(); err != nil {
+		log.Printf("Warning: %v", err)
+	} else if required {
+		bootstrapToken = cfg.Auth.BootstrapToken
+		if bootstrapToken == "" {
+			if bootstrapToken, err = auth.RandomToken(); err != nil {
+				log.Fatalf("Failed to generate bootstrap token: %v", err)
+			}
+		}
+		log.Printf("No administrator account exists yet. Open %s and create one with bootstrap token: %s", auth.LoginPage, bootstrapToken)
+	}
+
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/api/health", func(w http.ResponseWriter, r *http.Request) {
@@ -78,24 +174,36 @@ func main() {
 	mux.HandleFunc("/api/config", func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
-			handleGetConfig(w, r, cfg)
+			handleGetConfig(w, r, configManager.Current())
 		case http.MethodPost:
-			handleUpdateConfig(w, r, cfg)
+			handleUpdateConfig(w, r, configManager.Current())
 		default:
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
 	})
 
+	mux.HandleFunc("/api/settings", func(w http.ResponseWriter, r *http.Request) {
+		handleSettings(w, r)
+	})
+
+	mux.HandleFunc("/api/settings/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handleResetSetting(w, r)
+	})
+
 	mux.HandleFunc("/api/config/test-email", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
-		handleTestEmail(w, r, cfg)
+		handleTestEmail(w, r, configManager.Current())
 	})
 
 	mux.HandleFunc("/api/config/debug", func(w http.ResponseWriter, r *http.Request) {
-		handleConfigDebug(w, r, cfg)
+		handleConfigDebug(w, r, configManager.Current())
 	})
 
 	mux.HandleFunc("/api/db/debug", func(w http.ResponseWriter, r *http.Request) {
@@ -107,7 +215,7 @@ func main() {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
-		handleSystemStatus(w, r, cfg, db)
+		handleSystemStatus(w, r, configManager.Current(), db)
 	})
 
 	mux.HandleFunc("/api/representatives", func(w http.ResponseWriter, r *http.Request) {
@@ -115,14 +223,46 @@ func main() {
 		case http.MethodGet:
 			handleGetRepresentatives(w, r, db)
 		case http.MethodPost:
-			handleSyncRepresentatives(w, r, db)
+			handleCreateRepresentative(w, r, db)
 		default:
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
 	})
 
+	mux.HandleFunc("/api/representatives/sync", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handleSyncRepresentatives(w, r, configManager.Current(), db)
+	})
+
+	mux.HandleFunc("/api/representatives/export", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handleExportRepresentatives(w, r, db)
+	})
+
+	mux.HandleFunc("/api/representatives/import", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handleImportRepresentatives(w, r, db)
+	})
+
+	mux.HandleFunc("/api/representatives/changes", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handleRepresentativeChanges(w, r, db)
+	})
+
 	mux.HandleFunc("/api/representatives/", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodPut || r.Method == http.MethodDelete {
+		if r.Method == http.MethodGet || r.Method == http.MethodPut || r.Method == http.MethodDelete {
 			handleRepresentativeByID(w, r, db)
 		} else {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -130,7 +270,7 @@ func main() {
 	})
 
 	mux.HandleFunc("/api/test/representatives", func(w http.ResponseWriter, r *http.Request) {
-		handleTestRepresentatives(w, r, db)
+		handleTestRepresentatives(w, r, configManager.Current(), db)
 	})
 
 	mux.HandleFunc("/api/letters/generate", func(w http.ResponseWriter, r *http.Request) {
@@ -138,141 +278,201 @@ func main() {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
-		handleGenerateLetter(w, r, cfg, db)
+		handleGenerateLetter(w, r, configManager.Current(), db)
 	})
 
-	// Serve static files from the web directory
-	mux.Handle("/css/", http.StripPrefix("/css/", http.FileServer(http.Dir("web/css"))))
-	mux.Handle("/js/", http.StripPrefix("/js/", http.FileServer(http.Dir("web/js"))))
-	mux.Handle("/html/", http.StripPrefix("/html/", http.FileServer(http.Dir("web/html"))))
+	mux.HandleFunc("/api/letters/send", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handleSendLetter(w, r, db)
+	})
 
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == "/" {
-			http.ServeFile(w, r, "web/html/index.html")
+	mux.HandleFunc("/api/letters/history", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
-		http.ServeFile(w, r, "web/html"+r.URL.Path)
+		handleLetterHistory(w, r, db)
 	})
 
-	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
-	log.Printf("Starting Lettersmith server on %s...", addr)
-	log.Printf("Configuration loaded from environment variables")
-	log.Printf("AI Provider: %s, Email Provider: %s", cfg.AI.Provider, cfg.Email.Provider)
+	mux.HandleFunc("/api/auth/status", func(w http.ResponseWriter, r *http.Request) {
+		handleAuthStatus(w, r, authService)
+	})
 
-	if err := http.ListenAndServe(addr, mux); err != nil {
-		log.Fatal(err)
-	}
-}
+	mux.HandleFunc("/api/auth/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handleLogin(w, r, configManager.Current(), authService)
+	})
 
-func handleGetConfig(w http.ResponseWriter, _ *http.Request, cfg *config.Config) {
+	mux.HandleFunc("/api/auth/logout", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handleLogout(w, r, configManager.Current(), authService)
+	})
 
-	envValues := readEnvFile()
+	mux.HandleFunc("/api/auth/bootstrap", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handleBootstrap(w, r, configManager.Current(), authService)
+	})
 
-	freshCfg := &config.Config{}
+	mux.HandleFunc("/api/auth/password", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handleChangePassword(w, r, authService)
+	})
 
-	if method := envValues["LETTER_GENERATION_METHOD"]; method != "" {
-		freshCfg.Letter.GenerationMethod = method
-	} else {
-		freshCfg.Letter.GenerationMethod = "ai"
-	}
+	mux.HandleFunc("/api/auth/tokens", func(w http.ResponseWriter, r *http.Request) {
+		handleAPITokens(w, r, authService)
+	})
 
-	if tone := envValues["LETTER_TONE"]; tone != "" {
-		freshCfg.Letter.Tone = tone
-	} else {
-		freshCfg.Letter.Tone = "professional"
-	}
+	mux.HandleFunc("/api/auth/tokens/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		handleRevokeAPIToken(w, r, authService)
+	})
 
-	if maxLen := envValues["LETTER_MAX_LENGTH"]; maxLen != "" {
-		if length, err := strconv.Atoi(maxLen); err == nil {
-			freshCfg.Letter.MaxLength = length
-		} else {
-			freshCfg.Letter.MaxLength = 500
+	mux.HandleFunc("/api/users", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handleListUsers(w, r, db)
+		case http.MethodPost:
+			handleCreateUser(w, r, configManager.Current(), db, authService)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
-	} else {
-		freshCfg.Letter.MaxLength = 500
-	}
+	})
 
-	if freshCfg.Letter.GenerationMethod == "templates" {
-		freshCfg.Letter.TemplateConfig = &config.TemplateConfig{
-			Directory:        envValues["TEMPLATE_DIRECTORY"],
-			RotationStrategy: envValues["TEMPLATE_ROTATION_STRATEGY"],
-			Personalize:      envValues["TEMPLATE_PERSONALIZE"] == "true",
+	mux.HandleFunc("/api/users/", func(w http.ResponseWriter, r *http.Request) {
+		handleUserByID(w, r, configManager.Current(), db)
+	})
+
+	mux.HandleFunc("/api/campaigns", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handleListCampaigns(w, r, db)
+		case http.MethodPost:
+			handleCreateCampaign(w, r, configManager.Current(), db)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
-		if freshCfg.Letter.TemplateConfig.Directory == "" {
-			freshCfg.Letter.TemplateConfig.Directory = "templates/"
+	})
+
+	mux.HandleFunc("/api/campaigns/", func(w http.ResponseWriter, r *http.Request) {
+		handleCampaignAction(w, r, configManager.Current(), db)
+	})
+
+	mux.HandleFunc("/api/targeting/rules", func(w http.ResponseWriter, r *http.Request) {
+		handleTargetingRules(w, r, configManager.Current(), db)
+	})
+
+	mux.HandleFunc("/api/targeting/preview", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
 		}
-		if freshCfg.Letter.TemplateConfig.RotationStrategy == "" {
-			freshCfg.Letter.TemplateConfig.RotationStrategy = "random-unique"
+		handleTargetingPreview(w, r, configManager.Current(), db)
+	})
+
+	mux.HandleFunc("/api/prompts", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handleListPrompts(w, r, configManager.Current(), db)
+		case http.MethodPost:
+			handleCreatePrompt(w, r, configManager.Current(), db)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
-	}
+	})
 
-	if provider := envValues["AI_PROVIDER"]; provider != "" {
-		freshCfg.AI.Provider = provider
-		if provider == "openai" {
-			freshCfg.AI.OpenAI.APIKey = envValues["OPENAI_API_KEY"]
-			freshCfg.AI.OpenAI.Model = envValues["OPENAI_MODEL"]
-			if freshCfg.AI.OpenAI.Model == "" {
-				freshCfg.AI.OpenAI.Model = "gpt-4"
-			}
-		} else if provider == "anthropic" {
-			freshCfg.AI.Anthropic.APIKey = envValues["ANTHROPIC_API_KEY"]
-			freshCfg.AI.Anthropic.Model = envValues["ANTHROPIC_MODEL"]
-			if freshCfg.AI.Anthropic.Model == "" {
-				freshCfg.AI.Anthropic.Model = "claude-3-sonnet-20240229"
-			}
+	mux.HandleFunc("/api/prompts/", func(w http.ResponseWriter, r *http.Request) {
+		handlePromptAction(w, r, configManager.Current(), db)
+	})
+
+	// Serve static files from the web directory
+	mux.Handle("/css/", http.StripPrefix("/css/", http.FileServer(http.Dir("web/css"))))
+	mux.Handle("/js/", http.StripPrefix("/js/", http.FileServer(http.Dir("web/js"))))
+	mux.Handle("/html/", http.StripPrefix("/html/", http.FileServer(http.Dir("web/html"))))
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			http.ServeFile(w, r, "web/html/index.html")
+			return
 		}
-	}
+		http.ServeFile(w, r, "web/html"+r.URL.Path)
+	})
 
-	currentCfg := freshCfg
+	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
+	log.Printf("Starting Lettersmith server on %s...", addr)
+	log.Printf("Configuration loaded from environment variables and saved settings")
+	log.Printf("AI Provider: %s, Email Provider: %s", cfg.AI.Provider, cfg.Email.Provider)
 
-	if currentCfg.User.Name == "" {
-		currentCfg.User = cfg.User
-	}
-	if currentCfg.Email.Provider == "" {
-		currentCfg.Email = cfg.Email
-	}
-	if currentCfg.Representatives.OpenStatesAPIKey == "" {
-		currentCfg.Representatives = cfg.Representatives
+	handler := authService.Middleware(auth.RequireRole(mux, requiredRole), isPublicPath)
+	if err := http.ListenAndServe(addr, handler); err != nil {
+		log.Fatal(err)
 	}
-	if currentCfg.Scheduler.SendTime == "" {
-		currentCfg.Scheduler = cfg.Scheduler
+}
+
+func handleGetConfig(w http.ResponseWriter, _ *http.Request, cfg *config.Config) {
+	envValues := configManager.Values()
+	for _, key := range []string{"POSTGRES_USER", "POSTGRES_PASSWORD", "POSTGRES_DB", "POSTGRES_PORT", "DATABASE_URL"} {
+		envValues[key] = os.Getenv(key)
 	}
 
 	safeConfig := map[string]interface{}{
-		"server": currentCfg.Server,
+		"server": cfg.Server,
 		"ai": map[string]interface{}{
-			"provider": currentCfg.AI.Provider,
+			"provider": cfg.AI.Provider,
 			"openai": map[string]interface{}{
-				"model":      currentCfg.AI.OpenAI.Model,
-				"configured": currentCfg.AI.OpenAI.APIKey != "",
+				"model":      cfg.AI.OpenAI.Model,
+				"configured": cfg.AI.OpenAI.APIKey != "",
 			},
 			"anthropic": map[string]interface{}{
-				"model":      currentCfg.AI.Anthropic.Model,
-				"configured": currentCfg.AI.Anthropic.APIKey != "",
+				"model":      cfg.AI.Anthropic.Model,
+				"configured": cfg.AI.Anthropic.APIKey != "",
 			},
 		},
 		"email": map[string]interface{}{
-			"provider": currentCfg.Email.Provider,
+			"provider": cfg.Email.Provider,
 			"smtp": map[string]interface{}{
-				"host":       currentCfg.Email.SMTP.Host,
-				"port":       currentCfg.Email.SMTP.Port,
-				"username":   currentCfg.Email.SMTP.Username,
-				"configured": currentCfg.Email.SMTP.Password != "",
+				"host":       cfg.Email.SMTP.Host,
+				"port":       cfg.Email.SMTP.Port,
+				"username":   cfg.Email.SMTP.Username,
+				"configured": cfg.Email.SMTP.Password != "",
 			},
 			"sendgrid": map[string]interface{}{
-				"configured": currentCfg.Email.SendGrid.APIKey != "",
+				"configured": cfg.Email.SendGrid.APIKey != "",
 			},
 			"mailgun": map[string]interface{}{
-				"domain":     currentCfg.Email.Mailgun.Domain,
-				"configured": currentCfg.Email.Mailgun.APIKey != "",
+				"domain":     cfg.Email.Mailgun.Domain,
+				"configured": cfg.Email.Mailgun.APIKey != "",
 			},
 		},
 		"representatives": map[string]interface{}{
-			"openstates_configured": currentCfg.Representatives.OpenStatesAPIKey != "",
+			"openstates_configured":      cfg.Representatives.OpenStatesAPIKey != "",
+			"openstates_base_url":        cfg.Representatives.OpenStatesBaseURL,
+			"openstates_cache_ttl_hours": cfg.Representatives.OpenStatesCacheTTLHours,
+			"selection_mode":             cfg.Representatives.SelectionMode,
+			"congress_legislators_file":  cfg.Representatives.CongressLegislatorsFile,
+			"sources_file":               cfg.Representatives.SourcesFile,
+			"sync_interval_hours":        cfg.Representatives.SyncIntervalHours,
 		},
-		"user":       currentCfg.User,
-		"scheduler":  currentCfg.Scheduler,
-		"letter":     currentCfg.Letter,
+		"user":       cfg.User,
+		"scheduler":  cfg.Scheduler,
+		"letter":     cfg.Letter,
 		"env_values": sanitizeEnvValues(envValues),
 	}
 
@@ -292,576 +492,410 @@ func handleUpdateConfig(w http.ResponseWriter, r *http.Request, _ *config.Config
 		return
 	}
 
-	var dbConn *sql.DB
-	if cfg, err := config.Load(); err == nil {
-		dbURL := cfg.DatabaseURL()
-		if db, err := sql.Open("postgres", dbURL); err == nil {
-			dbConn = db
-			defer db.Close()
-		}
-	}
-
-	if err := updateEnvFile(updates, dbConn); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+	values, err := settingsFromUpdates(updates)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{
-			"error": fmt.Sprintf("Failed to update configuration: %s", err.Error()),
+			"error": err.Error(),
 		})
 		return
 	}
 
+	if err := configManager.Save(values); err != nil {
+		writeSettingsError(w, http.StatusBadRequest, fmt.Sprintf("Failed to update configuration: %s", err.Error()), err)
+		return
+	}
+
 	json.NewEncoder(w).Encode(map[string]string{
 		"status": "Configuration updated successfully",
 	})
 }
 
-func handleConfigDebug(w http.ResponseWriter, _ *http.Request, cfg *config.Config) {
-	w.Header().Set("Content-Type", "application/json")
+// settingsFromUpdates maps the configuration form onto runtime settings.
+// Secrets left blank keep their current value.
+func settingsFromUpdates(updates map[string]interface{}) (map[string]string, error) {
+	values := make(map[string]string)
 
-	freshCfg, err := config.Load()
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": fmt.Sprintf("Failed to load configuration: %v", err),
-		})
-		return
+	setString := func(section map[string]interface{}, field, key string, keepBlank bool) {
+		if value, ok := section[field].(string); ok && (value != "" || !keepBlank) {
+			values[key] = strings.TrimSpace(value)
+		}
 	}
-
-	envValues := readEnvFile()
-
-	debugInfo := map[string]interface{}{
-		"environment_variables": map[string]string{
-			"POSTGRES_USER":              getEnvFileStatus(envValues, "POSTGRES_USER"),
-			"POSTGRES_PASSWORD":          getEnvFileStatus(envValues, "POSTGRES_PASSWORD"),
-			"POSTGRES_DB":                getEnvFileStatus(envValues, "POSTGRES_DB"),
-			"POSTGRES_PORT":              getEnvFileStatus(envValues, "POSTGRES_PORT"),
-			"DATABASE_URL":               getEnvFileStatus(envValues, "DATABASE_URL"),
-			"ZIP_DATA_UPDATE":            getEnvFileStatus(envValues, "ZIP_DATA_UPDATE"),
-			"PORT":                       getEnvFileStatus(envValues, "PORT"),
-			"AI_PROVIDER":                getEnvFileStatus(envValues, "AI_PROVIDER"),
-			"OPENAI_API_KEY":             getEnvFileStatus(envValues, "OPENAI_API_KEY"),
-			"ANTHROPIC_API_KEY":          getEnvFileStatus(envValues, "ANTHROPIC_API_KEY"),
-			"EMAIL_PROVIDER":             getEnvFileStatus(envValues, "EMAIL_PROVIDER"),
-			"SMTP_HOST":                  getEnvFileStatus(envValues, "SMTP_HOST"),
-			"SMTP_PORT":                  getEnvFileStatus(envValues, "SMTP_PORT"),
-			"SMTP_USERNAME":              getEnvFileStatus(envValues, "SMTP_USERNAME"),
-			"SMTP_PASSWORD":              getEnvFileStatus(envValues, "SMTP_PASSWORD"),
-			"SMTP_FROM":                  getEnvFileStatus(envValues, "SMTP_FROM"),
-			"SENDGRID_API_KEY":           getEnvFileStatus(envValues, "SENDGRID_API_KEY"),
-			"MAILGUN_API_KEY":            getEnvFileStatus(envValues, "MAILGUN_API_KEY"),
-			"MAILGUN_DOMAIN":             getEnvFileStatus(envValues, "MAILGUN_DOMAIN"),
-			"OPENSTATES_API_KEY":         getEnvFileStatus(envValues, "OPENSTATES_API_KEY"),
-			"USER_NAME":                  getEnvFileStatus(envValues, "USER_NAME"),
-			"USER_EMAIL":                 getEnvFileStatus(envValues, "USER_EMAIL"),
-			"USER_ZIP_CODE":              getEnvFileStatus(envValues, "USER_ZIP_CODE"),
-			"SEND_COPY_TO_SELF":          getEnvFileStatus(envValues, "SEND_COPY_TO_SELF"),
-			"SCHEDULER_SEND_TIME":        getEnvFileStatus(envValues, "SCHEDULER_SEND_TIME"),
-			"SCHEDULER_TIMEZONE":         getEnvFileStatus(envValues, "SCHEDULER_TIMEZONE"),
-			"SCHEDULER_ENABLED":          getEnvFileStatus(envValues, "SCHEDULER_ENABLED"),
-			"LETTER_TONE":                getEnvFileStatus(envValues, "LETTER_TONE"),
-			"LETTER_MAX_LENGTH":          getEnvFileStatus(envValues, "LETTER_MAX_LENGTH"),
-			"LETTER_GENERATION_METHOD":   getEnvFileStatus(envValues, "LETTER_GENERATION_METHOD"),
-			"LETTER_THEMES":              getEnvFileStatus(envValues, "LETTER_THEMES"),
-			"TEMPLATE_DIRECTORY":         getEnvFileStatus(envValues, "TEMPLATE_DIRECTORY"),
-			"TEMPLATE_ROTATION_STRATEGY": getEnvFileStatus(envValues, "TEMPLATE_ROTATION_STRATEGY"),
-			"TEMPLATE_PERSONALIZE":       getEnvFileStatus(envValues, "TEMPLATE_PERSONALIZE"),
-			"DOCKER_IMAGE":               getEnvFileStatus(envValues, "DOCKER_IMAGE"),
-			"CENSUS_BUREAU_URL":          getEnvFileStatus(envValues, "CENSUS_BUREAU_URL"),
-		},
-		"configuration_status": map[string]interface{}{
-			"user_configured":            freshCfg.User.Name != "" && freshCfg.User.Email != "" && freshCfg.User.ZipCode != "",
-			"generation_method":          freshCfg.Letter.GenerationMethod,
-			"ai_configured":              freshCfg.Letter.GenerationMethod == "ai" && freshCfg.AI.Provider != "" && isAIConfigured(freshCfg),
-			"templates_configured":       freshCfg.Letter.GenerationMethod == "templates" && isTemplatesConfigured(freshCfg),
-			"email_configured":           freshCfg.Email.Provider != "" && isEmailConfigured(freshCfg),
-			"representatives_configured": isRepresentativesConfigured(freshCfg),
-			"validation_result":          getValidationResult(freshCfg),
-		},
-		"database_url_parsed": freshCfg.DatabaseURL(),
+	setNumber := func(section map[string]interface{}, field, key string) {
+		if value, ok := section[field].(float64); ok && value > 0 {
+			values[key] = strconv.Itoa(int(value))
+		}
+	}
+	setBool := func(section map[string]interface{}, field, key string) {
+		if value, ok := section[field].(bool); ok {
+			values[key] = strconv.FormatBool(value)
+		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(debugInfo)
-}
+	if database, ok := updates["database"].(map[string]interface{}); ok {
+		for _, field := range []string{"user", "password", "db", "port"} {
+			if _, ok := database[field]; ok {
+				return nil, fmt.Errorf("database connection settings come from the environment (POSTGRES_*, DATABASE_URL) and cannot be changed here")
+			}
+		}
+		setBool(database, "zip_data_update", "ZIP_DATA_UPDATE")
+	}
 
-func isAIConfigured(cfg *config.Config) bool {
+	if user, ok := updates["user"].(map[string]interface{}); ok {
+		setString(user, "name", "USER_NAME", true)
+		setString(user, "email", "USER_EMAIL", true)
+		setString(user, "zip_code", "USER_ZIP_CODE", true)
+		setBool(user, "send_copy_to_self", "SEND_COPY_TO_SELF")
+	}
 
-	if cfg.Letter.GenerationMethod != "ai" {
-		return false
+	if ai, ok := updates["ai"].(map[string]interface{}); ok {
+		setString(ai, "provider", "AI_PROVIDER", true)
+		if openai, ok := ai["openai"].(map[string]interface{}); ok {
+			setString(openai, "model", "OPENAI_MODEL", true)
+			setString(openai, "api_key", "OPENAI_API_KEY", true)
+		}
+		if anthropic, ok := ai["anthropic"].(map[string]interface{}); ok {
+			setString(anthropic, "model", "ANTHROPIC_MODEL", true)
+			setString(anthropic, "api_key", "ANTHROPIC_API_KEY", true)
+		}
 	}
 
-	switch cfg.AI.Provider {
-	case "openai":
-		return cfg.AI.OpenAI.APIKey != ""
-	case "anthropic":
-		return cfg.AI.Anthropic.APIKey != ""
-	default:
-		return false
+	if email, ok := updates["email"].(map[string]interface{}); ok {
+		setString(email, "provider", "EMAIL_PROVIDER", true)
+		if smtp, ok := email["smtp"].(map[string]interface{}); ok {
+			setString(smtp, "host", "SMTP_HOST", false)
+			setNumber(smtp, "port", "SMTP_PORT")
+			setString(smtp, "username", "SMTP_USERNAME", false)
+			setString(smtp, "password", "SMTP_PASSWORD", true)
+			setString(smtp, "from", "SMTP_FROM", false)
+		}
+		if sendgrid, ok := email["sendgrid"].(map[string]interface{}); ok {
+			setString(sendgrid, "api_key", "SENDGRID_API_KEY", true)
+			setString(sendgrid, "from", "SENDGRID_FROM", true)
+		}
+		if mailgun, ok := email["mailgun"].(map[string]interface{}); ok {
+			setString(mailgun, "api_key", "MAILGUN_API_KEY", true)
+			setString(mailgun, "domain", "MAILGUN_DOMAIN", true)
+			setString(mailgun, "from", "MAILGUN_FROM", true)
+		}
 	}
-}
 
-func isEmailConfigured(cfg *config.Config) bool {
-	switch cfg.Email.Provider {
-	case "smtp":
-		return cfg.Email.SMTP.Host != "" && cfg.Email.SMTP.Port != 0 && cfg.Email.SMTP.Password != ""
-	case "sendgrid":
-		return cfg.Email.SendGrid.APIKey != ""
-	case "mailgun":
-		return cfg.Email.Mailgun.APIKey != "" && cfg.Email.Mailgun.Domain != ""
-	default:
-		return false
+	if reps, ok := updates["representatives"].(map[string]interface{}); ok {
+		setString(reps, "openstates_api_key", "OPENSTATES_API_KEY", true)
+		setString(reps, "openstates_base_url", "OPENSTATES_BASE_URL", true)
+		setNumber(reps, "openstates_cache_ttl_hours", "OPENSTATES_CACHE_TTL_HOURS")
+		setString(reps, "selection_mode", "REP_SELECTION_MODE", true)
+		setString(reps, "congress_legislators_file", "CONGRESS_LEGISLATORS_FILE", true)
+		setString(reps, "sources_file", "REP_SOURCES_FILE", true)
+		setNumber(reps, "sync_interval_hours", "REP_SYNC_INTERVAL_HOURS")
 	}
-}
 
-func isTemplatesConfigured(cfg *config.Config) bool {
-	if cfg.Letter.GenerationMethod != "templates" {
-		return false
+	if scheduler, ok := updates["scheduler"].(map[string]interface{}); ok {
+		setString(scheduler, "send_time", "SCHEDULER_SEND_TIME", true)
+		setString(scheduler, "timezone", "SCHEDULER_TIMEZONE", true)
+		setBool(scheduler, "enabled", "SCHEDULER_ENABLED")
 	}
 
-	if cfg.Letter.TemplateConfig == nil || cfg.Letter.TemplateConfig.Directory == "" {
-		return false
+	if letter, ok := updates["letter"].(map[string]interface{}); ok {
+		setString(letter, "tone", "LETTER_TONE", true)
+		setNumber(letter, "max_length", "LETTER_MAX_LENGTH")
+		setString(letter, "generation_method", "LETTER_GENERATION_METHOD", true)
+
+		if themes, ok := letter["themes"].([]interface{}); ok && len(themes) > 0 {
+			var themeStrings []string
+			for _, theme := range themes {
+				if themeStr, ok := theme.(string); ok && themeStr != "" {
+					themeStrings = append(themeStrings, strings.TrimSpace(themeStr))
+				}
+			}
+			if len(themeStrings) > 0 {
+				values["LETTER_THEMES"] = strings.Join(themeStrings, ",")
+			}
+		}
+
+		if templateConfig, ok := letter["template_config"].(map[string]interface{}); ok {
+			setString(templateConfig, "directory", "TEMPLATE_DIRECTORY", true)
+			setString(templateConfig, "rotation_strategy", "TEMPLATE_ROTATION_STRATEGY", true)
+			setBool(templateConfig, "personalize", "TEMPLATE_PERSONALIZE")
+		}
 	}
 
-	return true
+	return values, nil
 }
 
-func isRepresentativesConfigured(cfg *config.Config) bool {
-	return cfg.Representatives.OpenStatesAPIKey != ""
-}
-
-func getValidationResult(cfg *config.Config) string {
-
-	if cfg.User.Name == "" || cfg.User.Email == "" || cfg.User.ZipCode == "" {
-		return "missing required user information"
-	}
-	if cfg.AI.Provider == "" && cfg.Letter.GenerationMethod == "ai" {
-		return "AI provider required for AI generation"
-	}
-	if cfg.Email.Provider == "" {
-		return "email provider required"
+// logConfigIssues logs each configuration issue on its own line.
+func logConfigIssues(issues []config.Issue) {
+	for _, issue := range issues {
+		log.Printf("Configuration %s: %s", issue.Severity, issue)
 	}
-	return "valid"
 }
 
-func readEnvFile() map[string]string {
-	envValues := make(map[string]string)
-
-	data, err := os.ReadFile(".env")
-	if err != nil {
-		return envValues
-	}
-
-	lines := strings.Split(string(data), "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
+// serviceClients are the AI and email clients built from one configuration,
+// so a request never mixes clients from before and after a reload.
+type serviceClients struct {
+	config *config.Config
+	ai     ai.AIClient
+	// aiErr explains why ai is nil.
+	aiErr *letterError
+	email *email.Client
+}
 
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 {
-			key := strings.TrimSpace(parts[0])
-			value := strings.TrimSpace(parts[1])
+func currentClients() *serviceClients {
+	return clients.Load()
+}
 
-			if len(value) > 1 && ((value[0] == '"' && value[len(value)-1] == '"') || (value[0] == '\'' && value[len(value)-1] == '\'')) {
-				value = value[1 : len(value)-1]
-			}
-			envValues[key] = value
-		}
-	}
+// rebuildClients replaces the service clients after a configuration reload.
+func rebuildClients(cfg *config.Config) {
+	c := &serviceClients{config: cfg, email: email.NewClient(&cfg.Email)}
+	c.ai, c.aiErr = newAIClient(cfg)
+	clients.Store(c)
 
-	return envValues
+	log.Printf("Configuration loaded: AI provider %q, email provider %q", cfg.AI.Provider, cfg.Email.Provider)
 }
 
-func updateEnvFile(updates map[string]interface{}, dbConn *sql.DB) error {
-	cwd, _ := os.Getwd()
-	log.Printf("Current working directory: %s", cwd)
-
-	if info, err := os.Stat(".env"); err == nil {
-		log.Printf(".env file exists with permissions: %v", info.Mode())
-	} else {
-		log.Printf(".env file does not exist yet: %v", err)
+func newAIClient(cfg *config.Config) (ai.AIClient, *letterError) {
+	provider := cfg.AI.Provider
+	if provider == "" {
+		return nil, newLetterError(http.StatusBadRequest, "AI provider not configured")
 	}
 
-	testFile := ".env_test_write"
-	if err := os.WriteFile(testFile, []byte("test"), 0644); err != nil {
-		log.Printf("ERROR: Cannot write to current directory: %v", err)
-		return fmt.Errorf("cannot write to current directory: %w", err)
-	} else {
-		os.Remove(testFile)
-		log.Printf("Current directory is writable")
+	var apiKey, model string
+	options := ai.ClientOptions{ProxyURL: cfg.AI.ProxyURL}
+	if cfg.AI.Timeout > 0 {
+		options.Timeout = time.Duration(cfg.AI.Timeout) * time.Second
 	}
-
-	existingEnv := readEnvFile()
-	log.Printf("Read %d existing environment variables from .env", len(existingEnv))
-
-	if database, ok := updates["database"].(map[string]interface{}); ok {
-		oldPassword := existingEnv["POSTGRES_PASSWORD"]
-		oldUser := existingEnv["POSTGRES_USER"]
-		if oldUser == "" {
-			oldUser = "lettersmith"
-		}
-
-		newUser := oldUser
-		var newPassword string
-
-		if user, ok := database["user"].(string); ok && user != "" {
-			newUser = strings.TrimSpace(user)
-			existingEnv["POSTGRES_USER"] = newUser
-		}
-		if password, ok := database["password"].(string); ok && password != "" {
-			newPassword = strings.TrimSpace(password)
-			existingEnv["POSTGRES_PASSWORD"] = newPassword
-
-			if oldPassword != "" && oldPassword != newPassword && dbConn != nil {
-				log.Printf("Updating database password for user: %s", newUser)
-				if err := updateDatabaseUserPassword(dbConn, newUser, newPassword); err != nil {
-					log.Printf("Warning: Failed to update database user password: %v", err)
-					return fmt.Errorf("failed to update database password: %w", err)
-				} else {
-					log.Printf("Successfully updated database user password")
-				}
-			}
-		}
-		if dbName, ok := database["db"].(string); ok && dbName != "" {
-			existingEnv["POSTGRES_DB"] = strings.TrimSpace(dbName)
-		}
-		if port, ok := database["port"].(float64); ok && port > 0 {
-			existingEnv["POSTGRES_PORT"] = strconv.Itoa(int(port))
-		}
-		if zipDataUpdate, ok := database["zip_data_update"].(bool); ok {
-			existingEnv["ZIP_DATA_UPDATE"] = strconv.FormatBool(zipDataUpdate)
-		}
-
-		user := existingEnv["POSTGRES_USER"]
-		if user == "" {
-			user = "lettersmith"
-		}
-		password := existingEnv["POSTGRES_PASSWORD"]
-		if password == "" {
-			password = "lettersmith_pass"
-		}
-		dbName := existingEnv["POSTGRES_DB"]
-		if dbName == "" {
-			dbName = "lettersmith"
-		}
-		port := existingEnv["POSTGRES_PORT"]
-		if port == "" {
-			port = "5432"
-		}
-
-		databaseURL := fmt.Sprintf("postgres://%s:%s@localhost:%s/%s?sslmode=disable", user, password, port, dbName)
-		existingEnv["DATABASE_URL"] = databaseURL
+	switch provider {
+	case "openai":
+		apiKey, model, options.BaseURL = cfg.AI.OpenAI.APIKey, cfg.AI.OpenAI.Model, cfg.AI.OpenAI.BaseURL
+	case "anthropic":
+		apiKey, model, options.BaseURL = cfg.AI.Anthropic.APIKey, cfg.AI.Anthropic.Model, cfg.AI.Anthropic.BaseURL
 	}
-
-	if user, ok := updates["user"].(map[string]interface{}); ok {
-		if name, ok := user["name"].(string); ok && name != "" {
-			existingEnv["USER_NAME"] = strings.TrimSpace(name)
-		}
-		if email, ok := user["email"].(string); ok && email != "" {
-			existingEnv["USER_EMAIL"] = strings.TrimSpace(email)
-		}
-		if zip, ok := user["zip_code"].(string); ok && zip != "" {
-			existingEnv["USER_ZIP_CODE"] = strings.TrimSpace(zip)
-		}
-		if sendCopy, ok := user["send_copy_to_self"].(bool); ok {
-			existingEnv["SEND_COPY_TO_SELF"] = strconv.FormatBool(sendCopy)
-		}
+	if apiKey == "" {
+		return nil, newLetterError(http.StatusBadRequest, "%s API key not configured", provider)
 	}
 
-	if ai, ok := updates["ai"].(map[string]interface{}); ok {
-		if provider, ok := ai["provider"].(string); ok && provider != "" {
-			existingEnv["AI_PROVIDER"] = strings.TrimSpace(provider)
-		}
-		if openai, ok := ai["openai"].(map[string]interface{}); ok {
-			if model, ok := openai["model"].(string); ok && model != "" {
-				existingEnv["OPENAI_MODEL"] = strings.TrimSpace(model)
-			}
-			if apiKey, ok := openai["api_key"].(string); ok && apiKey != "" {
-				existingEnv["OPENAI_API_KEY"] = strings.TrimSpace(apiKey)
-			}
-		}
-		if anthropic, ok := ai["anthropic"].(map[string]interface{}); ok {
-			if model, ok := anthropic["model"].(string); ok && model != "" {
-				existingEnv["ANTHROPIC_MODEL"] = strings.TrimSpace(model)
-			}
-			if apiKey, ok := anthropic["api_key"].(string); ok && apiKey != "" {
-				existingEnv["ANTHROPIC_API_KEY"] = strings.TrimSpace(apiKey)
-			}
-		}
+	client, err := ai.NewClientWithOptions(provider, apiKey, model, options)
+	if err != nil {
+		return nil, newLetterError(http.StatusInternalServerError, "Failed to create AI client: %v", err)
 	}
+	return client, nil
+}
 
-	if email, ok := updates["email"].(map[string]interface{}); ok {
-
-		if provider, ok := email["provider"].(string); ok && provider != "" {
-			currentProvider := existingEnv["EMAIL_PROVIDER"]
-			if currentProvider != "" && currentProvider != provider {
-
-				switch currentProvider {
-				case "smtp":
-					delete(existingEnv, "SMTP_HOST")
-					delete(existingEnv, "SMTP_PORT")
-					delete(existingEnv, "SMTP_USERNAME")
-					delete(existingEnv, "SMTP_PASSWORD")
-					delete(existingEnv, "SMTP_FROM")
-				case "sendgrid":
-					delete(existingEnv, "SENDGRID_API_KEY")
-					delete(existingEnv, "SENDGRID_FROM")
-				case "mailgun":
-					delete(existingEnv, "MAILGUN_API_KEY")
-					delete(existingEnv, "MAILGUN_DOMAIN")
-					delete(existingEnv, "MAILGUN_FROM")
-				}
-			}
-			existingEnv["EMAIL_PROVIDER"] = strings.TrimSpace(provider)
-		}
+// handleSettings lists every runtime setting with its effective value and
+// source (GET) or saves a set of them (PUT). Secrets are never returned.
+func handleSettings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
 
-		if smtp, ok := email["smtp"].(map[string]interface{}); ok {
-			if host, ok := smtp["host"].(string); ok {
-				existingEnv["SMTP_HOST"] = strings.TrimSpace(host)
-			}
-			if port, ok := smtp["port"].(float64); ok && port > 0 {
-				existingEnv["SMTP_PORT"] = strconv.Itoa(int(port))
-			}
-			if username, ok := smtp["username"].(string); ok {
-				existingEnv["SMTP_USERNAME"] = strings.TrimSpace(username)
-			}
-			if password, ok := smtp["password"].(string); ok && password != "" {
-				existingEnv["SMTP_PASSWORD"] = strings.TrimSpace(password)
-			}
-			if from, ok := smtp["from"].(string); ok {
-				existingEnv["SMTP_FROM"] = strings.TrimSpace(from)
-			}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var values map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&values); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid JSON format",
+			})
+			return
 		}
-		if sendgrid, ok := email["sendgrid"].(map[string]interface{}); ok {
-			if apiKey, ok := sendgrid["api_key"].(string); ok && apiKey != "" {
-				existingEnv["SENDGRID_API_KEY"] = strings.TrimSpace(apiKey)
-			}
-			if from, ok := sendgrid["from"].(string); ok && from != "" {
-				existingEnv["SENDGRID_FROM"] = strings.TrimSpace(from)
+		// A masked secret sent back unchanged keeps its current value.
+		for key, value := range values {
+			if value == maskedSecret {
+				delete(values, key)
 			}
 		}
-		if mailgun, ok := email["mailgun"].(map[string]interface{}); ok {
-			if apiKey, ok := mailgun["api_key"].(string); ok && apiKey != "" {
-				existingEnv["MAILGUN_API_KEY"] = strings.TrimSpace(apiKey)
-			}
-			if domain, ok := mailgun["domain"].(string); ok && domain != "" {
-				existingEnv["MAILGUN_DOMAIN"] = strings.TrimSpace(domain)
-			}
-			if from, ok := mailgun["from"].(string); ok && from != "" {
-				existingEnv["MAILGUN_FROM"] = strings.TrimSpace(from)
-			}
+		if err := configManager.Save(values); err != nil {
+			writeSettingsError(w, http.StatusBadRequest, err.Error(), err)
+			return
 		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
-	if reps, ok := updates["representatives"].(map[string]interface{}); ok {
-		if apiKey, ok := reps["openstates_api_key"].(string); ok && apiKey != "" {
-			existingEnv["OPENSTATES_API_KEY"] = strings.TrimSpace(apiKey)
+	settings := append([]config.SettingValue(nil), configManager.Settings()...)
+	for i := range settings {
+		if settings[i].Secret && settings[i].Value != "" {
+			settings[i].Value = maskedSecret
 		}
 	}
 
-	if scheduler, ok := updates["scheduler"].(map[string]interface{}); ok {
-		if sendTime, ok := scheduler["send_time"].(string); ok && sendTime != "" {
-			existingEnv["SCHEDULER_SEND_TIME"] = strings.TrimSpace(sendTime)
-		}
-		if timezone, ok := scheduler["timezone"].(string); ok && timezone != "" {
-			existingEnv["SCHEDULER_TIMEZONE"] = strings.TrimSpace(timezone)
-		}
-		if enabled, ok := scheduler["enabled"].(bool); ok {
-			existingEnv["SCHEDULER_ENABLED"] = strconv.FormatBool(enabled)
-		}
-	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"settings": settings,
+	})
+}
 
-	if letter, ok := updates["letter"].(map[string]interface{}); ok {
-		if tone, ok := letter["tone"].(string); ok && tone != "" {
-			existingEnv["LETTER_TONE"] = strings.TrimSpace(tone)
-		}
-		if maxLength, ok := letter["max_length"].(float64); ok && maxLength > 0 {
-			existingEnv["LETTER_MAX_LENGTH"] = strconv.Itoa(int(maxLength))
-		}
-		if method, ok := letter["generation_method"].(string); ok && method != "" {
-			existingEnv["LETTER_GENERATION_METHOD"] = strings.TrimSpace(method)
+// handleResetSetting removes a saved setting so the environment or default
+// applies again.
+func handleResetSetting(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
 
-			if method == "templates" {
+	key := strings.TrimPrefix(r.URL.Path, "/api/settings/")
+	if _, ok := config.LookupSetting(key); !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("Unknown setting %s", key),
+		})
+		return
+	}
 
-				delete(existingEnv, "AI_PROVIDER")
-				delete(existingEnv, "OPENAI_API_KEY")
-				delete(existingEnv, "OPENAI_MODEL")
-				delete(existingEnv, "ANTHROPIC_API_KEY")
-				delete(existingEnv, "ANTHROPIC_MODEL")
-			} else if method == "ai" {
+	if err := configManager.Reset(key); err != nil {
+		writeSettingsError(w, http.StatusInternalServerError, err.Error(), err)
+		return
+	}
 
-				delete(existingEnv, "TEMPLATE_DIRECTORY")
-				delete(existingEnv, "TEMPLATE_ROTATION_STRATEGY")
-				delete(existingEnv, "TEMPLATE_PERSONALIZE")
-			}
-		}
-		if themes, ok := letter["themes"].([]interface{}); ok && len(themes) > 0 {
-			var themeStrings []string
-			for _, theme := range themes {
-				if themeStr, ok := theme.(string); ok && themeStr != "" {
-					themeStrings = append(themeStrings, strings.TrimSpace(themeStr))
-				}
-			}
-			if len(themeStrings) > 0 {
-				existingEnv["LETTER_THEMES"] = strings.Join(themeStrings, ",")
-			}
-		}
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": fmt.Sprintf("Setting %s reset", key),
+	})
+}
 
-		if templateConfig, ok := letter["template_config"].(map[string]interface{}); ok {
-			if directory, ok := templateConfig["directory"].(string); ok && directory != "" {
-				existingEnv["TEMPLATE_DIRECTORY"] = strings.TrimSpace(directory)
-			}
-			if strategy, ok := templateConfig["rotation_strategy"].(string); ok && strategy != "" {
-				existingEnv["TEMPLATE_ROTATION_STRATEGY"] = strings.TrimSpace(strategy)
-			}
-			if personalize, ok := templateConfig["personalize"].(bool); ok {
-				existingEnv["TEMPLATE_PERSONALIZE"] = strconv.FormatBool(personalize)
-			}
-		}
+// writeSettingsError reports a failed save. When the configuration would not
+// validate, the issues are listed and the status is 400.
+func writeSettingsError(w http.ResponseWriter, status int, message string, err error) {
+	body := map[string]interface{}{
+		"error": message,
+	}
+	var invalid *config.ValidationError
+	if errors.As(err, &invalid) {
+		status = http.StatusBadRequest
+		body["issues"] = invalid.Issues
 	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
 
-	var envContent strings.Builder
-	envContent.WriteString("# Lettersmith Configuration\n")
-	envContent.WriteString("# Generated by web UI - edit via http://localhost:8080\n\n")
+func handleConfigDebug(w http.ResponseWriter, _ *http.Request, cfg *config.Config) {
+	w.Header().Set("Content-Type", "application/json")
 
-	writeEnvSection(&envContent, "Database Configuration", map[string]string{
-		"POSTGRES_USER":     existingEnv["POSTGRES_USER"],
-		"POSTGRES_PASSWORD": existingEnv["POSTGRES_PASSWORD"],
-		"POSTGRES_DB":       existingEnv["POSTGRES_DB"],
-		"POSTGRES_PORT":     existingEnv["POSTGRES_PORT"],
-		"ZIP_DATA_UPDATE":   existingEnv["ZIP_DATA_UPDATE"],
-		"DATABASE_URL":      existingEnv["DATABASE_URL"],
-	})
+	envValues := configManager.Values()
+	for _, key := range []string{"POSTGRES_USER", "POSTGRES_PASSWORD", "POSTGRES_DB", "POSTGRES_PORT", "DATABASE_URL", "PORT", "DOCKER_IMAGE"} {
+		envValues[key] = os.Getenv(key)
+	}
 
-	writeEnvSection(&envContent, "Advanced Configuration", map[string]string{
-		"DOCKER_IMAGE":      existingEnv["DOCKER_IMAGE"],
-		"CENSUS_BUREAU_URL": existingEnv["CENSUS_BUREAU_URL"],
-	})
+	debugInfo := map[string]interface{}{
+		"environment_variables": map[string]string{
+			"POSTGRES_USER":              settingStatus(envValues, "POSTGRES_USER"),
+			"POSTGRES_PASSWORD":          settingStatus(envValues, "POSTGRES_PASSWORD"),
+			"POSTGRES_DB":                settingStatus(envValues, "POSTGRES_DB"),
+			"POSTGRES_PORT":              settingStatus(envValues, "POSTGRES_PORT"),
+			"DATABASE_URL":               settingStatus(envValues, "DATABASE_URL"),
+			"ZIP_DATA_UPDATE":            settingStatus(envValues, "ZIP_DATA_UPDATE"),
+			"PORT":                       settingStatus(envValues, "PORT"),
+			"AI_PROVIDER":                settingStatus(envValues, "AI_PROVIDER"),
+			"OPENAI_API_KEY":             settingStatus(envValues, "OPENAI_API_KEY"),
+			"ANTHROPIC_API_KEY":          settingStatus(envValues, "ANTHROPIC_API_KEY"),
+			"EMAIL_PROVIDER":             settingStatus(envValues, "EMAIL_PROVIDER"),
+			"SMTP_HOST":                  settingStatus(envValues, "SMTP_HOST"),
+			"SMTP_PORT":                  settingStatus(envValues, "SMTP_PORT"),
+			"SMTP_USERNAME":              settingStatus(envValues, "SMTP_USERNAME"),
+			"SMTP_PASSWORD":              settingStatus(envValues, "SMTP_PASSWORD"),
+			"SMTP_FROM":                  settingStatus(envValues, "SMTP_FROM"),
+			"SENDGRID_API_KEY":           settingStatus(envValues, "SENDGRID_API_KEY"),
+			"MAILGUN_API_KEY":            settingStatus(envValues, "MAILGUN_API_KEY"),
+			"MAILGUN_DOMAIN":             settingStatus(envValues, "MAILGUN_DOMAIN"),
+			"OPENSTATES_API_KEY":         settingStatus(envValues, "OPENSTATES_API_KEY"),
+			"REP_SELECTION_MODE":         settingStatus(envValues, "REP_SELECTION_MODE"),
+			"USER_NAME":                  settingStatus(envValues, "USER_NAME"),
+			"USER_EMAIL":                 settingStatus(envValues, "USER_EMAIL"),
+			"USER_ZIP_CODE":              settingStatus(envValues, "USER_ZIP_CODE"),
+			"SEND_COPY_TO_SELF":          settingStatus(envValues, "SEND_COPY_TO_SELF"),
+			"SCHEDULER_SEND_TIME":        settingStatus(envValues, "SCHEDULER_SEND_TIME"),
+			"SCHEDULER_TIMEZONE":         settingStatus(envValues, "SCHEDULER_TIMEZONE"),
+			"SCHEDULER_ENABLED":          settingStatus(envValues, "SCHEDULER_ENABLED"),
+			"LETTER_TONE":                settingStatus(envValues, "LETTER_TONE"),
+			"LETTER_MAX_LENGTH":          settingStatus(envValues, "LETTER_MAX_LENGTH"),
+			"LETTER_GENERATION_METHOD":   settingStatus(envValues, "LETTER_GENERATION_METHOD"),
+			"LETTER_THEMES":              settingStatus(envValues, "LETTER_THEMES"),
+			"TEMPLATE_DIRECTORY":         settingStatus(envValues, "TEMPLATE_DIRECTORY"),
+			"TEMPLATE_ROTATION_STRATEGY": settingStatus(envValues, "TEMPLATE_ROTATION_STRATEGY"),
+			"TEMPLATE_PERSONALIZE":       settingStatus(envValues, "TEMPLATE_PERSONALIZE"),
+			"DOCKER_IMAGE":               settingStatus(envValues, "DOCKER_IMAGE"),
+			"CENSUS_BUREAU_URL":          settingStatus(envValues, "CENSUS_BUREAU_URL"),
+		},
+		"configuration_status": map[string]interface{}{
+			"user_configured":            cfg.User.Name != "" && cfg.User.Email != "" && cfg.User.ZipCode != "",
+			"generation_method":          cfg.Letter.GenerationMethod,
+			"ai_configured":              cfg.Letter.GenerationMethod == "ai" && cfg.AI.Provider != "" && isAIConfigured(cfg),
+			"templates_configured":       cfg.Letter.GenerationMethod == "templates" && isTemplatesConfigured(cfg),
+			"email_configured":           cfg.Email.Provider != "" && isEmailConfigured(cfg),
+			"representatives_configured": isRepresentativesConfigured(cfg),
+			"validation_result":          getValidationResult(cfg),
+		},
+		"database_url_parsed": maskDatabaseURL(cfg.DatabaseURL()),
+	}
 
-	writeEnvSection(&envContent, "Server", map[string]string{
-		"PORT": existingEnv["PORT"],
-	})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(debugInfo)
+}
 
-	writeEnvSection(&envContent, "User Information", map[string]string{
-		"USER_NAME":         existingEnv["USER_NAME"],
-		"USER_EMAIL":        existingEnv["USER_EMAIL"],
-		"USER_ZIP_CODE":     existingEnv["USER_ZIP_CODE"],
-		"SEND_COPY_TO_SELF": existingEnv["SEND_COPY_TO_SELF"],
-	})
+func isAIConfigured(cfg *config.Config) bool {
 
-	generationMethod := existingEnv["LETTER_GENERATION_METHOD"]
-	if generationMethod == "ai" {
-		writeEnvSection(&envContent, "AI Provider", map[string]string{
-			"AI_PROVIDER":       existingEnv["AI_PROVIDER"],
-			"OPENAI_API_KEY":    existingEnv["OPENAI_API_KEY"],
-			"OPENAI_MODEL":      existingEnv["OPENAI_MODEL"],
-			"ANTHROPIC_API_KEY": existingEnv["ANTHROPIC_API_KEY"],
-			"ANTHROPIC_MODEL":   existingEnv["ANTHROPIC_MODEL"],
-		})
+	if cfg.Letter.GenerationMethod != "ai" {
+		return false
 	}
 
-	currentEmailProvider := existingEnv["EMAIL_PROVIDER"]
-	emailSettings := map[string]string{
-		"EMAIL_PROVIDER": existingEnv["EMAIL_PROVIDER"],
+	switch cfg.AI.Provider {
+	case "openai":
+		return cfg.AI.OpenAI.APIKey != ""
+	case "anthropic":
+		return cfg.AI.Anthropic.APIKey != ""
+	default:
+		return false
 	}
+}
 
-	switch currentEmailProvider {
+func isEmailConfigured(cfg *config.Config) bool {
+	switch cfg.Email.Provider {
 	case "smtp":
-		emailSettings["SMTP_HOST"] = existingEnv["SMTP_HOST"]
-		emailSettings["SMTP_PORT"] = existingEnv["SMTP_PORT"]
-		emailSettings["SMTP_USERNAME"] = existingEnv["SMTP_USERNAME"]
-		emailSettings["SMTP_PASSWORD"] = existingEnv["SMTP_PASSWORD"]
-		emailSettings["SMTP_FROM"] = existingEnv["SMTP_FROM"]
+		return cfg.Email.SMTP.Host != "" && cfg.Email.SMTP.Port != 0 && cfg.Email.SMTP.Password != ""
 	case "sendgrid":
-		emailSettings["SENDGRID_API_KEY"] = existingEnv["SENDGRID_API_KEY"]
-		emailSettings["SENDGRID_FROM"] = existingEnv["SENDGRID_FROM"]
+		return cfg.Email.SendGrid.APIKey != ""
 	case "mailgun":
-		emailSettings["MAILGUN_API_KEY"] = existingEnv["MAILGUN_API_KEY"]
-		emailSettings["MAILGUN_DOMAIN"] = existingEnv["MAILGUN_DOMAIN"]
-		emailSettings["MAILGUN_FROM"] = existingEnv["MAILGUN_FROM"]
+		return cfg.Email.Mailgun.APIKey != "" && cfg.Email.Mailgun.Domain != ""
+	default:
+		return false
 	}
+}
 
-	writeEnvSection(&envContent, "Email Provider", emailSettings)
-
-	writeEnvSection(&envContent, "Representative APIs", map[string]string{
-		"OPENSTATES_API_KEY": existingEnv["OPENSTATES_API_KEY"],
-	})
-
-	writeEnvSection(&envContent, "Scheduler", map[string]string{
-		"SCHEDULER_SEND_TIME": existingEnv["SCHEDULER_SEND_TIME"],
-		"SCHEDULER_TIMEZONE":  existingEnv["SCHEDULER_TIMEZONE"],
-		"SCHEDULER_ENABLED":   existingEnv["SCHEDULER_ENABLED"],
-	})
-
-	letterSettings := map[string]string{
-		"LETTER_GENERATION_METHOD": existingEnv["LETTER_GENERATION_METHOD"],
-		"LETTER_THEMES":            existingEnv["LETTER_THEMES"],
+func isTemplatesConfigured(cfg *config.Config) bool {
+	if cfg.Letter.GenerationMethod != "templates" {
+		return false
 	}
 
-	if generationMethod == "ai" {
-		letterSettings["LETTER_TONE"] = existingEnv["LETTER_TONE"]
-		letterSettings["LETTER_MAX_LENGTH"] = existingEnv["LETTER_MAX_LENGTH"]
-	} else if generationMethod == "templates" {
-		letterSettings["TEMPLATE_DIRECTORY"] = existingEnv["TEMPLATE_DIRECTORY"]
-		letterSettings["TEMPLATE_ROTATION_STRATEGY"] = existingEnv["TEMPLATE_ROTATION_STRATEGY"]
-		letterSettings["TEMPLATE_PERSONALIZE"] = existingEnv["TEMPLATE_PERSONALIZE"]
+	if cfg.Letter.TemplateConfig == nil || cfg.Letter.TemplateConfig.Directory == "" {
+		return false
 	}
 
-	writeEnvSection(&envContent, "Letter Settings", letterSettings)
-
-	content := envContent.String()
-	log.Printf("Generated .env content (%d bytes)", len(content))
+	return true
+}
 
-	envPath := ".env"
-	log.Printf("Attempting to write to: %s", envPath)
+func isRepresentativesConfigured(cfg *config.Config) bool {
+	return cfg.Representatives.OpenStatesAPIKey != "" || cfg.Representatives.CongressLegislatorsFile != "" ||
+		cfg.Representatives.SourcesFile != ""
+}
 
-	if err := os.WriteFile(envPath, []byte(content), 0644); err != nil {
-		log.Printf("ERROR writing .env file: %v", err)
-		log.Printf("Error type: %T", err)
+func getValidationResult(cfg *config.Config) string {
 
-		if os.IsPermission(err) {
-			return fmt.Errorf("permission denied writing .env file - check directory permissions: %w", err)
-		}
-		if os.IsExist(err) {
-			return fmt.Errorf(".env file exists and cannot be overwritten: %w", err)
-		}
-		return fmt.Errorf("failed to write .env file: %w", err)
+	if cfg.User.Name == "" || cfg.User.Email == "" || cfg.User.ZipCode == "" {
+		return "missing required user information"
 	}
-
-	log.Printf("Successfully wrote .env file")
-	return nil
-}
-
-func writeEnvSection(builder *strings.Builder, sectionName string, variables map[string]string) {
-	hasContent := false
-	for _, value := range variables {
-		if value != "" {
-			hasContent = true
-			break
-		}
+	if cfg.AI.Provider == "" && cfg.Letter.GenerationMethod == "ai" {
+		return "AI provider required for AI generation"
 	}
-
-	if hasContent {
-		builder.WriteString(fmt.Sprintf("# %s\n", sectionName))
-		for key, value := range variables {
-			if value != "" {
-
-				if strings.ContainsAny(value, " \t\n\"'\\") {
-					value = fmt.Sprintf(`"%s"`, strings.ReplaceAll(value, `"`, `\"`))
-				}
-				builder.WriteString(fmt.Sprintf("%s=%s\n", key, value))
-			}
-		}
-		builder.WriteString("\n")
+	if cfg.Email.Provider == "" {
+		return "email provider required"
 	}
+	return "valid"
 }
 
-func getEnvFileStatus(envValues map[string]string, key string) string {
+// maskedSecret stands in for a secret that is set.
+const maskedSecret = "••••••••"
+
+func settingStatus(envValues map[string]string, key string) string {
 	value := envValues[key]
 	if value == "" {
 		return "not set"
 	}
-	if strings.Contains(strings.ToLower(key), "key") || strings.Contains(strings.ToLower(key), "password") {
+	if isSecretKey(key) {
 		return "set (masked)"
 	}
 	return value
@@ -874,15 +908,9 @@ func sanitizeEnvValues(envValues map[string]string) map[string]string {
 		if value == "" {
 			sanitized[key] = ""
 		} else if key == "DATABASE_URL" {
-			urlPattern := `(postgres://[^:]+:)([^@]+)(@.+)`
-			re := regexp.MustCompile(urlPattern)
-			if re.MatchString(value) {
-				sanitized[key] = re.ReplaceAllString(value, "${1}••••••••${3}")
-			} else {
-				sanitized[key] = "••••••••"
-			}
-		} else if strings.Contains(strings.ToLower(key), "key") || strings.Contains(strings.ToLower(key), "password") {
-			sanitized[key] = "••••••••"
+			sanitized[key] = maskDatabaseURL(value)
+		} else if isSecretKey(key) {
+			sanitized[key] = maskedSecret
 		} else {
 			sanitized[key] = value
 		}
@@ -891,7 +919,24 @@ func sanitizeEnvValues(envValues map[string]string) map[string]string {
 	return sanitized
 }
 
-func handleTestEmail(w http.ResponseWriter, r *http.Request, _ *config.Config) {
+// isSecretKey reports whether a variable must never be shown. Besides the
+// known secrets, anything named like a key or password is masked.
+func isSecretKey(key string) bool {
+	lower := strings.ToLower(key)
+	return config.IsSecret(key) || strings.Contains(lower, "key") || strings.Contains(lower, "password")
+}
+
+var databaseURLPassword = regexp.MustCompile(`(postgres(?:ql)?://[^:/@]+:)([^@]+)(@.+)`)
+
+// maskDatabaseURL hides the password in a PostgreSQL connection URL.
+func maskDatabaseURL(url string) string {
+	if !databaseURLPassword.MatchString(url) {
+		return maskedSecret
+	}
+	return databaseURLPassword.ReplaceAllString(url, "${1}"+maskedSecret+"${3}")
+}
+
+func handleTestEmail(w http.ResponseWriter, r *http.Request, cfg *config.Config) {
 	w.Header().Set("Content-Type", "application/json")
 
 	var reqData map[string]interface{}
@@ -903,8 +948,6 @@ func handleTestEmail(w http.ResponseWriter, r *http.Request, _ *config.Config) {
 		return
 	}
 
-	envValues := readEnvFile()
-
 	emailConfig := &config.EmailConfig{}
 
 	if email, ok := reqData["email"].(map[string]interface{}); ok {
@@ -916,42 +959,32 @@ func handleTestEmail(w http.ResponseWriter, r *http.Request, _ *config.Config) {
 			if host, ok := smtp["host"].(string); ok {
 				emailConfig.SMTP.Host = strings.TrimSpace(host)
 			} else {
-
-				emailConfig.SMTP.Host = envValues["SMTP_HOST"]
+				emailConfig.SMTP.Host = cfg.Email.SMTP.Host
 			}
 
 			if port, ok := smtp["port"].(float64); ok {
 				emailConfig.SMTP.Port = int(port)
 			} else {
-
-				if portStr := envValues["SMTP_PORT"]; portStr != "" {
-					if p, err := strconv.Atoi(portStr); err == nil {
-						emailConfig.SMTP.Port = p
-					}
-				}
+				emailConfig.SMTP.Port = cfg.Email.SMTP.Port
 			}
 
 			if username, ok := smtp["username"].(string); ok {
 				emailConfig.SMTP.Username = strings.TrimSpace(username)
 			} else {
-
-				emailConfig.SMTP.Username = envValues["SMTP_USERNAME"]
+				emailConfig.SMTP.Username = cfg.Email.SMTP.Username
 			}
 
 			if password, ok := smtp["password"].(string); ok && password != "" {
 				emailConfig.SMTP.Password = strings.TrimSpace(password)
 			} else {
-
-				emailConfig.SMTP.Password = envValues["SMTP_PASSWORD"]
+				emailConfig.SMTP.Password = cfg.Email.SMTP.Password
 			}
 
 			if from, ok := smtp["from"].(string); ok {
 				emailConfig.SMTP.From = strings.TrimSpace(from)
 			} else {
-
-				if fromAddr := envValues["SMTP_FROM"]; fromAddr != "" {
-					emailConfig.SMTP.From = fromAddr
-				} else {
+				emailConfig.SMTP.From = cfg.Email.SMTP.From
+				if emailConfig.SMTP.From == "" {
 					emailConfig.SMTP.From = emailConfig.SMTP.Username
 				}
 			}
@@ -959,21 +992,9 @@ func handleTestEmail(w http.ResponseWriter, r *http.Request, _ *config.Config) {
 	}
 
 	if emailConfig.Provider == "" {
-		emailConfig.Provider = envValues["EMAIL_PROVIDER"]
+		emailConfig.Provider = cfg.Email.Provider
 		if emailConfig.Provider == "smtp" {
-			emailConfig.SMTP.Host = envValues["SMTP_HOST"]
-			emailConfig.SMTP.Username = envValues["SMTP_USERNAME"]
-			emailConfig.SMTP.Password = envValues["SMTP_PASSWORD"]
-			if fromAddr := envValues["SMTP_FROM"]; fromAddr != "" {
-				emailConfig.SMTP.From = fromAddr
-			} else {
-				emailConfig.SMTP.From = emailConfig.SMTP.Username
-			}
-			if portStr := envValues["SMTP_PORT"]; portStr != "" {
-				if p, err := strconv.Atoi(portStr); err == nil {
-					emailConfig.SMTP.Port = p
-				}
-			}
+			emailConfig.SMTP = cfg.Email.SMTP
 		}
 	}
 
@@ -1002,7 +1023,7 @@ func handleTestEmail(w http.ResponseWriter, r *http.Request, _ *config.Config) {
 	}
 
 	if userEmail == "" {
-		userEmail = envValues["USER_EMAIL"]
+		userEmail = cfg.User.Email
 	}
 
 	if userEmail == "" {
@@ -1064,7 +1085,6 @@ This email was sent as part of your email configuration testing.`
 func handleSystemStatus(w http.ResponseWriter, _ *http.Request, cfg *config.Config, db *sql.DB) {
 	w.Header().Set("Content-Type", "application/json")
 
-	envValues := readEnvFile()
 	status := map[string]interface{}{
 		"overall_status":     "healthy",
 		"timestamp":          fmt.Sprintf("%v", time.Now().UTC().Format(time.RFC3339)),
@@ -1124,33 +1144,20 @@ func handleSystemStatus(w http.ResponseWriter, _ *http.Request, cfg *config.Conf
 		"details": "",
 	}
 
-	emailProvider := envValues["EMAIL_PROVIDER"]
+	emailProvider := cfg.Email.Provider
 	if emailProvider == "" {
 		emailStatus["status"] = "not_configured"
 		emailStatus["details"] = "No email provider configured"
 		missingComponents = append(missingComponents, "Email Provider")
 	} else if emailProvider == "smtp" {
-		if envValues["SMTP_HOST"] != "" && envValues["SMTP_PORT"] != "" &&
-			envValues["SMTP_USERNAME"] != "" && envValues["SMTP_PASSWORD"] != "" {
-			emailConfig := &config.EmailConfig{
-				Provider: "smtp",
-				SMTP: config.SMTPConfig{
-					Host:     envValues["SMTP_HOST"],
-					Username: envValues["SMTP_USERNAME"],
-					Password: envValues["SMTP_PASSWORD"],
-				},
-			}
-			if port, err := strconv.Atoi(envValues["SMTP_PORT"]); err == nil {
-				emailConfig.SMTP.Port = port
-			}
-
-			emailClient := email.NewClient(emailConfig)
-			if err := emailClient.TestConnection(); err != nil {
+		if cfg.Email.SMTP.Host != "" && cfg.Email.SMTP.Port != 0 &&
+			cfg.Email.SMTP.Username != "" && cfg.Email.SMTP.Password != "" {
+			if err := currentClients().email.TestConnection(); err != nil {
 				emailStatus["status"] = "error"
 				emailStatus["details"] = fmt.Sprintf("SMTP connection failed: %v", err)
 			} else {
 				emailStatus["status"] = "healthy"
-				emailStatus["details"] = fmt.Sprintf("SMTP connection to %s:%s successful", envValues["SMTP_HOST"], envValues["SMTP_PORT"])
+				emailStatus["details"] = fmt.Sprintf("SMTP connection to %s:%d successful", cfg.Email.SMTP.Host, cfg.Email.SMTP.Port)
 				healthyCount++
 			}
 		} else {
@@ -1172,22 +1179,19 @@ func handleSystemStatus(w http.ResponseWriter, _ *http.Request, cfg *config.Conf
 		"details": "",
 	}
 
-	generationMethod := envValues["LETTER_GENERATION_METHOD"]
-	if generationMethod == "" {
-		generationMethod = "ai"
-	}
+	generationMethod := cfg.Letter.GenerationMethod
 
 	if generationMethod == "ai" {
-		aiProvider := envValues["AI_PROVIDER"]
+		aiProvider := cfg.AI.Provider
 		if aiProvider == "" {
 			aiStatus["status"] = "not_configured"
 			aiStatus["details"] = "AI generation selected but no provider configured"
 			missingComponents = append(missingComponents, "AI Provider")
-		} else if aiProvider == "openai" && envValues["OPENAI_API_KEY"] != "" {
+		} else if aiProvider == "openai" && cfg.AI.OpenAI.APIKey != "" {
 			aiStatus["status"] = "not_implemented"
 			aiStatus["details"] = "OpenAI API key configured but client not implemented"
 			missingComponents = append(missingComponents, "OpenAI Client Implementation")
-		} else if aiProvider == "anthropic" && envValues["ANTHROPIC_API_KEY"] != "" {
+		} else if aiProvider == "anthropic" && cfg.AI.Anthropic.APIKey != "" {
 			aiStatus["status"] = "not_implemented"
 			aiStatus["details"] = "Anthropic API key configured but client not implemented"
 			missingComponents = append(missingComponents, "Anthropic Client Implementation")
@@ -1197,10 +1201,7 @@ func handleSystemStatus(w http.ResponseWriter, _ *http.Request, cfg *config.Conf
 			missingComponents = append(missingComponents, fmt.Sprintf("%s API Key", aiProvider))
 		}
 	} else if generationMethod == "templates" {
-		templateDir := envValues["TEMPLATE_DIRECTORY"]
-		if templateDir == "" {
-			templateDir = "templates/"
-		}
+		templateDir := cfg.Letter.TemplateConfig.Directory
 		aiStatus["status"] = "not_implemented"
 		aiStatus["details"] = fmt.Sprintf("Template generation configured (dir: %s) but not implemented", templateDir)
 		missingComponents = append(missingComponents, "Template Engine Implementation")
@@ -1214,20 +1215,19 @@ func handleSystemStatus(w http.ResponseWriter, _ *http.Request, cfg *config.Conf
 		"details": "",
 	}
 
-	openstatesKey := envValues["OPENSTATES_API_KEY"]
-	userZip := envValues["USER_ZIP_CODE"]
+	userZip := cfg.User.ZipCode
 
-	if openstatesKey == "" {
+	if !isRepresentativesConfigured(cfg) {
 		repsStatus["status"] = "not_configured"
-		repsStatus["details"] = "No OpenStates API key configured"
-		missingComponents = append(missingComponents, "OpenStates API Key")
+		repsStatus["details"] = "No OpenStates API key or congress-legislators file configured"
+		missingComponents = append(missingComponents, "OpenStates API Key or Congress Legislators File")
 	} else if userZip == "" {
 		repsStatus["status"] = "incomplete"
-		repsStatus["details"] = "OpenStates API key configured but USER_ZIP_CODE missing"
+		repsStatus["details"] = "Representative sources configured but USER_ZIP_CODE missing"
 		missingComponents = append(missingComponents, "User ZIP Code")
 	} else if geocoderInstance == nil {
 		repsStatus["status"] = "error"
-		repsStatus["details"] = "OpenStates configured but geocoding service unavailable"
+		repsStatus["details"] = "Representative sources configured but geocoding service unavailable"
 	} else {
 		var repCount int
 		if err := db.QueryRow("SELECT COUNT(*) FROM representatives").Scan(&repCount); err != nil {
@@ -1235,11 +1235,11 @@ func handleSystemStatus(w http.ResponseWriter, _ *http.Request, cfg *config.Conf
 			repsStatus["details"] = fmt.Sprintf("Database error: %v", err)
 		} else if repCount > 0 {
 			repsStatus["status"] = "healthy"
-			repsStatus["details"] = fmt.Sprintf("OpenStates integration working - %d representatives loaded", repCount)
+			repsStatus["details"] = fmt.Sprintf("Representative lookup working - %d representatives loaded", repCount)
 			healthyCount++
 		} else {
 			repsStatus["status"] = "ready"
-			repsStatus["details"] = "OpenStates configured and ready - click 'Sync from OpenStates' to load representatives"
+			repsStatus["details"] = "Representative lookup configured and ready - click 'Sync Representatives' to load representatives"
 			healthyCount++
 		}
 	}
@@ -1278,7 +1278,7 @@ func handleSystemStatus(w http.ResponseWriter, _ *http.Request, cfg *config.Conf
 		"details": "",
 	}
 
-	if envValues["USER_NAME"] != "" && envValues["USER_EMAIL"] != "" && envValues["USER_ZIP_CODE"] != "" {
+	if cfg.User.Name != "" && cfg.User.Email != "" && cfg.User.ZipCode != "" {
 		userStatus["status"] = "healthy"
 		userStatus["details"] = "User information configured"
 		healthyCount++
@@ -1290,6 +1290,8 @@ func handleSystemStatus(w http.ResponseWriter, _ *http.Request, cfg *config.Conf
 	services["user_config"] = userStatus
 
 	status["missing_components"] = missingComponents
+	// Errors stop a configuration from loading, so only warnings can remain.
+	status["configuration_issues"] = cfg.Validate()
 	status["summary"] = map[string]interface{}{
 		"healthy_services":      healthyCount,
 		"total_services":        totalServices,
@@ -1394,14 +1396,6 @@ func handleDatabaseDebug(w http.ResponseWriter, _ *http.Request, db *sql.DB) {
 	json.NewEncoder(w).Encode(debugInfo)
 }
 
-func updateDatabaseUserPassword(db *sql.DB, newUser string, newPassword string) error {
-	query := fmt.Sprintf("ALTER USER %s WITH PASSWORD '%s'", newUser, newPassword)
-	if _, err := db.Exec(query); err != nil {
-		return fmt.Errorf("failed to update database user password: %w", err)
-	}
-	return nil
-}
-
 func runMigrations(db *sql.DB) error {
 	_, err := db.Exec(`
 		CREATE TABLE IF NOT EXISTS schema_migrations (
@@ -1429,6 +1423,22 @@ func runMigrations(db *sql.DB) error {
 	migrations := []string{
 		"001_initial_schema.sql",
 		"002_zip_coordinates.sql",
+		"003_prompt_templates.sql",
+		"004_letter_fingerprints.sql",
+		"005_campaigns.sql",
+		"006_user_profiles.sql",
+		"007_auth.sql",
+		"008_roles.sql",
+		"009_congress.sql",
+		"010_zcta_districts.sql",
+		"011_address_geocoding.sql",
+		"012_representative_contacts.sql",
+		"013_representative_changes.sql",
+		"014_openstates_cache.sql",
+		"015_representative_divisions.sql",
+		"016_representative_sources.sql",
+		"017_representative_zip_codes.sql",
+		"018_representative_overrides.sql",
 	}
 
 	for _, migration := range migrations {
@@ -1465,20 +1475,20 @@ func runMigrations(db *sql.DB) error {
 	return nil
 }
 
-func handleTestRepresentatives(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+func handleTestRepresentatives(w http.ResponseWriter, r *http.Request, cfg *config.Config, db *sql.DB) {
 	w.Header().Set("Content-Type", "application/json")
 
-	envValues := readEnvFile()
-	userZip := envValues["USER_ZIP_CODE"]
-	if userZip == "" {
+	user, err := currentUser(r, db)
+	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{
-			"error": "USER_ZIP_CODE not configured in .env file",
+			"error": err.Error(),
 		})
 		return
 	}
+	userZip := user.ZipCode
 
-	openstatesKey := envValues["OPENSTATES_API_KEY"]
+	openstatesKey := cfg.Representatives.OpenStatesAPIKey
 	if openstatesKey == "" {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{
@@ -1506,7 +1516,7 @@ func handleTestRepresentatives(w http.ResponseWriter, r *http.Request, db *sql.D
 		return
 	}
 
-	resp, err := geocoderInstance.GetRepresentativesFromZip(userZip, openstatesKey)
+	people, err := newOpenStatesClient(cfg, db).PeopleGeo(r.Context(), coords.Latitude, coords.Longitude)
 	if err != nil {
 		w.WriteHeader(http.StatusServiceUnavailable)
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -1521,18 +1531,6 @@ func handleTestRepresentatives(w http.ResponseWriter, r *http.Request, db *sql.D
 		})
 		return
 	}
-	defer resp.Body.Close()
-
-	var representatives map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&representatives); err != nil {
-		w.WriteHeader(http.StatusServiceUnavailable)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error":    fmt.Sprintf("Failed to parse OpenStates response: %v", err),
-			"zip_code": userZip,
-		})
-		return
-	}
-
 	result := map[string]interface{}{
 		"zip_code": userZip,
 		"coordinates": map[string]interface{}{
@@ -1541,8 +1539,8 @@ func handleTestRepresentatives(w http.ResponseWriter, r *http.Request, db *sql.D
 			"city":      coords.City,
 			"state":     coords.State,
 		},
-		"representatives": representatives,
-		"api_status":      resp.StatusCode,
+		"representatives": people,
+		"count":           len(people),
 	}
 
 	json.NewEncoder(w).Encode(result)
@@ -1551,19 +1549,19 @@ func handleTestRepresentatives(w http.ResponseWriter, r *http.Request, db *sql.D
 func handleGetRepresentatives(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 	w.Header().Set("Content-Type", "application/json")
 
-	envValues := readEnvFile()
-	userZip := envValues["USER_ZIP_CODE"]
-	if userZip == "" {
+	user, err := currentUser(r, db)
+	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{
-			"error": "USER_ZIP_CODE not configured in .env file",
+			"error": err.Error(),
 		})
 		return
 	}
+	userZip := user.ZipCode
 
 	repsService := reps.NewService(db)
 
-	representatives, err := repsService.GetUserRepresentatives(userZip)
+	representatives, err := repsService.GetUserRepresentatives(userZip, user.Districts)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{
@@ -1572,34 +1570,44 @@ func handleGetRepresentatives(w http.ResponseWriter, r *http.Request, db *sql.DB
 		return
 	}
 
-	result := map[string]interface{}{
-		"zip_code":        userZip,
-		"representatives": representatives,
+	districts, err := repsService.ResolveDistricts(userZip, user.Districts)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("Failed to get districts: %v", err),
+		})
+		return
+	}
+
+	result := map[string]interface{}{
+		"zip_code":        userZip,
+		"districts":       districts,
+		"representatives": representatives,
 		"count":           len(representatives),
 	}
 
 	json.NewEncoder(w).Encode(result)
 }
 
-func handleSyncRepresentatives(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+func handleSyncRepresentatives(w http.ResponseWriter, r *http.Request, cfg *config.Config, db *sql.DB) {
 	w.Header().Set("Content-Type", "application/json")
 
-	envValues := readEnvFile()
-	userZip := envValues["USER_ZIP_CODE"]
-	openstatesKey := envValues["OPENSTATES_API_KEY"]
-
-	if userZip == "" {
+	user, err := currentUser(r, db)
+	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{
-			"error": "USER_ZIP_CODE not configured in .env file",
+			"error": err.Error(),
 		})
 		return
 	}
+	userZip := user.ZipCode
 
-	if openstatesKey == "" {
+	legislatorsFile := cfg.Representatives.CongressLegislatorsFile
+
+	if !isRepresentativesConfigured(cfg) {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{
-			"error": "OPENSTATES_API_KEY not configured in .env file",
+			"error": "None of OPENSTATES_API_KEY, CONGRESS_LEGISLATORS_FILE and REP_SOURCES_FILE is configured",
 		})
 		return
 	}
@@ -1621,17 +1629,59 @@ func handleSyncRepresentatives(w http.ResponseWriter, r *http.Request, db *sql.D
 		return
 	}
 
-	repsService := reps.NewService(db)
-	err = repsService.SyncFromOpenStates(coords.Latitude, coords.Longitude, openstatesKey, coords.State)
+	// A geocoded street address is more precise than the ZIP code centroid
+	if user.Latitude != nil && user.Longitude != nil {
+		coords.Latitude, coords.Longitude = *user.Latitude, *user.Longitude
+	}
+
+	sources, err := representativeSources(cfg, db)
 	if err != nil {
-		w.WriteHeader(http.StatusServiceUnavailable)
+		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{
-			"error": fmt.Sprintf("Failed to sync representatives: %v", err),
+			"error": fmt.Sprintf("Failed to load representative sources: %v", err),
 		})
 		return
 	}
 
-	representatives, err := repsService.GetUserRepresentatives(userZip)
+	repsService := reps.NewService(db)
+	result := map[string]interface{}{
+		"status":   "Representatives synced successfully",
+		"zip_code": userZip,
+	}
+
+	// Members of Congress come from the congress-legislators file when one
+	// is configured, OpenStates supplies the state legislators and the
+	// sources file any others.
+	if legislatorsFile != "" {
+		count, err := repsService.ImportCongressLegislators(legislatorsFile)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": fmt.Sprintf("Failed to import members of Congress: %v", err),
+			})
+			return
+		}
+		result["congress_members_imported"] = count
+
+		cacheCongressionalDistrict(repsService, userZip, user.Districts)
+	}
+
+	location := reps.Location{ZipCode: userZip, State: coords.State, Latitude: coords.Latitude, Longitude: coords.Longitude}
+	synced := map[string]int{}
+	for _, source := range sources {
+		count, err := repsService.SyncFromSource(r.Context(), source, location)
+		if err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": fmt.Sprintf("Failed to sync representatives: %v", err),
+			})
+			return
+		}
+		synced[source.Name()] = count
+	}
+	result["sources"] = synced
+
+	representatives, err := repsService.GetUserRepresentatives(userZip, user.Districts)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{
@@ -1640,16 +1690,288 @@ func handleSyncRepresentatives(w http.ResponseWriter, r *http.Request, db *sql.D
 		return
 	}
 
-	result := map[string]interface{}{
-		"status":          "Representatives synced successfully",
-		"zip_code":        userZip,
-		"representatives": representatives,
-		"count":           len(representatives),
+	if districts, err := repsService.ResolveDistricts(userZip, user.Districts); err == nil {
+		result["districts"] = districts
+	}
+	result["representatives"] = representatives
+	result["count"] = len(representatives)
+
+	json.NewEncoder(w).Encode(result)
+}
+
+// cacheCongressionalDistrict looks up the congressional district of the ZIP
+// code's centroid, which is only needed when no relationship file has been
+// imported. Without either, every House member of the state is listed.
+func cacheCongressionalDistrict(repsService *reps.Service, zipCode string, chosen reps.Districts) {
+	districts, err := repsService.ResolveDistricts(zipCode, chosen)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	} else if len(districts.Congressional) == 0 {
+		if _, err := geocoderInstance.GetCongressionalDistrict(zipCode); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+}
+
+// runRepresentativeSync syncs the representatives of every user a minute
+// after startup and then every REP_SYNC_INTERVAL_HOURS, unless that is 0.
+func runRepresentativeSync(db *sql.DB) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	var lastRun time.Time
+	for range ticker.C {
+		cfg := configManager.Current()
+		interval := time.Duration(cfg.Representatives.SyncIntervalHours) * time.Hour
+		if interval <= 0 || time.Since(lastRun) < interval {
+			continue
+		}
+		lastRun = time.Now()
+
+		if err := syncAllRepresentatives(cfg, db); err != nil {
+			log.Printf("Warning: representative sync: %v", err)
+		}
+	}
+}
+
+// syncAllRepresentatives imports the members of Congress and asks every
+// source for the representatives at each distinct user location, like a sync
+// from the Representatives page for every user. When every location synced,
+// representatives no source returned any more are marked as departed.
+func syncAllRepresentatives(cfg *config.Config, db *sql.DB) error {
+	if !isRepresentativesConfigured(cfg) {
+		return nil
+	}
+	if geocoderInstance == nil {
+		return fmt.Errorf("geocoding service not available")
+	}
+
+	allUsers, err := users.NewService(db).List()
+	if err != nil {
+		return err
+	}
+	if len(allUsers) == 0 {
+		return nil
+	}
+
+	repsService := reps.NewService(db)
+	started, err := repsService.SyncStarted()
+	if err != nil {
+		return err
+	}
+
+	sources, err := representativeSources(cfg, db)
+	if err != nil {
+		return err
+	}
+
+	legislatorsFile := cfg.Representatives.CongressLegislatorsFile
+	if legislatorsFile != "" {
+		if _, err := repsService.ImportCongressLegislators(legislatorsFile); err != nil {
+			return fmt.Errorf("failed to import members of Congress: %w", err)
+		}
+	}
+
+	// Failures stop departures from being checked, but a user whose ZIP code
+	// is invalid or unknown is skipped, or one bad profile would keep every
+	// departed representative active.
+	synced := map[string]bool{}
+	failed, skipped := 0, 0
+	for _, user := range allUsers {
+		coords, err := geocoderInstance.GetCoordinates(user.ZipCode)
+		if errors.Is(err, geocoding.ErrZipNotFound) {
+			log.Printf("Warning: skipping representative sync for user %d: %v", user.ID, err)
+			skipped++
+			continue
+		}
+		if err != nil {
+			log.Printf("Warning: representative sync for user %d: %v", user.ID, err)
+			failed++
+			continue
+		}
+		if user.Latitude != nil && user.Longitude != nil {
+			coords.Latitude, coords.Longitude = *user.Latitude, *user.Longitude
+		}
+
+		if legislatorsFile != "" {
+			cacheCongressionalDistrict(repsService, user.ZipCode, user.Districts)
+		}
+
+		key := fmt.Sprintf("%s@%.5f,%.5f", user.ZipCode, coords.Latitude, coords.Longitude)
+		if len(sources) == 0 || synced[key] {
+			continue
+		}
+		synced[key] = true
+
+		location := reps.Location{ZipCode: user.ZipCode, State: coords.State, Latitude: coords.Latitude, Longitude: coords.Longitude}
+		for _, source := range sources {
+			if _, err := repsService.SyncFromSource(context.Background(), source, location); err != nil {
+				log.Printf("Warning: representative sync for user %d: %v", user.ID, err)
+				failed++
+				break
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d users' representatives failed to sync, departures not checked", failed)
+	}
+	if len(sources) > 0 && len(synced) == 0 {
+		return fmt.Errorf("no user's ZIP code could be located, departures not checked")
+	}
+
+	departed, err := repsService.MarkDeparted(started)
+	if err != nil {
+		return err
+	}
+	log.Printf("Synced representatives for %d locations, %d departed, %d users skipped", len(synced), departed, skipped)
+	return nil
+}
+
+// handleRepresentativeChanges lists what recent syncs changed, newest first.
+func handleRepresentativeChanges(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	w.Header().Set("Content-Type", "application/json")
+
+	limit := 50
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > 500 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "limit must be between 1 and 500",
+			})
+			return
+		}
+		limit = n
+	}
+
+	changes, err := reps.NewService(db).ListChanges(limit)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("Failed to get representative changes: %v", err),
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"changes": changes,
+		"count":   len(changes),
+	})
+}
+
+// handleExportRepresentatives downloads every representative as CSV or, with
+// format=json, JSON.
+func handleExportRepresentatives(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = reps.FormatCSV
+	}
+	if format != reps.FormatCSV && format != reps.FormatJSON {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "format must be csv or json",
+		})
+		return
+	}
+
+	representatives, err := reps.NewService(db).ListRepresentatives()
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("Failed to get representatives: %v", err),
+		})
+		return
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if format == reps.FormatJSON {
+		contentType = "application/json"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="representatives.%s"`, format))
+	if err := reps.ExportRepresentatives(w, format, representatives); err != nil {
+		log.Printf("Warning: failed to export representatives: %v", err)
+	}
+}
+
+// maxImportSize limits representative imports to a generous spreadsheet.
+const maxImportSize = 10 << 20
+
+// handleImportRepresentatives applies a CSV or JSON file of representatives,
+// or with dry_run=true shows what it would change. The format comes from
+// format or the Content-Type. Nothing is changed when any row is invalid.
+func handleImportRepresentatives(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	w.Header().Set("Content-Type", "application/json")
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = reps.FormatJSON
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+			format = reps.FormatCSV
+		}
+	}
+	if format != reps.FormatCSV && format != reps.FormatJSON {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "format must be csv or json",
+		})
+		return
+	}
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	records, err := reps.ParseImport(http.MaxBytesReader(w, r.Body, maxImportSize), format)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	result, err := reps.NewService(db).ImportRepresentatives(records, dryRun)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("Failed to import representatives: %v", err),
+		})
+		return
 	}
 
+	if len(result.Errors) > 0 && !dryRun {
+		w.WriteHeader(http.StatusBadRequest)
+	}
 	json.NewEncoder(w).Encode(result)
 }
 
+// handleCreateRepresentative adds a recipient no source knows about, which
+// syncs leave alone.
+func handleCreateRepresentative(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var rep reps.Representative
+	if err := json.NewDecoder(r.Body).Decode(&rep); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Invalid JSON format",
+		})
+		return
+	}
+
+	if err := reps.NewService(db).CreateRepresentative(&rep); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("Failed to create representative: %v", err),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rep)
+}
+
 func handleRepresentativeByID(w http.ResponseWriter, r *http.Request, db *sql.DB) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -1665,6 +1987,22 @@ func handleRepresentativeByID(w http.ResponseWriter, r *http.Request, db *sql.DB
 	repsService := reps.NewService(db)
 
 	switch r.Method {
+	case http.MethodGet:
+		rep, err := repsService.GetRepresentativeByID(id)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if err.Error() == "representative not found" {
+				status = http.StatusNotFound
+			}
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		json.NewEncoder(w).Encode(rep)
+
 	case http.MethodPut:
 		var updates map[string]interface{}
 		if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
@@ -1739,61 +2077,18 @@ func handleGenerateLetter(w http.ResponseWriter, r *http.Request, cfg *config.Co
 	specificConcern, _ := advocacy["specific_concern"].(string)
 	requestedAction, _ := advocacy["requested_action"].(string)
 
-	if mainIssue == "" || specificConcern == "" || requestedAction == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Missing required fields: main_issue, specific_concern, requested_action",
-		})
-		return
-	}
-
-	envValues := readEnvFile()
-	userName := envValues["USER_NAME"]
-	userZipCode := envValues["USER_ZIP_CODE"]
-
-	if userName == "" || userZipCode == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "User name and ZIP code must be configured",
-		})
-		return
-	}
-
-	aiProvider := envValues["AI_PROVIDER"]
-	if aiProvider == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "AI provider not configured",
-		})
-		return
-	}
-
-	var aiAPIKey, aiModel string
-	if aiProvider == "openai" {
-		aiAPIKey = envValues["OPENAI_API_KEY"]
-		aiModel = envValues["OPENAI_MODEL"]
-		if aiModel == "" {
-			aiModel = "gpt-4"
-		}
-	} else if aiProvider == "anthropic" {
-		aiAPIKey = envValues["ANTHROPIC_API_KEY"]
-		aiModel = envValues["ANTHROPIC_MODEL"]
-		if aiModel == "" {
-			aiModel = "claude-3-sonnet-20240229"
-		}
-	}
-
-	if aiAPIKey == "" {
+	user, err := currentUser(r, db)
+	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{
-			"error": fmt.Sprintf("%s API key not configured", aiProvider),
+			"error": err.Error(),
 		})
 		return
 	}
 
 	// Get all available representatives so AI can choose
 	repsService := reps.NewService(db)
-	representatives, err := repsService.GetUserRepresentatives(userZipCode)
+	representatives, err := repsService.GetUserRepresentatives(user.ZipCode, user.Districts)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{
@@ -1802,72 +2097,37 @@ func handleGenerateLetter(w http.ResponseWriter, r *http.Request, cfg *config.Co
 		return
 	}
 
-	if len(representatives) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "No representatives found. Please sync representatives first.",
-		})
-		return
-	}
-
-	// Convert representatives to the format expected by AI
-	availableReps := make([]ai.RepresentativeOption, len(representatives))
-	for i, rep := range representatives {
-		availableReps[i] = ai.RepresentativeOption{
-			ID:       rep.ID,
-			Name:     rep.Name,
-			Title:    rep.Title,
-			State:    rep.State,
-			Party:    rep.Party,
-			District: rep.District,
+	// In rules mode the targeting rules pick the recipient up front and the
+	// model only writes the letter.
+	selectionReasoning := "AI automatically selected the most appropriate representative for this issue"
+	var selection *targeting.Selection
+	if cfg.Representatives.SelectionMode == targeting.ModeRules && len(representatives) > 0 {
+		rules, err := targeting.NewStore(db, cfg.Representatives.TargetingRulesFile).Active()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": fmt.Sprintf("Failed to load targeting rules: %v", err),
+			})
+			return
 		}
-	}
-
-	aiClient, err := ai.NewClient(aiProvider, aiAPIKey, aiModel)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": fmt.Sprintf("Failed to create AI client: %v", err),
-		})
-		return
-	}
 
-	letterTone := envValues["LETTER_TONE"]
-	if letterTone == "" {
-		letterTone = "professional"
+		selection = rules.Select(mainIssue, specificConcern, requestedAction, representatives)
+		representatives = selection.Representatives[:1]
+		selectionReasoning = selection.Explanation
 	}
 
-	maxLength := 500
-	if maxLenStr := envValues["LETTER_MAX_LENGTH"]; maxLenStr != "" {
-		if parsed, err := strconv.Atoi(maxLenStr); err == nil {
-			maxLength = parsed
-		}
-	}
-
-	generationRequest := &ai.GenerationRequest{
-		MainIssue:                mainIssue,
-		SpecificIssue:            specificConcern,
-		RequestedAction:          requestedAction,
-		UserName:                 userName,
-		UserZipCode:              userZipCode,
-		AvailableRepresentatives: availableReps,
-		Tone:                     letterTone,
-		MaxLength:                maxLength,
-	}
-
-	ctx := context.Background()
-	letter, err := aiClient.GenerateLetter(ctx, generationRequest)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": fmt.Sprintf("Failed to generate letter: %v", err),
-		})
-		return
+	generated, genErr := generateAdvocacyLetter(r.Context(), cfg, db, user, mainIssue, specificConcern, requestedAction, representatives)
+	if genErr != nil {
+		writeLetterError(w, genErr)
+		return
 	}
+	letter := generated.Letter
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status": "Letter generated successfully",
+		"status":        "Letter generated successfully",
+		"lint":          generated.Lint,
+		"regenerations": generated.Regenerations,
 		"letter": map[string]interface{}{
 			"subject":                 letter.Subject,
 			"content":                 letter.Content,
@@ -1882,13 +2142,1521 @@ func handleGenerateLetter(w http.ResponseWriter, r *http.Request, cfg *config.Co
 		},
 		"ai_selection": map[string]interface{}{
 			"selected_representative_id": letter.Metadata.SelectedRepresentativeID,
-			"reasoning":                  "AI automatically selected the most appropriate representative for this issue",
+			"reasoning":                  selectionReasoning,
 		},
+		"targeting": selection,
 		"configuration_used": map[string]interface{}{
-			"max_length":  maxLength,
-			"tone":        letterTone,
-			"ai_provider": aiProvider,
-			"ai_model":    aiModel,
+			"max_length":     letter.Metadata.MaxLength,
+			"tone":           letter.Metadata.Tone,
+			"ai_provider":    letter.Metadata.Provider,
+			"ai_model":       letter.Metadata.Model,
+			"prompt_version": letter.Metadata.PromptVersion,
 		},
 	})
 }
+
+// letterError is a failure in the generate or send pipeline, carrying the
+// HTTP status and JSON body the API reports for it.
+type letterError struct {
+	status int
+	body   map[string]interface{}
+}
+
+func (e *letterError) Error() string {
+	msg, _ := e.body["error"].(string)
+	return msg
+}
+
+func newLetterError(status int, format string, args ...interface{}) *letterError {
+	return &letterError{status: status, body: map[string]interface{}{"error": fmt.Sprintf(format, args...)}}
+}
+
+func writeLetterError(w http.ResponseWriter, e *letterError) {
+	w.WriteHeader(e.status)
+	json.NewEncoder(w).Encode(e.body)
+}
+
+type generatedLetter struct {
+	Letter        *ai.Letter
+	Lint          lint.Report
+	Regenerations int
+}
+
+// generateAdvocacyLetter asks the configured AI provider for a letter to one
+// of the candidate representatives, regenerating when the result is too
+// similar to a letter already sent, and lints the result.
+func generateAdvocacyLetter(ctx context.Context, cfg *config.Config, db *sql.DB, user *users.User,
+	mainIssue, specificConcern, requestedAction string, representatives []reps.Representative) (*generatedLetter, *letterError) {
+	if mainIssue == "" || specificConcern == "" || requestedAction == "" {
+		return nil, newLetterError(http.StatusBadRequest, "Missing required fields: main_issue, specific_concern, requested_action")
+	}
+
+	if findings := ai.DetectInjection(map[string]string{
+		"main_issue":       mainIssue,
+		"specific_concern": specificConcern,
+		"requested_action": requestedAction,
+	}); len(findings) > 0 {
+		return nil, &letterError{status: http.StatusBadRequest, body: map[string]interface{}{
+			"error":    "Advocacy fields contain instruction-like content. Describe your concern in plain language.",
+			"findings": findings,
+		}}
+	}
+
+	clients := currentClients()
+	if clients.aiErr != nil {
+		return nil, clients.aiErr
+	}
+
+	if len(representatives) == 0 {
+		return nil, newLetterError(http.StatusBadRequest, "No representatives found. Please sync representatives first.")
+	}
+
+	// Convert representatives to the format expected by AI
+	availableReps := make([]ai.RepresentativeOption, len(representatives))
+	for i, rep := range representatives {
+		availableReps[i] = ai.RepresentativeOption{
+			ID:       rep.ID,
+			Name:     rep.Name,
+			Title:    rep.Title,
+			State:    rep.State,
+			Party:    rep.Party,
+			District: rep.District,
+		}
+		// Letters go to the capitol office where there is one
+		if office := rep.PreferredOffice(); office != nil {
+			availableReps[i].OfficeAddress = &office.Address
+		} else {
+			availableReps[i].OfficeAddress = rep.OfficeAddress
+		}
+	}
+
+	// The user's own preferences win over the instance-wide defaults
+	letterTone := user.Tone
+	if letterTone == "" {
+		letterTone = cfg.Letter.Tone
+	}
+
+	maxLength := user.MaxLength
+	if maxLength == 0 {
+		maxLength = cfg.Letter.MaxLength
+	}
+
+	promptTemplate, err := prompts.NewStore(db, cfg.AI.PromptDirectory).Active(ai.DefaultPromptName)
+	if err != nil {
+		return nil, newLetterError(http.StatusInternalServerError, "Failed to load prompt template: %v", err)
+	}
+
+	generationRequest := &ai.GenerationRequest{
+		MainIssue:                mainIssue,
+		SpecificIssue:            specificConcern,
+		RequestedAction:          requestedAction,
+		UserName:                 user.Name,
+		UserZipCode:              user.ZipCode,
+		UserSignature:            user.Signature,
+		AvailableRepresentatives: availableReps,
+		Tone:                     letterTone,
+		MaxLength:                maxLength,
+		PromptTemplate:           promptTemplate,
+	}
+
+	similarityThreshold, regenerateAttempts := cfg.Letter.SimilarityThreshold, cfg.Letter.RegenerateAttempts
+	letterStore := letters.NewStore(db)
+
+	var letter *ai.Letter
+	regenerations := 0
+	for {
+		letter, err = clients.ai.GenerateLetter(ctx, generationRequest)
+		if err != nil {
+			return nil, newLetterError(http.StatusInternalServerError, "Failed to generate letter: %v", err)
+		}
+
+		match, err := letterStore.FindSimilar(letter.SelectedRepresentative.ID, letters.Fingerprint(letter.Content), similarityThreshold)
+		if err != nil {
+			return nil, newLetterError(http.StatusInternalServerError, "Failed to check letter similarity: %v", err)
+		}
+		if match == nil {
+			break
+		}
+
+		if regenerations >= regenerateAttempts {
+			return nil, &letterError{status: http.StatusConflict, body: map[string]interface{}{
+				"error":      fmt.Sprintf("Generated letter is %.0f%% similar to letter %d already sent to %s. Try a different angle on the issue.", match.Similarity*100, match.LetterID, letter.SelectedRepresentative.Name),
+				"similar_to": match,
+			}}
+		}
+
+		regenerations++
+		log.Printf("Generated letter is %.0f%% similar to letter %d, regenerating (%d/%d)",
+			match.Similarity*100, match.LetterID, regenerations, regenerateAttempts)
+	}
+
+	return &generatedLetter{
+		Letter: letter,
+		Lint: lintLetter(letter.Content, user.Name, user.ZipCode,
+			letter.SelectedRepresentative.Name, letter.SelectedRepresentative.Title, letter.SelectedRepresentative.State),
+		Regenerations: regenerations,
+	}, nil
+}
+
+func handleListPrompts(w http.ResponseWriter, r *http.Request, cfg *config.Config, db *sql.DB) {
+	w.Header().Set("Content-Type", "application/json")
+
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		name = ai.DefaultPromptName
+	}
+
+	store := prompts.NewStore(db, cfg.AI.PromptDirectory)
+
+	active, err := store.Active(name)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("Failed to load active prompt: %v", err),
+		})
+		return
+	}
+
+	versions, err := store.List(name)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("Failed to list prompt versions: %v", err),
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"name":     name,
+		"active":   active,
+		"versions": versions,
+		"count":    len(versions),
+	})
+}
+
+func handleCreatePrompt(w http.ResponseWriter, r *http.Request, cfg *config.Config, db *sql.DB) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Name     string `json:"name"`
+		Content  string `json:"content"`
+		Notes    string `json:"notes"`
+		Activate bool   `json:"activate"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Invalid JSON format",
+		})
+		return
+	}
+	if req.Name == "" {
+		req.Name = ai.DefaultPromptName
+	}
+
+	version, err := prompts.NewStore(db, cfg.AI.PromptDirectory).Create(req.Name, req.Content, req.Notes, req.Activate)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("Failed to create prompt version: %v", err),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(version)
+}
+
+// handlePromptAction serves /api/prompts/validate, /api/prompts/activate
+// and /api/prompts/{version}.
+func handlePromptAction(w http.ResponseWriter, r *http.Request, cfg *config.Config, db *sql.DB) {
+	w.Header().Set("Content-Type", "application/json")
+
+	action := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/prompts/"), "/")
+	store := prompts.NewStore(db, cfg.AI.PromptDirectory)
+
+	switch {
+	case action == "validate" && r.Method == http.MethodPost:
+		var req struct {
+			Content string `json:"content"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid JSON format",
+			})
+			return
+		}
+
+		preview, err := ai.ValidatePromptTemplate(req.Content)
+		if err != nil {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"valid": false,
+				"error": err.Error(),
+			})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"valid":   true,
+			"preview": preview,
+		})
+
+	case action == "activate" && r.Method == http.MethodPost:
+		var req struct {
+			Name    string `json:"name"`
+			Version int    `json:"version"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid JSON format",
+			})
+			return
+		}
+		if req.Name == "" {
+			req.Name = ai.DefaultPromptName
+		}
+
+		if err := store.Activate(req.Name, req.Version); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": fmt.Sprintf("Failed to activate prompt version: %v", err),
+			})
+			return
+		}
+
+		active, err := store.Active(req.Name)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": fmt.Sprintf("Failed to load active prompt: %v", err),
+			})
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "Prompt version activated",
+			"active": active,
+		})
+
+	case r.Method == http.MethodGet:
+		version, err := strconv.Atoi(action)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": fmt.Sprintf("Invalid prompt version: %v", err),
+			})
+			return
+		}
+
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			name = ai.DefaultPromptName
+		}
+
+		v, err := store.Get(name, version)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		json.NewEncoder(w).Encode(v)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func lintLetter(content, userName, userZip, repName, repTitle, repState string) lint.Report {
+	input := lint.Input{
+		Content:             content,
+		ConstituentName:     userName,
+		ConstituentZipCode:  userZip,
+		RepresentativeName:  repName,
+		RepresentativeTitle: repTitle,
+		RepresentativeState: repState,
+	}
+
+	if geocoderInstance != nil {
+		if coords, err := geocoderInstance.GetCoordinates(userZip); err == nil {
+			input.ConstituentState = coords.State
+		}
+	}
+
+	return lint.Run(input)
+}
+
+// handleSendLetter saves a generated letter and emails it to the selected
+// representative. Letters with high-severity lint findings are refused.
+func handleSendLetter(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Subject          string      `json:"subject"`
+		Content          string      `json:"content"`
+		RepresentativeID int         `json:"representative_id"`
+		Metadata         ai.Metadata `json:"metadata"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Invalid JSON format",
+		})
+		return
+	}
+
+	if strings.TrimSpace(req.Subject) == "" || strings.TrimSpace(req.Content) == "" || req.RepresentativeID == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Missing required fields: subject, content, representative_id",
+		})
+		return
+	}
+
+	user, err := currentUser(r, db)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	rep, err := reps.NewService(db).GetRepresentativeByID(req.RepresentativeID)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("Failed to get representative: %v", err),
+		})
+		return
+	}
+
+	letter, lintReport, sendErr := deliverLetter(db, user, rep, req.Subject, req.Content, req.Metadata)
+	if sendErr != nil {
+		writeLetterError(w, sendErr)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "Letter sent successfully",
+		"letter_id": letter.ID,
+		"sent_to":   *rep.Email,
+		"lint":      lintReport,
+	})
+}
+
+// deliverLetter lints, de-duplicates, saves and emails a letter to rep. A
+// letter that was saved but failed to send is returned alongside the error.
+func deliverLetter(db *sql.DB, user *users.User, rep *reps.Representative,
+	subject, content string, metadata ai.Metadata) (*letters.Letter, lint.Report, *letterError) {
+	if rep.Email == nil || *rep.Email == "" {
+		return nil, lint.Report{}, newLetterError(http.StatusBadRequest, "%s %s has no email address on file", rep.Title, rep.Name)
+	}
+
+	lintReport := lintLetter(content, user.Name, user.ZipCode, rep.Name, rep.Title, rep.State)
+	if lintReport.Blocking {
+		return nil, lintReport, &letterError{status: http.StatusUnprocessableEntity, body: map[string]interface{}{
+			"error": "Letter has high-severity problems and was not sent",
+			"lint":  lintReport,
+		}}
+	}
+
+	clients := currentClients()
+	similarityThreshold := clients.config.Letter.SimilarityThreshold
+	fingerprint := letters.Fingerprint(content)
+	store := letters.NewStore(db)
+
+	match, err := store.FindSimilar(rep.ID, fingerprint, similarityThreshold)
+	if err != nil {
+		return nil, lintReport, newLetterError(http.StatusInternalServerError, "Failed to check letter similarity: %v", err)
+	}
+	if match != nil {
+		return nil, lintReport, &letterError{status: http.StatusConflict, body: map[string]interface{}{
+			"error":      fmt.Sprintf("Letter is %.0f%% similar to letter %d already sent to %s", match.Similarity*100, match.LetterID, rep.Name),
+			"similar_to": match,
+		}}
+	}
+
+	emailProvider := clients.config.Email.Provider
+	if emailProvider == "" {
+		return nil, lintReport, newLetterError(http.StatusBadRequest, "Email provider not configured")
+	}
+
+	letter := &letters.Letter{
+		UserID:           &user.ID,
+		RepresentativeID: rep.ID,
+		Subject:          subject,
+		Content:          content,
+		AIProvider:       metadata.Provider,
+		AIModel:          metadata.Model,
+		Theme:            metadata.Theme,
+		Tone:             metadata.Tone,
+	}
+	if metadata.PromptTemplateID != 0 {
+		letter.PromptTemplateID = &metadata.PromptTemplateID
+	}
+	if metadata.PromptVersion != "" {
+		letter.PromptVersion = &metadata.PromptVersion
+	}
+
+	if err := store.Create(letter); err != nil {
+		return nil, lintReport, newLetterError(http.StatusInternalServerError, "%v", err)
+	}
+
+	emailClient := clients.email
+	if err := emailClient.SendEmail(*rep.Email, subject, content); err != nil {
+		log.Printf("Failed to send letter %d to %s: %v", letter.ID, *rep.Email, err)
+		if markErr := store.MarkFailed(letter.ID, emailProvider, err); markErr != nil {
+			log.Printf("Warning: %v", markErr)
+		}
+		return letter, lintReport, &letterError{status: http.StatusBadGateway, body: map[string]interface{}{
+			"error":     fmt.Sprintf("Failed to send letter: %v", err),
+			"letter_id": letter.ID,
+		}}
+	}
+
+	if err := store.MarkSent(letter.ID, emailProvider); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if err := store.SaveFingerprint(letter.ID, rep.ID, fingerprint); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	if user.SendCopyToSelf {
+		if err := emailClient.SendEmail(user.Email, "Copy: "+subject, content); err != nil {
+			log.Printf("Warning: failed to send copy of letter %d to self: %v", letter.ID, err)
+		}
+	}
+
+	return letter, lintReport, nil
+}
+
+func handleLetterHistory(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user, err := currentUser(r, db)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	history, err := letters.NewStore(db).History(user.ID, limit)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("Failed to get letter history: %v", err),
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"letters": history,
+		"count":   len(history),
+	})
+}
+
+func handleListCampaigns(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	w.Header().Set("Content-Type", "application/json")
+
+	user, err := currentUser(r, db)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	list, err := campaigns.NewService(db).List(user.ID)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("Failed to list campaigns: %v", err),
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"campaigns": list,
+		"count":     len(list),
+	})
+}
+
+// handleCreateCampaign resolves the target rule against the constituent's
+// representatives and stores the campaign with one pending target each.
+func handleCreateCampaign(w http.ResponseWriter, r *http.Request, cfg *config.Config, db *sql.DB) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var c campaigns.Campaign
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Invalid JSON format",
+		})
+		return
+	}
+	if c.TargetRule == "" {
+		c.TargetRule = campaigns.RuleAll
+	}
+
+	user, err := currentUser(r, db)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.UserID = user.ID
+
+	representatives, err := reps.NewService(db).GetUserRepresentatives(user.ZipCode, user.Districts)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("Failed to get representatives: %v", err),
+		})
+		return
+	}
+
+	rules, err := targeting.NewStore(db, cfg.Representatives.TargetingRulesFile).Active()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("Failed to load targeting rules: %v", err),
+		})
+		return
+	}
+
+	targets, err := campaigns.SelectTargets(&c, rules, representatives)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := campaigns.NewService(db).Create(&c, targets); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("Failed to create campaign: %v", err),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"campaign": c,
+		"targets":  targets,
+	})
+}
+
+// handleCampaignAction serves /api/campaigns/{id}, /api/campaigns/{id}/summary
+// and /api/campaigns/{id}/run.
+func handleCampaignAction(w http.ResponseWriter, r *http.Request, cfg *config.Config, db *sql.DB) {
+	w.Header().Set("Content-Type", "application/json")
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/campaigns/"), "/"), "/")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Invalid campaign ID",
+		})
+		return
+	}
+	action := ""
+	if len(parts) > 1 {
+		action = parts[1]
+	}
+
+	user, err := currentUser(r, db)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// Other users' campaigns are reported as missing, except to admins.
+	service := campaigns.NewService(db)
+	if c, err := service.Get(id); err != nil || (c.UserID != user.ID && !auth.FromContext(r.Context()).IsAdmin()) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("campaign %d not found", id),
+		})
+		return
+	}
+
+	switch {
+	case (action == "" || action == "summary") && r.Method == http.MethodGet:
+		summary, err := service.Summary(id)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(summary)
+
+	case action == "run" && r.Method == http.MethodPost:
+		c, err := service.Claim(id)
+		if errors.Is(err, campaigns.ErrNotClaimed) {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Campaign is already running",
+			})
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		// Each target is a full AI round trip, so the run continues in the
+		// background and progress is read from the summary endpoint.
+		go service.RunClaimed(context.Background(), c, &campaignWriter{db: db}, reps.NewService(db))
+
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":  "Campaign started",
+			"summary": fmt.Sprintf("/api/campaigns/%d/summary", id),
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// campaignWriter sends campaign letters through the same generate and send
+// pipeline as letters written from the UI, offering the model only the
+// campaign's current target.
+type campaignWriter struct {
+	db *sql.DB
+}
+
+func (cw *campaignWriter) Write(ctx context.Context, c *campaigns.Campaign, rep reps.Representative) campaigns.Outcome {
+	user, err := users.NewService(cw.db).Get(c.UserID)
+	if err != nil {
+		return campaigns.Outcome{Status: campaigns.TargetFailed, Err: fmt.Errorf("campaign owner: %w", err)}
+	}
+
+	generated, genErr := generateAdvocacyLetter(ctx, configManager.Current(), cw.db, user,
+		c.MainIssue, c.SpecificConcern, c.RequestedAction, []reps.Representative{rep})
+	if genErr != nil {
+		return campaigns.Outcome{Status: outcomeStatus(genErr), Err: genErr}
+	}
+
+	letter, _, sendErr := deliverLetter(cw.db, user, &rep, generated.Letter.Subject, generated.Letter.Content, generated.Letter.Metadata)
+	outcome := campaigns.Outcome{Status: campaigns.TargetSent}
+	if letter != nil {
+		outcome.LetterID = &letter.ID
+	}
+	if sendErr != nil {
+		outcome.Status = outcomeStatus(sendErr)
+		outcome.Err = sendErr
+	}
+	return outcome
+}
+
+// outcomeStatus separates letters the checks refused from letters that failed
+// for operational reasons.
+func outcomeStatus(e *letterError) string {
+	if e.status == http.StatusUnprocessableEntity || e.status == http.StatusConflict {
+		return campaigns.TargetBlocked
+	}
+	return campaigns.TargetFailed
+}
+
+func runCampaign(db *sql.DB, id int) {
+	writer := &campaignWriter{db: db}
+	if err := campaigns.NewService(db).Run(context.Background(), id, writer, reps.NewService(db)); err != nil {
+		log.Printf("Campaign %d: %v", id, err)
+	}
+}
+
+// runCampaignScheduler starts scheduled campaigns once their time arrives,
+// unless SCHEDULER_ENABLED is off. It is told about configuration reloads so
+// a change to that setting is logged when it happens.
+func runCampaignScheduler(db *sql.DB) {
+	reloaded := make(chan *config.Config, 1)
+	configManager.Subscribe("campaign scheduler", func(cfg *config.Config) {
+		select {
+		case reloaded <- cfg:
+		default:
+		}
+	})
+
+	enabled := configManager.Current().Scheduler.Enabled
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case cfg := <-reloaded:
+			if cfg.Scheduler.Enabled != enabled {
+				enabled = cfg.Scheduler.Enabled
+				log.Printf("Campaign scheduler enabled: %t", enabled)
+			}
+		case <-ticker.C:
+			if !enabled {
+				continue
+			}
+			due, err := campaigns.NewService(db).Due(time.Now())
+			if err != nil {
+				log.Printf("Warning: campaign scheduler: %v", err)
+				continue
+			}
+			for _, id := range due {
+				log.Printf("Starting scheduled campaign %d", id)
+				runCampaign(db, id)
+			}
+		}
+	}
+}
+
+// handleTargetingRules reads (GET), replaces (PUT) or resets (DELETE) the
+// targeting rules stored in the database.
+func handleTargetingRules(w http.ResponseWriter, r *http.Request, cfg *config.Config, db *sql.DB) {
+	w.Header().Set("Content-Type", "application/json")
+
+	store := targeting.NewStore(db, cfg.Representatives.TargetingRulesFile)
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Failed to read request body",
+			})
+			return
+		}
+		if _, err := store.Save(body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+	case http.MethodDelete:
+		if err := store.Reset(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rules, err := store.Active()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("Failed to load targeting rules: %v", err),
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"mode":  cfg.Representatives.SelectionMode,
+		"rules": rules,
+	})
+}
+
+// handleTargetingPreview shows which representatives the rules would pick for
+// an issue, and why, without generating anything.
+func handleTargetingPreview(w http.ResponseWriter, r *http.Request, cfg *config.Config, db *sql.DB) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		MainIssue       string `json:"main_issue"`
+		SpecificConcern string `json:"specific_concern"`
+		RequestedAction string `json:"requested_action"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Invalid JSON format",
+		})
+		return
+	}
+
+	rules, err := targeting.NewStore(db, cfg.Representatives.TargetingRulesFile).Active()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("Failed to load targeting rules: %v", err),
+		})
+		return
+	}
+
+	user, err := currentUser(r, db)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	representatives, err := reps.NewService(db).GetUserRepresentatives(user.ZipCode, user.Districts)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("Failed to get representatives: %v", err),
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(rules.Select(req.MainIssue, req.SpecificConcern, req.RequestedAction, representatives))
+}
+
+// currentUser resolves the user a request acts for: the signed-in user.
+// Administrators may act for another user with the X-User-ID header (or
+// user_id query parameter).
+func currentUser(r *http.Request, db *sql.DB) (*users.User, error) {
+	principal := auth.FromContext(r.Context())
+	if principal == nil {
+		return nil, fmt.Errorf("not signed in")
+	}
+
+	userID := principal.UserID
+	id := r.Header.Get("X-User-ID")
+	if id == "" {
+		id = r.URL.Query().Get("user_id")
+	}
+	if id != "" {
+		requested, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid user ID %q", id)
+		}
+		if requested != userID && !principal.IsAdmin() {
+			return nil, fmt.Errorf("only administrators can act for another user")
+		}
+		userID = requested
+	}
+
+	return users.NewService(db).Get(userID)
+}
+
+func handleListUsers(w http.ResponseWriter, r *http.Request, db *sql.DB) {
+	w.Header().Set("Content-Type", "application/json")
+
+	list, err := users.NewService(db).List()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("Failed to list users: %v", err),
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"users": list,
+		"count": len(list),
+	})
+}
+
+func handleCreateUser(w http.ResponseWriter, r *http.Request, cfg *config.Config, db *sql.DB, authService *auth.Service) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		users.User
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Invalid JSON format",
+		})
+		return
+	}
+
+	if _, err := auth.HashPassword(req.Password); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	user := req.User
+	err := locateUser(r.Context(), cfg, db, &user)
+	if err == nil {
+		err = users.NewService(db).Create(&user)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := authService.SetPassword(user.ID, req.Password); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(user)
+}
+
+// representativeSources returns the sources synced for each location: OpenStates
+// when it has an API key, then those listed in REP_SOURCES_FILE. Members of
+// Congress from OpenStates are only kept without CONGRESS_LEGISLATORS_FILE.
+func representativeSources(cfg *config.Config, db *sql.DB) ([]reps.RepresentativeSource, error) {
+	var sources []reps.RepresentativeSource
+	if cfg.Representatives.OpenStatesAPIKey != "" {
+		sources = append(sources, reps.OpenStatesSource{
+			Client:         newOpenStatesClient(cfg, db),
+			IncludeFederal: cfg.Representatives.CongressLegislatorsFile == "",
+		})
+	}
+	if file := cfg.Representatives.SourcesFile; file != "" {
+		extra, err := reps.LoadSources(file)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, extra...)
+	}
+	return sources, nil
+}
+
+// newOpenStatesClient returns a client for the configured OpenStates API,
+// caching responses in the database unless OPENSTATES_CACHE_TTL_HOURS is 0.
+func newOpenStatesClient(cfg *config.Config, db *sql.DB) *openstates.Client {
+	var cache openstates.Cache
+	if hours := cfg.Representatives.OpenStatesCacheTTLHours; hours > 0 {
+		cache = openstates.NewDBCache(db, time.Duration(hours)*time.Hour)
+	}
+	return openstates.NewClient(cfg.Representatives.OpenStatesBaseURL, cfg.Representatives.OpenStatesAPIKey, cache)
+}
+
+// newAddressGeocoder returns the configured street address geocoder, or nil
+// when address geocoding is off.
+func newAddressGeocoder(cfg *config.Config, db *sql.DB) (geocoding.AddressGeocoder, error) {
+	var backend geocoding.AddressGeocoder
+	switch cfg.Geocoding.AddressGeocoder {
+	case "off":
+		return nil, nil
+	case "file":
+		fileGeocoder, err := geocoding.LoadFileAddressGeocoder(cfg.Geocoding.AddressGeocoderFile)
+		if err != nil {
+			return nil, err
+		}
+		backend = fileGeocoder
+	default:
+		backend = geocoding.NewCensusAddressGeocoder(cfg.Geocoding.AddressGeocoderURL)
+	}
+	return geocoding.NewCachedAddressGeocoder(db, backend), nil
+}
+
+// locateUser geocodes the user's street address, so that representative
+// lookups use it instead of the ZIP code centroid. Without an address the
+// coordinates are cleared.
+func locateUser(ctx context.Context, cfg *config.Config, db *sql.DB, user *users.User) error {
+	user.Latitude, user.Longitude = nil, nil
+	user.StreetAddress = strings.TrimSpace(user.StreetAddress)
+	if user.StreetAddress == "" {
+		return nil
+	}
+
+	geocoder, err := newAddressGeocoder(cfg, db)
+	if err != nil {
+		return err
+	}
+	if geocoder == nil {
+		return fmt.Errorf("street addresses cannot be used while ADDRESS_GEOCODER is off")
+	}
+
+	address := user.StreetAddress
+	if !strings.Contains(address, user.ZipCode) {
+		address += ", " + user.ZipCode
+	}
+	match, err := geocoder.GeocodeAddress(ctx, address)
+	if errors.Is(err, geocoding.ErrAddressNotFound) {
+		return fmt.Errorf("address %q was not found", user.StreetAddress)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to geocode address: %w", err)
+	}
+	if match.ZipCode != "" && !strings.HasPrefix(match.ZipCode, user.ZipCode) {
+		return fmt.Errorf("address %q is in ZIP code %s, not %s", user.StreetAddress, match.ZipCode, user.ZipCode)
+	}
+
+	user.Latitude, user.Longitude = &match.Latitude, &match.Longitude
+	return nil
+}
+
+// handleUserByID serves /api/users/me and /api/users/{id}.
+func handleUserByID(w http.ResponseWriter, r *http.Request, cfg *config.Config, db *sql.DB) {
+	w.Header().Set("Content-Type", "application/json")
+
+	service := users.NewService(db)
+
+	var user *users.User
+	var err error
+	idStr := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/users/"), "/")
+	if idStr == "me" {
+		user, err = currentUser(r, db)
+	} else {
+		id, convErr := strconv.Atoi(idStr)
+		if convErr != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid user ID",
+			})
+			return
+		}
+		user, err = service.Get(id)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(user)
+
+	case http.MethodPut:
+		id, role := user.ID, user.Role
+		zipCode, districts := user.ZipCode, user.Districts
+		address, latitude, longitude := user.StreetAddress, user.Latitude, user.Longitude
+		if err := json.NewDecoder(r.Body).Decode(user); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid JSON format",
+			})
+			return
+		}
+		user.ID = id
+		// Coordinates only come from geocoding the address
+		user.Latitude, user.Longitude = latitude, longitude
+		if user.StreetAddress != address || user.ZipCode != zipCode {
+			if err := locateUser(r.Context(), cfg, db, user); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error": err.Error(),
+				})
+				return
+			}
+		}
+		// Only administrators can change roles, including their own
+		if !auth.FromContext(r.Context()).IsAdmin() {
+			user.Role = role
+		}
+		// Districts chosen for the previous ZIP code no longer apply
+		if user.ZipCode != zipCode && user.Districts == districts {
+			user.Districts = reps.Districts{}
+		}
+		resolution, err := reps.NewService(db).ResolveDistricts(user.ZipCode, user.Districts)
+		if err == nil {
+			err = resolution.Check(user.Districts)
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+		if err := service.Update(user); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		updated, err := service.Get(user.ID)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(updated)
+
+	case http.MethodDelete:
+		if err := service.Delete(user.ID); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "User deleted successfully",
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// requiredRole maps a request to the least privileged role allowed to make
+// it. Reads need a viewer and changes a writer, except for configuration,
+// debugging, user management and shared settings, which are admin-only.
+func requiredRole(r *http.Request) string {
+	path := r.URL.Path
+	readOnly := r.Method == http.MethodGet || r.Method == http.MethodHead
+
+	switch {
+	case path == "/api/config" || strings.HasPrefix(path, "/api/config/"),
+		path == "/api/settings" || strings.HasPrefix(path, "/api/settings/"),
+		path == "/api/db/debug":
+		return auth.RoleAdmin
+	case strings.HasPrefix(path, "/api/auth/"):
+		// Everyone manages their own session, password and API tokens
+		return auth.RoleViewer
+	case path == "/api/users/me", path == "/api/representatives/sync":
+	case path == "/api/users" || strings.HasPrefix(path, "/api/users/"):
+		return auth.RoleAdmin
+	case (path == "/api/prompts" && !readOnly) || path == "/api/prompts/activate",
+		path == "/api/targeting/rules" && !readOnly,
+		(path == "/api/representatives" || strings.HasPrefix(path, "/api/representatives/")) && !readOnly:
+		return auth.RoleAdmin
+	}
+
+	if readOnly {
+		return auth.RoleViewer
+	}
+	return auth.RoleWriter
+}
+
+// isPublicPath lists what can be reached without signing in: the login page
+// and its assets, the endpoints it calls, and the health check.
+func isPublicPath(path string) bool {
+	switch path {
+	case "/api/health", "/api/auth/status", "/api/auth/login", "/api/auth/bootstrap",
+		auth.LoginPage, "/html" + auth.LoginPage:
+		return true
+	}
+	return strings.HasPrefix(path, "/css/") || strings.HasPrefix(path, "/js/")
+}
+
+// secureRequest reports whether the browser reached us over HTTPS, directly
+// or through a proxy.
+func secureRequest(r *http.Request, cfg *config.Config) bool {
+	return cfg.Auth.SecureCookies || r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+}
+
+func handleAuthStatus(w http.ResponseWriter, r *http.Request, authService *auth.Service) {
+	w.Header().Set("Content-Type", "application/json")
+
+	required, err := authService.BootstrapRequired()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	principal := auth.FromContext(r.Context())
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"authenticated":      principal != nil,
+		"user":               principal,
+		"bootstrap_required": required,
+	})
+}
+
+func handleLogin(w http.ResponseWriter, r *http.Request, cfg *config.Config, authService *auth.Service) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Invalid JSON format",
+		})
+		return
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	limitKey := strings.ToLower(strings.TrimSpace(req.Email)) + "|" + host
+	if !loginLimiter.Allow(limitKey) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Too many failed sign-in attempts. Try again in a few minutes.",
+		})
+		return
+	}
+
+	principal, err := authService.Authenticate(strings.TrimSpace(req.Email), req.Password)
+	if err != nil {
+		if err == auth.ErrInvalidCredentials {
+			loginLimiter.Fail(limitKey)
+			w.WriteHeader(http.StatusUnauthorized)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+	loginLimiter.Reset(limitKey)
+
+	session, err := authService.CreateSession(principal.UserID, r.UserAgent())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err := authService.DeleteExpiredSessions(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	auth.SetSessionCookies(w, session, secureRequest(r, cfg))
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "Signed in",
+		"user":   principal,
+	})
+}
+
+func handleLogout(w http.ResponseWriter, r *http.Request, cfg *config.Config, authService *auth.Service) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if cookie, err := r.Cookie(auth.SessionCookie); err == nil {
+		if err := authService.DeleteSession(cookie.Value); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+
+	auth.ClearSessionCookies(w, secureRequest(r, cfg))
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "Signed out",
+	})
+}
+
+// handleBootstrap creates the first administrator. It requires the bootstrap
+// token logged at startup (or AUTH_BOOTSTRAP_TOKEN) and stops working once an
+// administrator exists. Name and ZIP code default to the .env identity.
+func handleBootstrap(w http.ResponseWriter, r *http.Request, cfg *config.Config, authService *auth.Service) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Token    string `json:"bootstrap_token"`
+		Name     string `json:"name"`
+		Email    string `json:"email"`
+		ZipCode  string `json:"zip_code"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Invalid JSON format",
+		})
+		return
+	}
+
+	if bootstrapToken == "" || subtle.ConstantTimeCompare([]byte(req.Token), []byte(bootstrapToken)) != 1 {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Invalid bootstrap token",
+		})
+		return
+	}
+
+	if req.Name == "" {
+		req.Name = cfg.User.Name
+	}
+	if req.Email == "" {
+		req.Email = cfg.User.Email
+	}
+	if req.ZipCode == "" {
+		req.ZipCode = cfg.User.ZipCode
+	}
+	if req.Name == "" || req.Email == "" || req.ZipCode == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Missing required fields: name, email, zip_code",
+		})
+		return
+	}
+
+	principal, err := authService.Bootstrap(strings.TrimSpace(req.Name), strings.TrimSpace(req.Email), strings.TrimSpace(req.ZipCode), req.Password)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+	log.Printf("Created administrator account for %s", principal.Email)
+
+	session, err := authService.CreateSession(principal.UserID, r.UserAgent())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	auth.SetSessionCookies(w, session, secureRequest(r, cfg))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "Administrator created",
+		"user":   principal,
+	})
+}
+
+func handleChangePassword(w http.ResponseWriter, r *http.Request, authService *auth.Service) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Invalid JSON format",
+		})
+		return
+	}
+
+	keepSession := ""
+	if cookie, err := r.Cookie(auth.SessionCookie); err == nil {
+		keepSession = cookie.Value
+	}
+
+	principal := auth.FromContext(r.Context())
+	if err := authService.ChangePassword(principal.UserID, req.CurrentPassword, req.NewPassword, keepSession); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "Password changed; other sessions were signed out",
+	})
+}
+
+// handleAPITokens lists (GET) or creates (POST) the caller's API tokens.
+func handleAPITokens(w http.ResponseWriter, r *http.Request, authService *auth.Service) {
+	w.Header().Set("Content-Type", "application/json")
+
+	principal := auth.FromContext(r.Context())
+
+	switch r.Method {
+	case http.MethodGet:
+		tokens, err := authService.ListAPITokens(principal.UserID)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"tokens": tokens,
+			"count":  len(tokens),
+		})
+
+	case http.MethodPost:
+		var req struct {
+			Name          string `json:"name"`
+			ExpiresInDays int    `json:"expires_in_days"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Invalid JSON format",
+			})
+			return
+		}
+
+		var expiresAt *time.Time
+		if req.ExpiresInDays > 0 {
+			t := time.Now().AddDate(0, 0, req.ExpiresInDays)
+			expiresAt = &t
+		}
+
+		secret, token, err := authService.CreateAPIToken(principal.UserID, req.Name, expiresAt)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"token":   secret,
+			"details": token,
+			"note":    "Store this token now; it cannot be shown again. Send it as 'Authorization: Bearer <token>'.",
+		})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func handleRevokeAPIToken(w http.ResponseWriter, r *http.Request, authService *auth.Service) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/auth/tokens/"), "/"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Invalid token ID",
+		})
+		return
+	}
+
+	if err := authService.RevokeAPIToken(auth.FromContext(r.Context()).UserID, id); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "API token revoked",
+	})
+}
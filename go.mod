@@ -2,4 +2,8 @@ module github.com/yourdatasucks/lettersmith
 
 go 1.23
 
-require github.com/lib/pq v1.10.9
+require (
+	github.com/lib/pq v1.10.9
+	golang.org/x/crypto v0.31.0
+	gopkg.in/yaml.v3 v3.0.1
+)
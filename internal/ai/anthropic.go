@@ -1,20 +1,15 @@
 package ai
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"html/template"
 	"net/http"
-	"strconv"
 	"strings"
-	"time"
 )
 
 type AnthropicClient struct {
-	apiKey string
-	model  string
+	*generator
 }
 
 type AnthropicRequest struct {
@@ -42,7 +37,7 @@ type AnthropicUsage struct {
 	OutputTokens int `json:"output_tokens"`
 }
 
-func NewAnthropicClient(apiKey, model string) (*AnthropicClient, error) {
+func NewAnthropicClient(apiKey, model string, opts ClientOptions) (*AnthropicClient, error) {
 	if apiKey == "" {
 		return nil, fmt.Errorf("anthropic API key is required")
 	}
@@ -50,89 +45,61 @@ func NewAnthropicClient(apiKey, model string) (*AnthropicClient, error) {
 		model = "claude-3-sonnet-20240229"
 	}
 
-	return &AnthropicClient{
-		apiKey: apiKey,
-		model:  model,
-	}, nil
-}
-
-func (c *AnthropicClient) GenerateLetter(ctx context.Context, req *GenerationRequest) (*Letter, error) {
-	promptContent, err := promptTemplates.ReadFile("templates/advocacy-prompt.txt")
+	g, err := newGenerator("anthropic", apiKey, model, anthropicWire{}, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read prompt template: %w", err)
+		return nil, err
 	}
 
-	// Convert representatives to RepresentativeOption format
-	availableReps := make([]RepresentativeOption, len(req.AvailableRepresentatives))
-	for i, rep := range req.AvailableRepresentatives {
-		availableReps[i] = RepresentativeOption{
-			ID:       rep.ID,
-			Name:     rep.Name,
-			Title:    rep.Title,
-			State:    rep.State,
-			Party:    rep.Party,
-			District: rep.District,
-		}
-	}
-
-	data := PromptData{
-		Advocacy: AdvocacyContent{
-			MainIssue:       req.MainIssue,
-			SpecificConcern: req.SpecificIssue,
-			RequestedAction: req.RequestedAction,
-		},
-		Representative: RepresentativeInfo{
-			Title: "", // Will be filled after AI selection
-			Name:  "",
-			State: "",
-			Party: "",
-		},
-		AvailableRepresentatives: availableReps,
-		Constituent: ConstituentInfo{
-			Name:    req.UserName,
-			ZipCode: req.UserZipCode,
-		},
-		Preferences: LetterPreferences{
-			Tone:      req.Tone,
-			MaxLength: req.MaxLength,
-		},
-	}
+	return &AnthropicClient{generator: g}, nil
+}
 
-	tmpl := template.Must(template.New("advocacy").Parse(string(promptContent)))
+func (c *AnthropicClient) GenerateLetter(ctx context.Context, req *GenerationRequest) (*Letter, error) {
+	return c.generate(ctx, req)
+}
 
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return nil, fmt.Errorf("failed to execute template: %w", err)
+func (c *AnthropicClient) ValidateAPIKey(ctx context.Context) error {
+	if len(c.apiKey) < 20 || !strings.HasPrefix(c.apiKey, "sk-ant-") {
+		return fmt.Errorf("invalid Anthropic API key format")
 	}
+	return nil
+}
 
-	prompt := buf.String()
-
-	// Better token calculation: 1 word ≈ 1.33 tokens, with buffer for instructions
-	// Add 500 tokens buffer for the representative selection and formatting
-	// Be more aggressive with token allocation for longer letters
-	baseTokens := int(float64(req.MaxLength) * 1.5)
-	bufferTokens := 500
+func (c *AnthropicClient) GetProviderName() string {
+	return "anthropic"
+}
 
-	// For longer letters, add extra buffer to ensure AI doesn't run out of tokens
-	if req.MaxLength > 500 {
-		bufferTokens = 1000 // Extra buffer for long letters
+func (c *AnthropicClient) EstimateCost(req *GenerationRequest) float64 {
+	switch c.model {
+	case "claude-3-opus-20240229":
+		return 0.08
+	case "claude-3-sonnet-20240229":
+		return 0.04
+	case "claude-3-haiku-20240307":
+		return 0.02
+	default:
+		return 0.04
 	}
+}
 
-	maxTokens := baseTokens + bufferTokens
+// anthropicWire speaks the messages API.
+type anthropicWire struct{}
 
-	// Claude 3 models can handle larger outputs than we were using
-	tokenCap := 8000 // Increased from 4000
-	if maxTokens > tokenCap {
-		maxTokens = tokenCap
-	}
+func (anthropicWire) defaultBaseURL() string {
+	return "https://api.anthropic.com/v1"
+}
 
-	// Ensure minimum tokens for any reasonable response
-	if maxTokens < 200 {
-		maxTokens = 200
-	}
+func (anthropicWire) path() string {
+	return "/messages"
+}
 
-	anthropicReq := AnthropicRequest{
-		Model:     c.model,
+func (anthropicWire) setHeaders(h http.Header, apiKey string) {
+	h.Set("x-api-key", apiKey)
+	h.Set("anthropic-version", "2023-06-01")
+}
+
+func (anthropicWire) encodeRequest(model, prompt string, req *GenerationRequest, maxTokens int) interface{} {
+	return AnthropicRequest{
+		Model:     model,
 		MaxTokens: maxTokens,
 		Messages: []Message{
 			{
@@ -141,163 +108,28 @@ func (c *AnthropicClient) GenerateLetter(ctx context.Context, req *GenerationReq
 			},
 		},
 	}
+}
 
-	reqBody, err := json.Marshal(anthropicReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", "https://api.anthropic.com/v1/messages", bytes.NewBuffer(reqBody))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("x-api-key", c.apiKey)
-	httpReq.Header.Set("anthropic-version", "2023-06-01")
-
-	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make API request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		var errorBody bytes.Buffer
-		errorBody.ReadFrom(resp.Body)
-
-		if resp.StatusCode == 429 {
-			return nil, fmt.Errorf("anthropic rate limit exceeded (429). Error details: %s. Try again in a few minutes", errorBody.String())
-		}
-
-		return nil, fmt.Errorf("anthropic API returned status %d: %s", resp.StatusCode, errorBody.String())
-	}
-
+func (anthropicWire) decodeResponse(body []byte) (string, int, error) {
 	var anthropicResp AnthropicResponse
-	if err := json.NewDecoder(resp.Body).Decode(&anthropicResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if err := json.Unmarshal(body, &anthropicResp); err != nil {
+		return "", 0, fmt.Errorf("failed to decode response: %w", err)
 	}
 
 	if len(anthropicResp.Content) == 0 {
-		return nil, fmt.Errorf("no content returned from Anthropic")
-	}
-
-	content := anthropicResp.Content[0].Text
-
-	// Parse the selected representative ID and letter content
-	selectedRepID, letterContent, selectedRep, err := c.parseAIResponse(content, req.AvailableRepresentatives)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse AI response: %w", err)
-	}
-
-	subject := fmt.Sprintf("Advocacy Letter: %s - %s Constituent", req.MainIssue, selectedRep.State)
-
-	// Calculate actual word count for debugging
-	actualWordCount := len(strings.Fields(letterContent))
-
-	letter := &Letter{
-		Subject: subject,
-		Content: letterContent,
-		Metadata: Metadata{
-			Provider:                 "anthropic",
-			Model:                    c.model,
-			TokensUsed:               anthropicResp.Usage.InputTokens + anthropicResp.Usage.OutputTokens,
-			GeneratedAt:              time.Now(),
-			Tone:                     req.Tone,
-			Theme:                    req.MainIssue,
-			MaxLength:                req.MaxLength,
-			ActualWordCount:          actualWordCount,
-			SelectedRepresentativeID: selectedRepID,
-		},
-		CreatedAt:              time.Now(),
-		SelectedRepresentative: selectedRep,
-	}
-
-	return letter, nil
-}
-
-func (c *AnthropicClient) parseAIResponse(content string, availableReps []RepresentativeOption) (int, string, *RepresentativeOption, error) {
-	lines := strings.Split(content, "\n")
-
-	// Look for the selected representative ID in the first few lines
-	selectedRepID := -1
-	letterStartIndex := 0
-
-	for i, line := range lines {
-		trimmedLine := strings.TrimSpace(line)
-		upperLine := strings.ToUpper(trimmedLine)
-
-		// Look for either "SELECTED_REPRESENTATIVE_ID:" or "SELECTED REPRESENTATIVE ID:"
-		if strings.Contains(upperLine, "SELECTED") && strings.Contains(upperLine, "REPRESENTATIVE") && strings.Contains(upperLine, "ID:") {
-			// Extract ID from line like "SELECTED_REPRESENTATIVE_ID: 5" or "SELECTED REPRESENTATIVE ID: 5"
-			parts := strings.Split(trimmedLine, ":")
-			if len(parts) >= 2 {
-				idStr := strings.TrimSpace(parts[1])
-				if id, err := strconv.Atoi(idStr); err == nil {
-					selectedRepID = id
-					letterStartIndex = i + 1
-					break
-				}
-			}
-		}
-	}
-
-	// Be more strict - don't fall back if we can't parse the ID
-	if selectedRepID == -1 {
-		return 0, "", nil, fmt.Errorf("could not find SELECTED_REPRESENTATIVE_ID in AI response. Response: %s", content[:min(500, len(content))])
-	}
-
-	// Find the selected representative
-	var selectedRep *RepresentativeOption
-	for _, rep := range availableReps {
-		if rep.ID == selectedRepID {
-			selectedRep = &rep
-			break
-		}
-	}
-
-	if selectedRep == nil {
-		return 0, "", nil, fmt.Errorf("selected representative ID %d not found in available representatives", selectedRepID)
-	}
-
-	// Extract letter content (everything after the ID line)
-	letterLines := lines[letterStartIndex:]
-	letterContent := strings.TrimSpace(strings.Join(letterLines, "\n"))
-
-	if letterContent == "" {
-		return 0, "", nil, fmt.Errorf("no letter content found after representative ID")
+		return "", 0, fmt.Errorf("no content returned from Anthropic")
 	}
 
-	// Validate that the letter content mentions the selected representative
-	expectedName := selectedRep.Name
-	if !strings.Contains(letterContent, expectedName) {
-		return 0, "", nil, fmt.Errorf("letter content does not mention selected representative %s (ID: %d). This suggests the AI wrote to a different representative than selected", expectedName, selectedRepID)
-	}
-
-	return selectedRepID, letterContent, selectedRep, nil
+	return anthropicResp.Content[0].Text, anthropicResp.Usage.InputTokens + anthropicResp.Usage.OutputTokens, nil
 }
 
-func (c *AnthropicClient) ValidateAPIKey(ctx context.Context) error {
-	if len(c.apiKey) < 20 || !strings.HasPrefix(c.apiKey, "sk-ant-") {
-		return fmt.Errorf("invalid Anthropic API key format")
-	}
-	return nil
+func (anthropicWire) tokenCap(model string) int {
+	return 8000
 }
 
-func (c *AnthropicClient) GetProviderName() string {
-	return "anthropic"
-}
-
-func (c *AnthropicClient) EstimateCost(req *GenerationRequest) float64 {
-	switch c.model {
-	case "claude-3-opus-20240229":
-		return 0.08
-	case "claude-3-sonnet-20240229":
-		return 0.04
-	case "claude-3-haiku-20240307":
-		return 0.02
-	default:
-		return 0.04
+func (anthropicWire) statusError(status int, body string) error {
+	if status == http.StatusTooManyRequests {
+		return fmt.Errorf("anthropic rate limit exceeded (429). Error details: %s. Try again in a few minutes", body)
 	}
+	return fmt.Errorf("anthropic API returned status %d: %s", status, body)
 }
@@ -24,6 +24,8 @@ type Metadata struct {
 	MaxLength                int       `json:"max_length"`
 	ActualWordCount          int       `json:"actual_word_count"`
 	SelectedRepresentativeID int       `json:"selected_representative_id"`
+	PromptTemplateID         int       `json:"prompt_template_id,omitempty"`
+	PromptVersion            string    `json:"prompt_version"`
 }
 
 type GenerationRequest struct {
@@ -32,9 +34,11 @@ type GenerationRequest struct {
 	RequestedAction          string                 `json:"requested_action"`
 	UserName                 string                 `json:"user_name"`
 	UserZipCode              string                 `json:"user_zip_code"`
+	UserSignature            string                 `json:"user_signature,omitempty"`
 	AvailableRepresentatives []RepresentativeOption `json:"available_representatives"`
 	Tone                     string                 `json:"tone"`
 	MaxLength                int                    `json:"max_length"`
+	PromptTemplate           *PromptTemplate        `json:"-"`
 }
 
 type PromptData struct {
@@ -46,12 +50,13 @@ type PromptData struct {
 }
 
 type RepresentativeOption struct {
-	ID       int     `json:"id"`
-	Name     string  `json:"name"`
-	Title    string  `json:"title"`
-	State    string  `json:"state"`
-	Party    *string `json:"party,omitempty"`
-	District *string `json:"district,omitempty"`
+	ID            int     `json:"id"`
+	Name          string  `json:"name"`
+	Title         string  `json:"title"`
+	State         string  `json:"state"`
+	Party         *string `json:"party,omitempty"`
+	District      *string `json:"district,omitempty"`
+	OfficeAddress *string `json:"office_address,omitempty"`
 }
 
 type AdvocacyContent struct {
@@ -68,8 +73,9 @@ type RepresentativeInfo struct {
 }
 
 type ConstituentInfo struct {
-	Name    string `json:"name"`
-	ZipCode string `json:"zip_code"`
+	Name      string `json:"name"`
+	ZipCode   string `json:"zip_code"`
+	Signature string `json:"signature,omitempty"`
 }
 
 type LetterPreferences struct {
@@ -85,11 +91,15 @@ type AIClient interface {
 }
 
 func NewClient(provider, apiKey, model string) (AIClient, error) {
+	return NewClientWithOptions(provider, apiKey, model, ClientOptions{})
+}
+
+func NewClientWithOptions(provider, apiKey, model string, opts ClientOptions) (AIClient, error) {
 	switch provider {
 	case "openai":
-		return NewOpenAIClient(apiKey, model)
+		return NewOpenAIClient(apiKey, model, opts)
 	case "anthropic":
-		return NewAnthropicClient(apiKey, model)
+		return NewAnthropicClient(apiKey, model, opts)
 	default:
 		return nil, fmt.Errorf("unsupported AI provider: %s", provider)
 	}
@@ -0,0 +1,306 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultHTTPTimeout = 60 * time.Second
+
+// ClientOptions configures the HTTP layer shared by every provider. The zero
+// value talks to the provider's public API with a 60 second timeout.
+type ClientOptions struct {
+	BaseURL    string
+	Timeout    time.Duration
+	ProxyURL   string
+	HTTPClient *http.Client
+}
+
+// wireAdapter is the provider-specific part of a client: how a prompt is
+// encoded for the provider's API and how the reply is decoded.
+type wireAdapter interface {
+	defaultBaseURL() string
+	path() string
+	setHeaders(h http.Header, apiKey string)
+	encodeRequest(model, prompt string, req *GenerationRequest, maxTokens int) interface{}
+	decodeResponse(body []byte) (text string, tokensUsed int, err error)
+	tokenCap(model string) int
+	statusError(status int, body string) error
+}
+
+// generator holds everything the providers have in common: prompt rendering,
+// token budgeting, the HTTP round trip and response validation.
+type generator struct {
+	provider   string
+	apiKey     string
+	model      string
+	baseURL    string
+	httpClient *http.Client
+	wire       wireAdapter
+}
+
+var sharedHTTPClient = &http.Client{Timeout: defaultHTTPTimeout}
+
+func newGenerator(provider, apiKey, model string, wire wireAdapter, opts ClientOptions) (*generator, error) {
+	httpClient, err := newHTTPClient(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	baseURL := strings.TrimRight(opts.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = wire.defaultBaseURL()
+	}
+
+	return &generator{
+		provider:   provider,
+		apiKey:     apiKey,
+		model:      model,
+		baseURL:    baseURL,
+		httpClient: httpClient,
+		wire:       wire,
+	}, nil
+}
+
+func newHTTPClient(opts ClientOptions) (*http.Client, error) {
+	if opts.HTTPClient != nil {
+		return opts.HTTPClient, nil
+	}
+	if opts.Timeout == 0 && opts.ProxyURL == "" {
+		return sharedHTTPClient, nil
+	}
+
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = defaultHTTPTimeout
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.ProxyURL != "" {
+		proxyURL, err := url.Parse(opts.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL %q: %w", opts.ProxyURL, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	return &http.Client{Timeout: timeout, Transport: transport}, nil
+}
+
+func (g *generator) generate(ctx context.Context, req *GenerationRequest) (*Letter, error) {
+	prompt, promptTemplate, err := renderPrompt(req)
+	if err != nil {
+		return nil, err
+	}
+
+	maxTokens := tokenBudget(req.MaxLength, g.wire.tokenCap(g.model))
+
+	log.Printf("%s request: max_length=%d, max_tokens=%d, model=%s",
+		g.provider, req.MaxLength, maxTokens, g.model)
+
+	body, err := g.post(ctx, g.wire.encodeRequest(g.model, prompt, req, maxTokens))
+	if err != nil {
+		return nil, err
+	}
+
+	content, tokensUsed, err := g.wire.decodeResponse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	selectedRepID, letterContent, selectedRep, err := parseAIResponse(content, req.AvailableRepresentatives)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse AI response: %w", err)
+	}
+
+	// Models copying the signature block "exactly as written" often keep the
+	// delimiters around it.
+	letterContent = strings.TrimSpace(userInputDelimiter.ReplaceAllString(letterContent, ""))
+
+	if err := DetectPromptLeak(letterContent, prompt); err != nil {
+		return nil, fmt.Errorf("rejected generated letter: %w", err)
+	}
+
+	return &Letter{
+		Subject: fmt.Sprintf("Advocacy Letter: %s - %s Constituent", req.MainIssue, selectedRep.State),
+		Content: letterContent,
+		Metadata: Metadata{
+			Provider:                 g.provider,
+			Model:                    g.model,
+			TokensUsed:               tokensUsed,
+			GeneratedAt:              time.Now(),
+			Tone:                     req.Tone,
+			Theme:                    req.MainIssue,
+			MaxLength:                req.MaxLength,
+			ActualWordCount:          len(strings.Fields(letterContent)),
+			SelectedRepresentativeID: selectedRepID,
+			PromptTemplateID:         promptTemplate.ID,
+			PromptVersion:            promptTemplate.Label(),
+		},
+		CreatedAt:              time.Now(),
+		SelectedRepresentative: selectedRep,
+	}, nil
+}
+
+func (g *generator) post(ctx context.Context, payload interface{}) ([]byte, error) {
+	reqBody, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, g.baseURL+g.wire.path(), bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	g.wire.setHeaders(httpReq.Header, g.apiKey)
+
+	resp, err := g.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make API request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, g.wire.statusError(resp.StatusCode, string(body))
+	}
+
+	return body, nil
+}
+
+func renderPrompt(req *GenerationRequest) (string, *PromptTemplate, error) {
+	prompt := req.PromptTemplate
+	if prompt == nil {
+		var err error
+		if prompt, err = DefaultPromptTemplate(); err != nil {
+			return "", nil, err
+		}
+	}
+
+	rendered, err := executePrompt(prompt.Content, newPromptData(req))
+	if err != nil {
+		return "", nil, err
+	}
+
+	return rendered, prompt, nil
+}
+
+func newPromptData(req *GenerationRequest) PromptData {
+	availableReps := make([]RepresentativeOption, len(req.AvailableRepresentatives))
+	copy(availableReps, req.AvailableRepresentatives)
+
+	return PromptData{
+		Advocacy: AdvocacyContent{
+			MainIssue:       req.MainIssue,
+			SpecificConcern: req.SpecificIssue,
+			RequestedAction: req.RequestedAction,
+		},
+		// Filled in by the model's own selection
+		Representative:           RepresentativeInfo{},
+		AvailableRepresentatives: availableReps,
+		Constituent: ConstituentInfo{
+			Name:      req.UserName,
+			ZipCode:   req.UserZipCode,
+			Signature: req.UserSignature,
+		},
+		Preferences: LetterPreferences{
+			Tone:      req.Tone,
+			MaxLength: req.MaxLength,
+		},
+	}
+}
+
+// tokenBudget converts a requested word count into a max_tokens value.
+// 1 word ≈ 1.33 tokens; the buffer leaves room for the representative
+// selection line and formatting, and long letters get extra headroom so the
+// model doesn't run out mid-letter.
+func tokenBudget(maxLength, tokenCap int) int {
+	bufferTokens := 500
+	if maxLength > 500 {
+		bufferTokens = 1000
+	}
+
+	maxTokens := int(float64(maxLength)*1.5) + bufferTokens
+
+	if maxTokens > tokenCap {
+		maxTokens = tokenCap
+	}
+
+	// Ensure minimum tokens for any reasonable response
+	if maxTokens < 200 {
+		maxTokens = 200
+	}
+
+	return maxTokens
+}
+
+func parseAIResponse(content string, availableReps []RepresentativeOption) (int, string, *RepresentativeOption, error) {
+	lines := strings.Split(content, "\n")
+
+	selectedRepID := -1
+	letterStartIndex := 0
+
+	for i, line := range lines {
+		trimmedLine := strings.TrimSpace(line)
+		upperLine := strings.ToUpper(trimmedLine)
+
+		// Look for either "SELECTED_REPRESENTATIVE_ID:" or "SELECTED REPRESENTATIVE ID:"
+		if strings.Contains(upperLine, "SELECTED") && strings.Contains(upperLine, "REPRESENTATIVE") && strings.Contains(upperLine, "ID:") {
+			parts := strings.Split(trimmedLine, ":")
+			if len(parts) >= 2 {
+				idStr := strings.TrimSpace(parts[1])
+				if id, err := strconv.Atoi(idStr); err == nil {
+					selectedRepID = id
+					letterStartIndex = i + 1
+					break
+				}
+			}
+		}
+	}
+
+	// Be strict - don't fall back if we can't parse the ID
+	if selectedRepID == -1 {
+		log.Printf("Failed to parse representative ID from AI response. First 200 chars: %s", content[:min(200, len(content))])
+		return 0, "", nil, fmt.Errorf("could not find SELECTED_REPRESENTATIVE_ID in AI response. Response: %s", content[:min(500, len(content))])
+	}
+
+	var selectedRep *RepresentativeOption
+	for i := range availableReps {
+		if availableReps[i].ID == selectedRepID {
+			rep := availableReps[i]
+			selectedRep = &rep
+			break
+		}
+	}
+
+	if selectedRep == nil {
+		return 0, "", nil, fmt.Errorf("selected representative ID %d not found in available representatives", selectedRepID)
+	}
+
+	letterContent := strings.TrimSpace(strings.Join(lines[letterStartIndex:], "\n"))
+	if letterContent == "" {
+		return 0, "", nil, fmt.Errorf("no letter content found after representative ID")
+	}
+
+	// Validate that the letter content mentions the selected representative
+	if !strings.Contains(letterContent, selectedRep.Name) {
+		return 0, "", nil, fmt.Errorf("letter content does not mention selected representative %s (ID: %d). This suggests the AI wrote to a different representative than selected", selectedRep.Name, selectedRepID)
+	}
+
+	return selectedRepID, letterContent, selectedRep, nil
+}
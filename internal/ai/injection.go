@@ -0,0 +1,143 @@
+package ai
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	userInputOpen  = "<user_input>"
+	userInputClose = "</user_input>"
+)
+
+// InjectionFinding describes instruction-like content found in a
+// user-supplied advocacy field.
+type InjectionFinding struct {
+	Field   string `json:"field"`
+	Pattern string `json:"pattern"`
+	Match   string `json:"match"`
+}
+
+var userInputDelimiter = regexp.MustCompile(`(?i)</?\s*user_input\s*>`)
+
+// injectionPatterns only match instructions aimed at the assistant or its
+// prompt, since advocacy text often asks to override rules, display
+// instructions or act as a watchdog.
+var injectionPatterns = []struct {
+	name string
+	re   *regexp.Regexp
+}{
+	{"override previous instructions", regexp.MustCompile(`(?i)\b(ignore|disregard|forget|override)\b[^.\n]{0,30}\b(previous|prior|above|earlier|preceding|system|your|all)\b[^.\n]{0,20}\b(instructions?|prompts?|directions)\b`)},
+	{"prompt disclosure request", regexp.MustCompile(`(?i)\b(reveal|print|show|repeat|output|display|leak)\b[^.\n]{0,20}\b(your|the|these|initial|original|hidden)\s+(system\s+)?(prompt|instructions)\b`)},
+	{"role reassignment", regexp.MustCompile(`(?i)\b(you are now|from now on,? you|(act as|pretend to be|pretend you are)\s+(an?\s+)?(different\s+|unrestricted\s+)?(ai|assistant|chatbot|language model|llm)\b|new instructions\s*:)`)},
+	{"system prompt reference", regexp.MustCompile(`(?i)\bsystem\s+prompt\b`)},
+	{"chat role marker", regexp.MustCompile(`(?im)^\s*(system|assistant|user)\s*:`)},
+	{"response format marker", regexp.MustCompile(`(?i)SELECTED[_ ]REPRESENTATIVE[_ ]ID`)},
+	{"input delimiter", userInputDelimiter},
+	{"prompt section marker", regexp.MustCompile(`={3,}[ \t]*[A-Za-z][A-Za-z ]*?[ \t]*={3,}`)},
+}
+
+// DetectInjection scans user-supplied fields for content that tries to steer
+// the model instead of describing the constituent's concern. Findings are
+// ordered by field name.
+func DetectInjection(fields map[string]string) []InjectionFinding {
+	names := make([]string, 0, len(fields))
+	for field := range fields {
+		names = append(names, field)
+	}
+	sort.Strings(names)
+
+	var findings []InjectionFinding
+	for _, field := range names {
+		value := fields[field]
+		for _, p := range injectionPatterns {
+			if match := p.re.FindString(value); match != "" {
+				findings = append(findings, InjectionFinding{
+					Field:   field,
+					Pattern: p.name,
+					Match:   match,
+				})
+			}
+		}
+	}
+	return findings
+}
+
+// delimitUserInput wraps untrusted text in explicit delimiters for the prompt.
+// Any delimiter look-alikes inside the value are stripped so the text can't
+// close the block early.
+func delimitUserInput(value string) string {
+	value = userInputDelimiter.ReplaceAllString(value, "")
+	return userInputOpen + strings.TrimSpace(value) + userInputClose
+}
+
+// promptLeakMarkers are fragments of the prompt scaffolding that never belong
+// in a finished letter.
+var promptLeakMarkers = []string{
+	"=====",
+	"SELECTED_REPRESENTATIVE_ID",
+	userInputOpen,
+	userInputClose,
+	"RESPONSE FORMAT",
+	"CRITICAL FORMAT EXAMPLE",
+	"MANDATORY WORD COUNT",
+	"You are an expert advocacy letter writer",
+}
+
+const leakShingleSize = 10
+
+// DetectPromptLeak reports whether the generated letter echoes the prompt's
+// instructions, either via known scaffolding markers or a long verbatim run of
+// instruction text. User-supplied spans are excluded from the comparison since
+// the letter is expected to restate them.
+func DetectPromptLeak(letter, prompt string) error {
+	for _, marker := range promptLeakMarkers {
+		if strings.Contains(letter, marker) {
+			return fmt.Errorf("generated letter contains prompt text %q", marker)
+		}
+	}
+
+	shingles := make(map[string]bool)
+	words := leakWords(stripUserInput(prompt))
+	for i := 0; i+leakShingleSize <= len(words); i++ {
+		shingles[strings.Join(words[i:i+leakShingleSize], " ")] = true
+	}
+
+	letterWords := leakWords(letter)
+	for i := 0; i+leakShingleSize <= len(letterWords); i++ {
+		run := strings.Join(letterWords[i:i+leakShingleSize], " ")
+		if shingles[run] {
+			return fmt.Errorf("generated letter repeats prompt instructions verbatim: %q", run)
+		}
+	}
+
+	return nil
+}
+
+var leakWordPattern = regexp.MustCompile(`[a-z0-9']+`)
+
+func leakWords(text string) []string {
+	return leakWordPattern.FindAllString(strings.ToLower(text), -1)
+}
+
+func stripUserInput(prompt string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(prompt, userInputOpen)
+		if start == -1 {
+			b.WriteString(prompt)
+			break
+		}
+		b.WriteString(prompt[:start])
+		rest := prompt[start+len(userInputOpen):]
+		end := strings.Index(rest, userInputClose)
+		if end == -1 {
+			break
+		}
+		b.WriteString(" ")
+		prompt = rest[end+len(userInputClose):]
+	}
+	return b.String()
+}
@@ -1,25 +1,19 @@
 package ai
 
 import (
-	"bytes"
 	"context"
 	"embed"
 	"encoding/json"
 	"fmt"
-	"html/template"
-	"log"
 	"net/http"
-	"strconv"
 	"strings"
-	"time"
 )
 
 //go:embed templates/*.txt
 var promptTemplates embed.FS
 
 type OpenAIClient struct {
-	apiKey string
-	model  string
+	*generator
 }
 
 type OpenAIRequest struct {
@@ -55,7 +49,7 @@ type Usage struct {
 	TotalTokens      int `json:"total_tokens"`
 }
 
-func NewOpenAIClient(apiKey, model string) (*OpenAIClient, error) {
+func NewOpenAIClient(apiKey, model string, opts ClientOptions) (*OpenAIClient, error) {
 	if apiKey == "" {
 		return nil, fmt.Errorf("OpenAI API key is required")
 	}
@@ -63,274 +57,100 @@ func NewOpenAIClient(apiKey, model string) (*OpenAIClient, error) {
 		model = "gpt-4"
 	}
 
-	return &OpenAIClient{
-		apiKey: apiKey,
-		model:  model,
-	}, nil
-}
-
-func (c *OpenAIClient) GenerateLetter(ctx context.Context, req *GenerationRequest) (*Letter, error) {
-	promptContent, err := promptTemplates.ReadFile("templates/advocacy-prompt.txt")
+	g, err := newGenerator("openai", apiKey, model, openAIWire{}, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read prompt template: %w", err)
-	}
-
-	// Convert representatives to RepresentativeOption format
-	availableReps := make([]RepresentativeOption, len(req.AvailableRepresentatives))
-	for i, rep := range req.AvailableRepresentatives {
-		availableReps[i] = RepresentativeOption{
-			ID:       rep.ID,
-			Name:     rep.Name,
-			Title:    rep.Title,
-			State:    rep.State,
-			Party:    rep.Party,
-			District: rep.District,
-		}
-	}
-
-	data := PromptData{
-		Advocacy: AdvocacyContent{
-			MainIssue:       req.MainIssue,
-			SpecificConcern: req.SpecificIssue,
-			RequestedAction: req.RequestedAction,
-		},
-		Representative: RepresentativeInfo{
-			Title: "", // Will be filled after AI selection
-			Name:  "",
-			State: "",
-			Party: "",
-		},
-		AvailableRepresentatives: availableReps,
-		Constituent: ConstituentInfo{
-			Name:    req.UserName,
-			ZipCode: req.UserZipCode,
-		},
-		Preferences: LetterPreferences{
-			Tone:      req.Tone,
-			MaxLength: req.MaxLength,
-		},
-	}
-
-	tmpl := template.Must(template.New("advocacy").Parse(string(promptContent)))
-
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return nil, fmt.Errorf("failed to execute template: %w", err)
+		return nil, err
 	}
 
-	prompt := buf.String()
+	return &OpenAIClient{generator: g}, nil
+}
 
-	// Better token calculation: 1 word ≈ 1.33 tokens, with buffer for instructions
-	// Add 500 tokens buffer for the representative selection and formatting
-	// Be more aggressive with token allocation for longer letters
-	baseTokens := int(float64(req.MaxLength) * 1.5)
-	bufferTokens := 500
+func (c *OpenAIClient) GenerateLetter(ctx context.Context, req *GenerationRequest) (*Letter, error) {
+	return c.generate(ctx, req)
+}
 
-	// For longer letters, add extra buffer to ensure AI doesn't run out of tokens
-	if req.MaxLength > 500 {
-		bufferTokens = 1000 // Extra buffer for long letters
+func (c *OpenAIClient) ValidateAPIKey(ctx context.Context) error {
+	if len(c.apiKey) < 20 || !strings.HasPrefix(c.apiKey, "sk-") {
+		return fmt.Errorf("invalid OpenAI API key format")
 	}
+	return nil
+}
 
-	maxTokens := baseTokens + bufferTokens
+func (c *OpenAIClient) GetProviderName() string {
+	return "openai"
+}
 
-	// Use model-specific limits - be more generous
-	var tokenCap int
+func (c *OpenAIClient) EstimateCost(req *GenerationRequest) float64 {
 	switch c.model {
-	case "gpt-4", "gpt-4-turbo", "gpt-4-turbo-preview":
-		tokenCap = 16000 // Increase significantly for GPT-4 (it can handle up to 128k context)
+	case "gpt-4":
+		return 0.05
 	case "gpt-3.5-turbo":
-		tokenCap = 8000 // Increase for GPT-3.5-turbo
+		return 0.01
 	default:
-		tokenCap = 8000 // More generous default
+		return 0.03
 	}
+}
 
-	if maxTokens > tokenCap {
-		maxTokens = tokenCap
-	}
+// openAIWire speaks the chat completions API.
+type openAIWire struct{}
 
-	// Ensure minimum tokens for any reasonable response
-	if maxTokens < 200 {
-		maxTokens = 200
-	}
+func (openAIWire) defaultBaseURL() string {
+	return "https://api.openai.com/v1"
+}
 
-	// Debug logging to help troubleshoot word count issues
-	log.Printf("OpenAI request: max_length=%d, base_tokens=%d, buffer=%d, final_tokens=%d, model=%s",
-		req.MaxLength, baseTokens, bufferTokens, maxTokens, c.model)
+func (openAIWire) path() string {
+	return "/chat/completions"
+}
 
-	// Create messages with system message for better context setting
-	messages := []Message{
-		{
-			Role:    "system",
-			Content: fmt.Sprintf("You are an expert advocacy letter writer. When asked to write a %d-word letter, you MUST write exactly that length. Longer letters require comprehensive, detailed content with multiple well-developed sections. Do not write short letters when long ones are requested.", req.MaxLength),
-		},
-		{
-			Role:    "user",
-			Content: prompt,
-		},
-	}
+func (openAIWire) setHeaders(h http.Header, apiKey string) {
+	h.Set("Authorization", "Bearer "+apiKey)
+}
 
-	openaiReq := OpenAIRequest{
-		Model:       c.model,
-		Messages:    messages,
+func (openAIWire) encodeRequest(model, prompt string, req *GenerationRequest, maxTokens int) interface{} {
+	// System message sets the length expectation up front; GPT models tend
+	// to under-deliver on long letters otherwise.
+	return OpenAIRequest{
+		Model: model,
+		Messages: []Message{
+			{
+				Role:    "system",
+				Content: fmt.Sprintf("You are an expert advocacy letter writer. When asked to write a %d-word letter, you MUST write exactly that length. Longer letters require comprehensive, detailed content with multiple well-developed sections. Do not write short letters when long ones are requested.", req.MaxLength),
+			},
+			{
+				Role:    "user",
+				Content: prompt,
+			},
+		},
 		MaxTokens:   maxTokens,
 		Temperature: 0.7,
 	}
+}
 
-	reqBody, err := json.Marshal(openaiReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", "https://api.openai.com/v1/chat/completions", bytes.NewBuffer(reqBody))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
-
-	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to make API request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		var errorBody bytes.Buffer
-		errorBody.ReadFrom(resp.Body)
-
-		if resp.StatusCode == 429 {
-			return nil, fmt.Errorf("OpenAI rate limit exceeded (429). Error details: %s. Try again in a few minutes or check your quota at https://platform.openai.com/usage", errorBody.String())
-		}
-
-		return nil, fmt.Errorf("OpenAI API returned status %d: %s", resp.StatusCode, errorBody.String())
-	}
-
+func (openAIWire) decodeResponse(body []byte) (string, int, error) {
 	var openaiResp OpenAIResponse
-	if err := json.NewDecoder(resp.Body).Decode(&openaiResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	if err := json.Unmarshal(body, &openaiResp); err != nil {
+		return "", 0, fmt.Errorf("failed to decode response: %w", err)
 	}
 
 	if len(openaiResp.Choices) == 0 {
-		return nil, fmt.Errorf("no choices returned from OpenAI")
-	}
-
-	content := openaiResp.Choices[0].Message.Content
-
-	// Parse the selected representative ID and letter content
-	selectedRepID, letterContent, selectedRep, err := c.parseAIResponse(content, req.AvailableRepresentatives)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse AI response: %w", err)
-	}
-
-	subject := fmt.Sprintf("Advocacy Letter: %s - %s Constituent", req.MainIssue, selectedRep.State)
-
-	// Calculate actual word count for debugging
-	actualWordCount := len(strings.Fields(letterContent))
-
-	letter := &Letter{
-		Subject: subject,
-		Content: letterContent,
-		Metadata: Metadata{
-			Provider:                 "openai",
-			Model:                    c.model,
-			TokensUsed:               openaiResp.Usage.TotalTokens,
-			GeneratedAt:              time.Now(),
-			Tone:                     req.Tone,
-			Theme:                    req.MainIssue,
-			MaxLength:                req.MaxLength,
-			ActualWordCount:          actualWordCount,
-			SelectedRepresentativeID: selectedRepID,
-		},
-		CreatedAt:              time.Now(),
-		SelectedRepresentative: selectedRep,
-	}
-
-	return letter, nil
-}
-
-func (c *OpenAIClient) parseAIResponse(content string, availableReps []RepresentativeOption) (int, string, *RepresentativeOption, error) {
-	lines := strings.Split(content, "\n")
-
-	// Look for the selected representative ID in the first few lines
-	selectedRepID := -1
-	letterStartIndex := 0
-
-	for i, line := range lines {
-		trimmedLine := strings.TrimSpace(line)
-		upperLine := strings.ToUpper(trimmedLine)
-
-		// Look for either "SELECTED_REPRESENTATIVE_ID:" or "SELECTED REPRESENTATIVE ID:"
-		if strings.Contains(upperLine, "SELECTED") && strings.Contains(upperLine, "REPRESENTATIVE") && strings.Contains(upperLine, "ID:") {
-			// Extract ID from line like "SELECTED_REPRESENTATIVE_ID: 5" or "SELECTED REPRESENTATIVE ID: 5"
-			parts := strings.Split(trimmedLine, ":")
-			if len(parts) >= 2 {
-				idStr := strings.TrimSpace(parts[1])
-				if id, err := strconv.Atoi(idStr); err == nil {
-					selectedRepID = id
-					letterStartIndex = i + 1
-					break
-				}
-			}
-		}
-	}
-
-	// Be more strict - don't fall back if we can't parse the ID
-	if selectedRepID == -1 {
-		// Add debug logging to help troubleshoot parsing issues
-		log.Printf("Failed to parse representative ID from AI response. First 200 chars: %s", content[:min(200, len(content))])
-		return 0, "", nil, fmt.Errorf("could not find SELECTED_REPRESENTATIVE_ID in AI response. Response: %s", content[:min(500, len(content))])
-	}
-
-	// Find the selected representative
-	var selectedRep *RepresentativeOption
-	for _, rep := range availableReps {
-		if rep.ID == selectedRepID {
-			selectedRep = &rep
-			break
-		}
+		return "", 0, fmt.Errorf("no choices returned from OpenAI")
 	}
 
-	if selectedRep == nil {
-		return 0, "", nil, fmt.Errorf("selected representative ID %d not found in available representatives", selectedRepID)
-	}
-
-	// Extract letter content (everything after the ID line)
-	letterLines := lines[letterStartIndex:]
-	letterContent := strings.TrimSpace(strings.Join(letterLines, "\n"))
-
-	if letterContent == "" {
-		return 0, "", nil, fmt.Errorf("no letter content found after representative ID")
-	}
-
-	// Validate that the letter content mentions the selected representative
-	expectedName := selectedRep.Name
-	if !strings.Contains(letterContent, expectedName) {
-		return 0, "", nil, fmt.Errorf("letter content does not mention selected representative %s (ID: %d). This suggests the AI wrote to a different representative than selected", expectedName, selectedRepID)
-	}
-
-	return selectedRepID, letterContent, selectedRep, nil
+	return openaiResp.Choices[0].Message.Content, openaiResp.Usage.TotalTokens, nil
 }
 
-func (c *OpenAIClient) ValidateAPIKey(ctx context.Context) error {
-	if len(c.apiKey) < 20 || !strings.HasPrefix(c.apiKey, "sk-") {
-		return fmt.Errorf("invalid OpenAI API key format")
+func (openAIWire) tokenCap(model string) int {
+	switch model {
+	case "gpt-4", "gpt-4-turbo", "gpt-4-turbo-preview":
+		return 16000
+	default:
+		return 8000
 	}
-	return nil
 }
 
-func (c *OpenAIClient) GetProviderName() string {
-	return "openai"
-}
-
-func (c *OpenAIClient) EstimateCost(req *GenerationRequest) float64 {
-	switch c.model {
-	case "gpt-4":
-		return 0.05
-	case "gpt-3.5-turbo":
-		return 0.01
-	default:
-		return 0.03
+func (openAIWire) statusError(status int, body string) error {
+	if status == http.StatusTooManyRequests {
+		return fmt.Errorf("OpenAI rate limit exceeded (429). Error details: %s. Try again in a few minutes or check your quota at https://platform.openai.com/usage", body)
 	}
+	return fmt.Errorf("OpenAI API returned status %d: %s", status, body)
 }
@@ -0,0 +1,124 @@
+package ai
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+const (
+	DefaultPromptName = "advocacy-prompt"
+
+	PromptSourceEmbedded = "embedded"
+	PromptSourceFile     = "file"
+	PromptSourceDatabase = "database"
+)
+
+// PromptTemplate is a prompt body plus where it came from, so generated
+// letters can record exactly which prompt produced them.
+type PromptTemplate struct {
+	ID      int    `json:"id,omitempty"`
+	Name    string `json:"name"`
+	Version int    `json:"version"`
+	Source  string `json:"source"`
+	Content string `json:"content"`
+}
+
+func (p *PromptTemplate) Label() string {
+	if p.Source == PromptSourceDatabase {
+		return fmt.Sprintf("%s@v%d", p.Name, p.Version)
+	}
+	return fmt.Sprintf("%s@%s", p.Name, p.Source)
+}
+
+// DefaultPromptTemplate returns the prompt compiled into the binary.
+func DefaultPromptTemplate() (*PromptTemplate, error) {
+	content, err := promptTemplates.ReadFile("templates/" + DefaultPromptName + ".txt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt template: %w", err)
+	}
+
+	return &PromptTemplate{
+		Name:    DefaultPromptName,
+		Source:  PromptSourceEmbedded,
+		Content: string(content),
+	}, nil
+}
+
+// ValidatePromptTemplate parses content and executes it against sample data.
+// It returns the rendered sample so callers can preview the result.
+func ValidatePromptTemplate(content string) (string, error) {
+	if strings.TrimSpace(content) == "" {
+		return "", fmt.Errorf("prompt template is empty")
+	}
+
+	rendered, err := executePrompt(content, SamplePromptData())
+	if err != nil {
+		return "", err
+	}
+
+	// parseAIResponse depends on the model echoing this marker
+	if !strings.Contains(rendered, "SELECTED_REPRESENTATIVE_ID") {
+		return rendered, fmt.Errorf("prompt template must instruct the model to answer with SELECTED_REPRESENTATIVE_ID")
+	}
+
+	sample := SamplePromptData().Advocacy
+	for field, value := range map[string]string{
+		"Advocacy.MainIssue":       sample.MainIssue,
+		"Advocacy.SpecificConcern": sample.SpecificConcern,
+		"Advocacy.RequestedAction": sample.RequestedAction,
+	} {
+		if strings.Contains(stripUserInput(rendered), value) {
+			return rendered, fmt.Errorf("%s must be wrapped with the input function, e.g. {{input .%s}}", field, field)
+		}
+	}
+
+	return rendered, nil
+}
+
+// SamplePromptData is the fixture templates are validated against.
+func SamplePromptData() PromptData {
+	party := "Independent"
+	district := "12"
+
+	return PromptData{
+		Advocacy: AdvocacyContent{
+			MainIssue:       "data privacy protection",
+			SpecificConcern: "data brokers selling location history without consent",
+			RequestedAction: "co-sponsor comprehensive consumer privacy legislation",
+		},
+		AvailableRepresentatives: []RepresentativeOption{
+			{ID: 1, Name: "Jane Smith", Title: "Senator", State: "CA", Party: &party},
+			{ID: 2, Name: "John Doe", Title: "Representative", State: "CA", Party: &party, District: &district},
+		},
+		Constituent: ConstituentInfo{
+			Name:    "Sample Constituent",
+			ZipCode: "94103",
+		},
+		Preferences: LetterPreferences{
+			Tone:      "professional",
+			MaxLength: 500,
+		},
+	}
+}
+
+// promptFuncs are available to every prompt template. User-supplied text must
+// go through input so the model can tell data from instructions.
+var promptFuncs = template.FuncMap{
+	"input": delimitUserInput,
+}
+
+func executePrompt(content string, data PromptData) (string, error) {
+	tmpl, err := template.New("advocacy").Funcs(promptFuncs).Parse(content)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse prompt template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to execute template: %w", err)
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,193 @@
+// Package auth provides local password accounts, cookie sessions with CSRF
+// protection and API tokens for scripting.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	MinPasswordLength = 10
+	bcryptCost        = 12
+	DefaultSessionTTL = 7 * 24 * time.Hour
+)
+
+var ErrInvalidCredentials = errors.New("invalid email or password")
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	UserID int    `json:"user_id"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	Role   string `json:"role"`
+	// Method is "session" or "token". Only session requests need a CSRF token.
+	Method    string `json:"method"`
+	csrfToken string
+}
+
+type Service struct {
+	db         *sql.DB
+	sessionTTL time.Duration
+}
+
+func NewService(db *sql.DB, sessionTTL time.Duration) *Service {
+	if sessionTTL <= 0 {
+		sessionTTL = DefaultSessionTTL
+	}
+	return &Service{db: db, sessionTTL: sessionTTL}
+}
+
+func HashPassword(password string) (string, error) {
+	if len(password) < MinPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// dummyHash is compared against when the email is unknown so a login takes
+// the same time whether or not the account exists.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("lettersmith-dummy-password"), bcryptCost)
+
+// Authenticate checks an email and password and returns the matching user.
+func (s *Service) Authenticate(email, password string) (*Principal, error) {
+	var p Principal
+	var hash sql.NullString
+	err := s.db.QueryRow(`
+		SELECT id, name, email, role, password_hash FROM users WHERE lower(email) = lower($1)
+	`, email).Scan(&p.UserID, &p.Name, &p.Email, &p.Role, &hash)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to look up user: %w", err)
+	}
+
+	if err == sql.ErrNoRows || !hash.Valid {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash.String), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &p, nil
+}
+
+func (s *Service) SetPassword(userID int, password string) error {
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`UPDATE users SET password_hash = $2 WHERE id = $1`, userID, hash); err != nil {
+		return fmt.Errorf("failed to set password: %w", err)
+	}
+	return nil
+}
+
+// ChangePassword replaces a user's password after checking the current one,
+// and signs out every other session.
+func (s *Service) ChangePassword(userID int, current, next string, keepSession string) error {
+	var email string
+	if err := s.db.QueryRow(`SELECT email FROM users WHERE id = $1`, userID).Scan(&email); err != nil {
+		return fmt.Errorf("failed to look up user: %w", err)
+	}
+	if _, err := s.Authenticate(email, current); err != nil {
+		return err
+	}
+	if err := s.SetPassword(userID, next); err != nil {
+		return err
+	}
+
+	_, err := s.db.Exec(`DELETE FROM sessions WHERE user_id = $1 AND token_hash <> $2`, userID, hashToken(keepSession))
+	if err != nil {
+		return fmt.Errorf("failed to end other sessions: %w", err)
+	}
+	return nil
+}
+
+// BootstrapRequired reports whether no administrator can sign in yet.
+func (s *Service) BootstrapRequired() (bool, error) {
+	var exists bool
+	err := s.db.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM users WHERE role = $1 AND password_hash IS NOT NULL)
+	`, RoleAdmin).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check for administrators: %w", err)
+	}
+	return !exists, nil
+}
+
+// Bootstrap creates the first administrator, or promotes the existing user
+// with that email. It fails once any administrator exists.
+func (s *Service) Bootstrap(name, email, zipCode, password string) (*Principal, error) {
+	hash, err := HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Serialize concurrent bootstrap attempts
+	if _, err := tx.Exec(`LOCK TABLE users IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		return nil, fmt.Errorf("failed to lock users: %w", err)
+	}
+
+	var exists bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE role = $1 AND password_hash IS NOT NULL)`, RoleAdmin).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to check for administrators: %w", err)
+	}
+	if exists {
+		return nil, fmt.Errorf("an administrator already exists")
+	}
+
+	p := Principal{Email: email, Name: name, Role: RoleAdmin}
+	err = tx.QueryRow(`
+		INSERT INTO users (email, name, zip_code, password_hash, role)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (email) DO UPDATE SET password_hash = EXCLUDED.password_hash, role = EXCLUDED.role
+		RETURNING id, name
+	`, email, name, zipCode, hash, RoleAdmin).Scan(&p.UserID, &p.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create administrator: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to create administrator: %w", err)
+	}
+	return &p, nil
+}
+
+func randomToken(bytes int) (string, error) {
+	b := make([]byte, bytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// RandomToken returns a URL-safe random string for one-time secrets such as
+// the bootstrap token.
+func RandomToken() (string, error) {
+	return randomToken(24)
+}
+
+// hashToken is what gets stored for sessions and API tokens, so a database
+// leak does not hand out live credentials.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
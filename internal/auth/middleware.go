@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SessionCookie = "lettersmith_session"
+	// CSRFCookie is readable by the page's JavaScript, which echoes it back in
+	// CSRFHeader on every state-changing request.
+	CSRFCookie = "lettersmith_csrf"
+	CSRFHeader = "X-CSRF-Token"
+
+	LoginPage = "/login.html"
+)
+
+type contextKey struct{}
+
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(contextKey{}).(*Principal)
+	return p
+}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// Middleware authenticates every request with a bearer API token or a
+// session cookie. Paths for which public returns true are served without
+// credentials, though a valid session is still attached when present.
+func (s *Service) Middleware(next http.Handler, public func(path string) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := s.principal(r)
+
+		if public(r.URL.Path) {
+			if p != nil {
+				r = r.WithContext(WithPrincipal(r.Context(), p))
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if p == nil {
+			message := "Authentication required"
+			if err != nil {
+				message = err.Error()
+			}
+			deny(w, r, http.StatusUnauthorized, message)
+			return
+		}
+
+		if p.Method == "session" && !safeMethod(r.Method) {
+			sent := r.Header.Get(CSRFHeader)
+			if sent == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(p.csrfToken)) != 1 {
+				deny(w, r, http.StatusForbidden, "Missing or invalid CSRF token")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+	})
+}
+
+func (s *Service) principal(r *http.Request) (*Principal, error) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			return nil, nil
+		}
+		return s.LookupAPIToken(strings.TrimSpace(token))
+	}
+
+	cookie, err := r.Cookie(SessionCookie)
+	if err != nil || cookie.Value == "" {
+		return nil, nil
+	}
+	return s.LookupSession(cookie.Value)
+}
+
+func deny(w http.ResponseWriter, r *http.Request, status int, message string) {
+	if !strings.HasPrefix(r.URL.Path, "/api/") && status == http.StatusUnauthorized {
+		http.Redirect(w, r, LoginPage, http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": message,
+	})
+}
+
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// SetSessionCookies starts a browser session. secure should be true whenever
+// the server is reached over HTTPS.
+func SetSessionCookies(w http.ResponseWriter, session *Session, secure bool) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    session.Token,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     CSRFCookie,
+		Value:    session.CSRFToken,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		Secure:   secure,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+func ClearSessionCookies(w http.ResponseWriter, secure bool) {
+	for _, name := range []string{SessionCookie, CSRFCookie} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: name == SessionCookie,
+			Secure:   secure,
+		})
+	}
+}
+
+// LoginLimiter slows password guessing by refusing further attempts for a key
+// (email and client address) after repeated failures.
+type LoginLimiter struct {
+	mu       sync.Mutex
+	failures map[string][]time.Time
+	max      int
+	window   time.Duration
+}
+
+func NewLoginLimiter(max int, window time.Duration) *LoginLimiter {
+	return &LoginLimiter{failures: make(map[string][]time.Time), max: max, window: window}
+}
+
+func (l *LoginLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.recent(key)) < l.max
+}
+
+func (l *LoginLimiter) Fail(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.failures[key] = append(l.recent(key), time.Now())
+}
+
+func (l *LoginLimiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.failures, key)
+}
+
+func (l *LoginLimiter) recent(key string) []time.Time {
+	cutoff := time.Now().Add(-l.window)
+	kept := l.failures[key][:0]
+	for _, t := range l.failures[key] {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	if len(kept) == 0 {
+		delete(l.failures, key)
+		return nil
+	}
+	l.failures[key] = kept
+	return kept
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Roles, from most to least privileged. Each role can do everything the
+// roles below it can.
+const (
+	RoleAdmin  = "admin"
+	RoleWriter = "writer"
+	RoleViewer = "viewer"
+)
+
+var roleRank = map[string]int{
+	RoleViewer: 1,
+	RoleWriter: 2,
+	RoleAdmin:  3,
+}
+
+func ValidRole(role string) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
+// Has reports whether the principal's role is at least role.
+func (p *Principal) Has(role string) bool {
+	return p != nil && roleRank[p.Role] >= roleRank[role]
+}
+
+func (p *Principal) IsAdmin() bool {
+	return p.Has(RoleAdmin)
+}
+
+// RequireRole refuses authenticated requests whose principal lacks the role
+// that required returns for them. It runs inside Middleware, so requests
+// without a principal are public paths and pass through.
+func RequireRole(next http.Handler, required func(r *http.Request) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := FromContext(r.Context())
+		if p != nil {
+			if role := required(r); !p.Has(role) {
+				deny(w, r, http.StatusForbidden, fmt.Sprintf("This action requires the %s role", role))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
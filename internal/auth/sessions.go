@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const apiTokenPrefix = "ls_"
+
+type Session struct {
+	Token     string
+	CSRFToken string
+	ExpiresAt time.Time
+}
+
+type APIToken struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (s *Service) CreateSession(userID int, userAgent string) (*Session, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	csrf, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &Session{Token: token, CSRFToken: csrf, ExpiresAt: time.Now().Add(s.sessionTTL)}
+	_, err = s.db.Exec(`
+		INSERT INTO sessions (token_hash, user_id, csrf_token, user_agent, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, hashToken(token), userID, csrf, userAgent, session.ExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return session, nil
+}
+
+func (s *Service) LookupSession(token string) (*Principal, error) {
+	var p Principal
+	err := s.db.QueryRow(`
+		SELECT u.id, u.name, u.email, u.role, s.csrf_token
+		FROM sessions s JOIN users u ON u.id = s.user_id
+		WHERE s.token_hash = $1 AND s.expires_at > CURRENT_TIMESTAMP
+	`, hashToken(token)).Scan(&p.UserID, &p.Name, &p.Email, &p.Role, &p.csrfToken)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("session expired or not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up session: %w", err)
+	}
+
+	p.Method = "session"
+	return &p, nil
+}
+
+func (s *Service) DeleteSession(token string) error {
+	if _, err := s.db.Exec(`DELETE FROM sessions WHERE token_hash = $1`, hashToken(token)); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredSessions removes sessions past their expiry.
+func (s *Service) DeleteExpiredSessions() error {
+	if _, err := s.db.Exec(`DELETE FROM sessions WHERE expires_at <= CURRENT_TIMESTAMP`); err != nil {
+		return fmt.Errorf("failed to delete expired sessions: %w", err)
+	}
+	return nil
+}
+
+// CreateAPIToken issues a token for scripting. The plaintext is returned only
+// here; afterwards only its prefix is shown.
+func (s *Service) CreateAPIToken(userID int, name string, expiresAt *time.Time) (string, *APIToken, error) {
+	if strings.TrimSpace(name) == "" {
+		return "", nil, fmt.Errorf("token name is required")
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+	token := apiTokenPrefix + secret
+
+	t := &APIToken{Name: name, Prefix: token[:len(apiTokenPrefix)+6], ExpiresAt: expiresAt}
+	err = s.db.QueryRow(`
+		INSERT INTO api_tokens (user_id, name, token_hash, token_prefix, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, userID, name, hashToken(token), t.Prefix, expiresAt).Scan(&t.ID, &t.CreatedAt)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create API token: %w", err)
+	}
+
+	return token, t, nil
+}
+
+func (s *Service) ListAPITokens(userID int) ([]APIToken, error) {
+	rows, err := s.db.Query(`
+		SELECT id, name, token_prefix, last_used_at, expires_at, created_at
+		FROM api_tokens WHERE user_id = $1 ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []APIToken
+	for rows.Next() {
+		var t APIToken
+		if err := rows.Scan(&t.ID, &t.Name, &t.Prefix, &t.LastUsedAt, &t.ExpiresAt, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan API token: %w", err)
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+func (s *Service) RevokeAPIToken(userID, tokenID int) error {
+	result, err := s.db.Exec(`DELETE FROM api_tokens WHERE id = $1 AND user_id = $2`, tokenID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API token: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("API token %d not found", tokenID)
+	}
+	return nil
+}
+
+func (s *Service) LookupAPIToken(token string) (*Principal, error) {
+	if !strings.HasPrefix(token, apiTokenPrefix) {
+		return nil, fmt.Errorf("malformed API token")
+	}
+
+	var p Principal
+	err := s.db.QueryRow(`
+		UPDATE api_tokens t SET last_used_at = CURRENT_TIMESTAMP
+		FROM users u
+		WHERE t.token_hash = $1 AND u.id = t.user_id
+		  AND (t.expires_at IS NULL OR t.expires_at > CURRENT_TIMESTAMP)
+		RETURNING u.id, u.name, u.email, u.role
+	`, hashToken(token)).Scan(&p.UserID, &p.Name, &p.Email, &p.Role)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("API token expired or not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up API token: %w", err)
+	}
+
+	p.Method = "token"
+	return &p, nil
+}
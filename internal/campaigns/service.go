@@ -0,0 +1,350 @@
+package campaigns
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/yourdatasucks/lettersmith/internal/reps"
+	"github.com/yourdatasucks/lettersmith/internal/targeting"
+)
+
+const selectCampaigns = `
+	SELECT id, COALESCE(user_id, 0), name, main_issue, specific_concern, requested_action, target_rule, target_ids,
+	       scheduled_at, status, last_run_at, created_at, updated_at
+	FROM campaigns
+`
+
+// Create stores a campaign and one pending target per representative. A
+// campaign with a scheduled time is picked up by RunDue; otherwise it stays a
+// draft until it is run explicitly.
+func (s *Service) Create(c *Campaign, targets []reps.Representative) error {
+	if c.Name == "" || c.MainIssue == "" || c.SpecificConcern == "" || c.RequestedAction == "" {
+		return fmt.Errorf("missing required fields: name, main_issue, specific_concern, requested_action")
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("target rule %q matched no representatives", c.TargetRule)
+	}
+
+	c.Status = StatusDraft
+	if c.ScheduledAt != nil {
+		c.Status = StatusScheduled
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO campaigns (user_id, name, main_issue, specific_concern, requested_action, target_rule, target_ids, scheduled_at, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at, updated_at
+	`, c.UserID, c.Name, c.MainIssue, c.SpecificConcern, c.RequestedAction, c.TargetRule,
+		pq.Array(toInt64s(c.TargetIDs)), c.ScheduledAt, c.Status,
+	).Scan(&c.ID, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create campaign: %w", err)
+	}
+
+	for _, rep := range targets {
+		if _, err := tx.Exec(`
+			INSERT INTO campaign_targets (campaign_id, representative_id) VALUES ($1, $2)
+			ON CONFLICT (campaign_id, representative_id) DO NOTHING
+		`, c.ID, rep.ID); err != nil {
+			return fmt.Errorf("failed to add target %s: %w", rep.Name, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *Service) Get(id int) (*Campaign, error) {
+	list, err := s.query(selectCampaigns+" WHERE id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("campaign %d not found", id)
+	}
+	return &list[0], nil
+}
+
+func (s *Service) List(userID int) ([]Campaign, error) {
+	return s.query(selectCampaigns+" WHERE user_id = $1 ORDER BY created_at DESC", userID)
+}
+
+func (s *Service) Targets(campaignID int) ([]Target, error) {
+	rows, err := s.db.Query(`
+		SELECT t.id, t.campaign_id, t.representative_id, r.name, r.title, t.letter_id, t.status, t.error, t.updated_at
+		FROM campaign_targets t
+		JOIN representatives r ON r.id = t.representative_id
+		WHERE t.campaign_id = $1
+		ORDER BY r.title, r.name
+	`, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query campaign targets: %w", err)
+	}
+	defer rows.Close()
+
+	var targets []Target
+	for rows.Next() {
+		var t Target
+		var letterID sql.NullInt64
+		var errMsg sql.NullString
+		if err := rows.Scan(&t.ID, &t.CampaignID, &t.RepresentativeID, &t.RepresentativeName,
+			&t.RepresentativeTitle, &letterID, &t.Status, &errMsg, &t.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan campaign target: %w", err)
+		}
+		if letterID.Valid {
+			id := int(letterID.Int64)
+			t.LetterID = &id
+		}
+		if errMsg.Valid {
+			t.Error = &errMsg.String
+		}
+		targets = append(targets, t)
+	}
+
+	return targets, rows.Err()
+}
+
+func (s *Service) Summary(id int) (*Summary, error) {
+	c, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	targets, err := s.Targets(id)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &Summary{
+		Campaign: *c,
+		Total:    len(targets),
+		ByStatus: map[string]int{TargetPending: 0, TargetSent: 0, TargetBlocked: 0, TargetFailed: 0},
+		Targets:  targets,
+	}
+	for _, t := range targets {
+		summary.ByStatus[t.Status]++
+	}
+
+	return summary, nil
+}
+
+// Run claims a campaign and writes a letter for every target that has not
+// been sent yet. Run returns an error only if the campaign could not be
+// claimed; per-target failures are recorded on the targets.
+func (s *Service) Run(ctx context.Context, id int, writer Writer, repsService *reps.Service) error {
+	c, err := s.Claim(id)
+	if err != nil {
+		return err
+	}
+	s.RunClaimed(ctx, c, writer, repsService)
+	return nil
+}
+
+// RunClaimed writes the letters of a campaign returned by Claim. Targets that
+// were blocked or failed on an earlier run are retried.
+func (s *Service) RunClaimed(ctx context.Context, c *Campaign, writer Writer, repsService *reps.Service) {
+	id := c.ID
+	targets, err := s.Targets(id)
+	if err != nil {
+		log.Printf("Campaign %d: %v", id, err)
+		s.finish(id, StatusCompletedWithErrors)
+		return
+	}
+
+	failures := 0
+	for _, t := range targets {
+		if t.Status == TargetSent {
+			continue
+		}
+		if ctx.Err() != nil {
+			failures++
+			continue
+		}
+
+		rep, err := repsService.GetRepresentativeByID(t.RepresentativeID)
+		if err != nil {
+			s.updateTarget(t.ID, Outcome{Status: TargetFailed, Err: err})
+			failures++
+			continue
+		}
+
+		outcome := writer.Write(ctx, c, *rep)
+		if outcome.Status != TargetSent {
+			failures++
+			log.Printf("Campaign %d: letter to %s %s %s: %v", id, rep.Title, rep.Name, outcome.Status, outcome.Err)
+		}
+		s.updateTarget(t.ID, outcome)
+	}
+
+	status := StatusCompleted
+	if failures > 0 {
+		status = StatusCompletedWithErrors
+	}
+	s.finish(id, status)
+
+	log.Printf("Campaign %d finished: %d targets, %d not sent", id, len(targets), failures)
+}
+
+// Due returns the IDs of scheduled campaigns whose time has come.
+func (s *Service) Due(now time.Time) ([]int, error) {
+	rows, err := s.db.Query(`
+		SELECT id FROM campaigns WHERE status = $1 AND scheduled_at <= $2 ORDER BY scheduled_at
+	`, StatusScheduled, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due campaigns: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan campaign id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ErrNotClaimed is returned by Claim when the campaign is already running.
+var ErrNotClaimed = errors.New("campaign is already running")
+
+// Claim moves a campaign into the running state. Only one caller can claim a
+// campaign at a time, so the scheduler and a manual run never overlap.
+func (s *Service) Claim(id int) (*Campaign, error) {
+	list, err := s.query(`
+		UPDATE campaigns SET status = $2, last_run_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status <> $2
+		RETURNING id, COALESCE(user_id, 0), name, main_issue, specific_concern, requested_action, target_rule, target_ids,
+		          scheduled_at, status, last_run_at, created_at, updated_at
+	`, id, StatusRunning)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		if _, err := s.Get(id); err != nil {
+			return nil, err
+		}
+		return nil, ErrNotClaimed
+	}
+	return &list[0], nil
+}
+
+// ResetInterrupted marks campaigns left running by a server that stopped
+// mid-run as completed with errors, so they can be run again, and returns
+// how many there were. Call it at startup, before any campaign runs.
+func (s *Service) ResetInterrupted() (int, error) {
+	result, err := s.db.Exec(`UPDATE campaigns SET status = $2 WHERE status = $1`, StatusRunning, StatusCompletedWithErrors)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reset interrupted campaigns: %w", err)
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+func (s *Service) finish(id int, status string) {
+	if _, err := s.db.Exec(`UPDATE campaigns SET status = $2 WHERE id = $1`, id, status); err != nil {
+		log.Printf("Warning: failed to update campaign %d status: %v", id, err)
+	}
+}
+
+func (s *Service) updateTarget(id int, outcome Outcome) {
+	var errMsg *string
+	if outcome.Err != nil {
+		msg := outcome.Err.Error()
+		errMsg = &msg
+	}
+
+	_, err := s.db.Exec(`
+		UPDATE campaign_targets SET status = $2, letter_id = COALESCE($3, letter_id), error = $4
+		WHERE id = $1
+	`, id, outcome.Status, outcome.LetterID, errMsg)
+	if err != nil {
+		log.Printf("Warning: failed to update campaign target %d: %v", id, err)
+	}
+}
+
+func (s *Service) query(query string, args ...interface{}) ([]Campaign, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query campaigns: %w", err)
+	}
+	defer rows.Close()
+
+	var list []Campaign
+	for rows.Next() {
+		var c Campaign
+		var targetIDs pq.Int64Array
+		if err := rows.Scan(&c.ID, &c.UserID, &c.Name, &c.MainIssue, &c.SpecificConcern, &c.RequestedAction,
+			&c.TargetRule, &targetIDs, &c.ScheduledAt, &c.Status, &c.LastRunAt,
+			&c.CreatedAt, &c.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan campaign: %w", err)
+		}
+		for _, id := range targetIDs {
+			c.TargetIDs = append(c.TargetIDs, int(id))
+		}
+		list = append(list, c)
+	}
+
+	return list, rows.Err()
+}
+
+// SelectTargets applies a campaign's target rule to the constituent's
+// representatives.
+func SelectTargets(c *Campaign, rules *targeting.RuleSet, representatives []reps.Representative) ([]reps.Representative, error) {
+	var selected []reps.Representative
+
+	switch c.TargetRule {
+	case "", RuleAll:
+		return representatives, nil
+	case RuleSenators:
+		for _, rep := range representatives {
+			if targeting.Chamber(rep) == targeting.ChamberUpper {
+				selected = append(selected, rep)
+			}
+		}
+	case RuleHouse:
+		for _, rep := range representatives {
+			if targeting.Chamber(rep) == targeting.ChamberLower {
+				selected = append(selected, rep)
+			}
+		}
+	case RuleIDs:
+		wanted := make(map[int]bool, len(c.TargetIDs))
+		for _, id := range c.TargetIDs {
+			wanted[id] = true
+		}
+		for _, rep := range representatives {
+			if wanted[rep.ID] {
+				selected = append(selected, rep)
+			}
+		}
+	case RuleIssue:
+		return rules.Select(c.MainIssue, c.SpecificConcern, c.RequestedAction, representatives).Representatives, nil
+	default:
+		return nil, fmt.Errorf("unknown target rule %q (expected all, senators, house, ids or issue)", c.TargetRule)
+	}
+
+	return selected, nil
+}
+
+func toInt64s(ids []int) []int64 {
+	if ids == nil {
+		return nil
+	}
+	out := make([]int64, len(ids))
+	for i, id := range ids {
+		out[i] = int64(id)
+	}
+	return out
+}
@@ -0,0 +1,89 @@
+package campaigns
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/yourdatasucks/lettersmith/internal/reps"
+)
+
+const (
+	StatusDraft               = "draft"
+	StatusScheduled           = "scheduled"
+	StatusRunning             = "running"
+	StatusCompleted           = "completed"
+	StatusCompletedWithErrors = "completed_with_errors"
+
+	TargetPending = "pending"
+	TargetSent    = "sent"
+	TargetBlocked = "blocked"
+	TargetFailed  = "failed"
+)
+
+const (
+	RuleAll      = "all"
+	RuleSenators = "senators"
+	RuleHouse    = "house"
+	RuleIDs      = "ids"
+	// RuleIssue sends to every representative the targeting rules pick for
+	// the campaign's issue.
+	RuleIssue = "issue"
+)
+
+type Campaign struct {
+	ID              int        `json:"id"`
+	UserID          int        `json:"user_id"`
+	Name            string     `json:"name"`
+	MainIssue       string     `json:"main_issue"`
+	SpecificConcern string     `json:"specific_concern"`
+	RequestedAction string     `json:"requested_action"`
+	TargetRule      string     `json:"target_rule"`
+	TargetIDs       []int      `json:"target_ids,omitempty"`
+	ScheduledAt     *time.Time `json:"scheduled_at,omitempty"`
+	Status          string     `json:"status"`
+	LastRunAt       *time.Time `json:"last_run_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+type Target struct {
+	ID                  int       `json:"id"`
+	CampaignID          int       `json:"campaign_id"`
+	RepresentativeID    int       `json:"representative_id"`
+	RepresentativeName  string    `json:"representative_name"`
+	RepresentativeTitle string    `json:"representative_title"`
+	LetterID            *int      `json:"letter_id,omitempty"`
+	Status              string    `json:"status"`
+	Error               *string   `json:"error,omitempty"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+type Summary struct {
+	Campaign Campaign       `json:"campaign"`
+	Total    int            `json:"total"`
+	ByStatus map[string]int `json:"by_status"`
+	Targets  []Target       `json:"targets"`
+}
+
+// Outcome is what happened when a letter was written for one target.
+type Outcome struct {
+	LetterID *int
+	Status   string
+	Err      error
+}
+
+// Writer generates, checks and sends the letter for a single target. The
+// server supplies it so campaigns go through the same pipeline as letters
+// written from the UI.
+type Writer interface {
+	Write(ctx context.Context, c *Campaign, rep reps.Representative) Outcome
+}
+
+type Service struct {
+	db *sql.DB
+}
+
+func NewService(db *sql.DB) *Service {
+	return &Service{db: db}
+}
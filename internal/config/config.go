@@ -1,10 +1,13 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
+
+	"github.com/yourdatasucks/lettersmith/internal/secrets"
 )
 
 type Config struct {
@@ -16,8 +19,13 @@ type Config struct {
 	User            UserConfig
 	Scheduler       SchedulerConfig
 	Letter          LetterConfig
+	Auth            AuthConfig
+	Geocoding       GeocodingConfig
 	ZipDataUpdate   bool
 	CensusBureauURL string
+
+	// loadIssues are values that could not be parsed while loading.
+	loadIssues []Issue
 }
 
 type DatabaseConfig struct {
@@ -38,16 +46,22 @@ type AIConfig struct {
 	Provider  string
 	OpenAI    OpenAIConfig
 	Anthropic AnthropicConfig
+	Timeout   int
+	ProxyURL  string
+	// PromptDirectory holds on-disk prompt overrides, e.g. advocacy-prompt.txt
+	PromptDirectory string
 }
 
 type OpenAIConfig struct {
-	APIKey string
-	Model  string
+	APIKey  string
+	Model   string
+	BaseURL string
 }
 
 type AnthropicConfig struct {
-	APIKey string
-	Model  string
+	APIKey  string
+	Model   string
+	BaseURL string
 }
 
 type EmailConfig struct {
@@ -77,7 +91,44 @@ type MailgunConfig struct {
 }
 
 type RepresentativesConfig struct {
-	OpenStatesAPIKey string
+	OpenStatesAPIKey  string
+	OpenStatesBaseURL string
+	// OpenStatesCacheTTLHours is how long OpenStates responses are reused;
+	// 0 turns the cache off.
+	OpenStatesCacheTTLHours int
+	// SelectionMode is "ai" (the model picks from every representative) or
+	// "rules" (the targeting rules pick before generation).
+	SelectionMode      string
+	TargetingRulesFile string
+	// CongressLegislatorsFile is a local copy of the congress-legislators
+	// legislators-current dataset (YAML or JSON) to load members of Congress
+	// from.
+	CongressLegislatorsFile string
+	// SourcesFile lists representative sources besides OpenStates and the
+	// congress-legislators dataset, such as city council directories.
+	SourcesFile string
+	// SyncIntervalHours is how often the representatives of every user's
+	// location are synced in the background; 0 turns it off.
+	SyncIntervalHours int
+}
+
+// GeocodingConfig chooses how street addresses are turned into coordinates.
+type GeocodingConfig struct {
+	// AddressGeocoder is "census" (a Census Geocoder-compatible API at
+	// AddressGeocoderURL), "file" (a JSON file of known addresses) or "off".
+	AddressGeocoder     string
+	AddressGeocoderURL  string
+	AddressGeocoderFile string
+}
+
+type AuthConfig struct {
+	SessionTTLHours int
+	// SecureCookies marks session cookies Secure even when TLS is terminated
+	// by a proxy that does not set X-Forwarded-Proto.
+	SecureCookies bool
+	// BootstrapToken authorizes creating the first administrator. A random
+	// one is logged at startup when unset.
+	BootstrapToken string
 }
 
 type UserConfig struct {
@@ -99,6 +150,10 @@ type LetterConfig struct {
 	MaxLength        int
 	GenerationMethod string
 	TemplateConfig   *TemplateConfig
+	// SimilarityThreshold is the estimated Jaccard similarity at which a new
+	// letter counts as a near-duplicate of one already sent to the same office.
+	SimilarityThreshold float64
+	RegenerateAttempts  int
 }
 
 type TemplateConfig struct {
@@ -107,35 +162,75 @@ type TemplateConfig struct {
 	Personalize      bool
 }
 
+// Load reads the configuration from environment variables over the config
+// file. The server uses a Manager instead, which also layers in the env file
+// and saved settings.
+// A configuration with validation errors is returned with a
+// *ValidationError; warnings are left to the caller's Validate.
 func Load() (*Config, error) {
-	cfg := &Config{}
+	box, err := secrets.LoadBox(os.Getenv)
+	if err != nil {
+		return nil, err
+	}
+	configFile, err := ReadConfigFile(ConfigFile())
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := load(layers{configFile: configFile, box: box})
+	if err != nil {
+		return cfg, err
+	}
+	if issues := cfg.Validate(); HasErrors(issues) {
+		return cfg, &ValidationError{Issues: issues}
+	}
+	return cfg, nil
+}
 
-	loadFromEnv(cfg)
+// load builds a configuration from the layers. Secrets that cannot be read
+// or decrypted are reported and left unset.
+func load(l layers) (*Config, error) {
+	// Switches that are on unless turned off, matching their Settings
+	// defaults; setDefaults cannot tell false or 0 from unset.
+	cfg := &Config{
+		ZipDataUpdate:   true,
+		User:            UserConfig{SendCopyToSelf: true},
+		Scheduler:       SchedulerConfig{Enabled: true},
+		Representatives: RepresentativesConfig{SyncIntervalHours: 24, OpenStatesCacheTTLHours: 6},
+	}
+
+	var errs []error
+	loadFromEnv(cfg, func(key string) string {
+		value, _, err := l.lookup(key)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		return value
+	})
 
 	setDefaults(cfg)
 
-	return cfg, nil
+	return cfg, errors.Join(errs...)
 }
 
-func loadFromEnv(cfg *Config) {
-	if user := os.Getenv("POSTGRES_USER"); user != "" {
+func loadFromEnv(cfg *Config, getenv func(string) string) {
+	if user := getenv("POSTGRES_USER"); user != "" {
 		cfg.Database.User = user
 	}
-	if password := os.Getenv("POSTGRES_PASSWORD"); password != "" {
+	if password := getenv("POSTGRES_PASSWORD"); password != "" {
 		cfg.Database.Password = password
 	}
-	if db := os.Getenv("POSTGRES_DB"); db != "" {
+	if db := getenv("POSTGRES_DB"); db != "" {
 		cfg.Database.Name = db
 	}
-	if port := os.Getenv("POSTGRES_PORT"); port != "" {
-		if p, err := strconv.Atoi(port); err == nil {
-			cfg.Database.Port = p
-		}
+	if port, ok := cfg.parseInt(getenv, "POSTGRES_PORT"); ok {
+		cfg.Database.Port = port
 	}
 
-	if url := os.Getenv("DATABASE_URL"); url != "" {
+	if url := getenv("DATABASE_URL"); url != "" {
 		if parsed, err := parsePostgreSQLURL(url); err == nil {
 			cfg.Database = *parsed
+		} else {
+			cfg.addLoadIssue("DATABASE_URL", err.Error())
 		}
 	} else if cfg.Database.User != "" && cfg.Database.Password != "" && cfg.Database.Name != "" {
 		cfg.Database.Host = "localhost"
@@ -145,111 +240,149 @@ func loadFromEnv(cfg *Config) {
 		cfg.Database.SSLMode = "disable"
 	}
 
-	if port := os.Getenv("PORT"); port != "" {
-		if p, err := strconv.Atoi(port); err == nil {
-			cfg.Server.Port = p
-		}
+	if port, ok := cfg.parseInt(getenv, "PORT"); ok {
+		cfg.Server.Port = port
 	}
-	if host := os.Getenv("SERVER_HOST"); host != "" {
+	if host := getenv("SERVER_HOST"); host != "" {
 		cfg.Server.Host = host
 	}
 
-	if provider := os.Getenv("AI_PROVIDER"); provider != "" {
+	if provider := getenv("AI_PROVIDER"); provider != "" {
 		cfg.AI.Provider = provider
 	}
-	if apiKey := os.Getenv("OPENAI_API_KEY"); apiKey != "" {
+	if apiKey := getenv("OPENAI_API_KEY"); apiKey != "" {
 		cfg.AI.OpenAI.APIKey = apiKey
 	}
-	if model := os.Getenv("OPENAI_MODEL"); model != "" {
+	if model := getenv("OPENAI_MODEL"); model != "" {
 		cfg.AI.OpenAI.Model = model
 	}
-	if apiKey := os.Getenv("ANTHROPIC_API_KEY"); apiKey != "" {
+	if apiKey := getenv("ANTHROPIC_API_KEY"); apiKey != "" {
 		cfg.AI.Anthropic.APIKey = apiKey
 	}
-	if model := os.Getenv("ANTHROPIC_MODEL"); model != "" {
+	if model := getenv("ANTHROPIC_MODEL"); model != "" {
 		cfg.AI.Anthropic.Model = model
 	}
+	if baseURL := getenv("OPENAI_BASE_URL"); baseURL != "" {
+		cfg.AI.OpenAI.BaseURL = baseURL
+	}
+	if baseURL := getenv("ANTHROPIC_BASE_URL"); baseURL != "" {
+		cfg.AI.Anthropic.BaseURL = baseURL
+	}
+	if timeout, ok := cfg.parseInt(getenv, "AI_HTTP_TIMEOUT"); ok {
+		cfg.AI.Timeout = timeout
+	}
+	if proxy := getenv("AI_HTTP_PROXY"); proxy != "" {
+		cfg.AI.ProxyURL = proxy
+	}
+	if dir := getenv("PROMPT_TEMPLATE_DIRECTORY"); dir != "" {
+		cfg.AI.PromptDirectory = dir
+	}
 
-	if provider := os.Getenv("EMAIL_PROVIDER"); provider != "" {
+	if provider := getenv("EMAIL_PROVIDER"); provider != "" {
 		cfg.Email.Provider = provider
 	}
-	if host := os.Getenv("SMTP_HOST"); host != "" {
+	if host := getenv("SMTP_HOST"); host != "" {
 		cfg.Email.SMTP.Host = host
 	}
-	if port := os.Getenv("SMTP_PORT"); port != "" {
-		if p, err := strconv.Atoi(port); err == nil {
-			cfg.Email.SMTP.Port = p
-		}
+	if port, ok := cfg.parseInt(getenv, "SMTP_PORT"); ok {
+		cfg.Email.SMTP.Port = port
 	}
-	if username := os.Getenv("SMTP_USERNAME"); username != "" {
+	if username := getenv("SMTP_USERNAME"); username != "" {
 		cfg.Email.SMTP.Username = username
 		cfg.Email.SMTP.From = username
 	}
-	if password := os.Getenv("SMTP_PASSWORD"); password != "" {
+	if password := getenv("SMTP_PASSWORD"); password != "" {
 		cfg.Email.SMTP.Password = password
 	}
-	if from := os.Getenv("SMTP_FROM"); from != "" {
+	if from := getenv("SMTP_FROM"); from != "" {
 		cfg.Email.SMTP.From = from
 	}
 
-	if apiKey := os.Getenv("SENDGRID_API_KEY"); apiKey != "" {
+	if apiKey := getenv("SENDGRID_API_KEY"); apiKey != "" {
 		cfg.Email.SendGrid.APIKey = apiKey
-		if from := os.Getenv("SENDGRID_FROM"); from != "" {
+		if from := getenv("SENDGRID_FROM"); from != "" {
 			cfg.Email.SendGrid.From = from
 		}
 	}
 
-	if apiKey := os.Getenv("MAILGUN_API_KEY"); apiKey != "" {
+	if apiKey := getenv("MAILGUN_API_KEY"); apiKey != "" {
 		cfg.Email.Mailgun.APIKey = apiKey
 	}
-	if domain := os.Getenv("MAILGUN_DOMAIN"); domain != "" {
+	if domain := getenv("MAILGUN_DOMAIN"); domain != "" {
 		cfg.Email.Mailgun.Domain = domain
 		cfg.Email.Mailgun.From = fmt.Sprintf("lettersmith@%s", domain)
 	}
-	if from := os.Getenv("MAILGUN_FROM"); from != "" {
+	if from := getenv("MAILGUN_FROM"); from != "" {
 		cfg.Email.Mailgun.From = from
 	}
 
-	if apiKey := os.Getenv("OPENSTATES_API_KEY"); apiKey != "" {
+	if apiKey := getenv("OPENSTATES_API_KEY"); apiKey != "" {
 		cfg.Representatives.OpenStatesAPIKey = apiKey
 	}
+	if baseURL := getenv("OPENSTATES_BASE_URL"); baseURL != "" {
+		cfg.Representatives.OpenStatesBaseURL = baseURL
+	}
+	if hours, ok := cfg.parseInt(getenv, "OPENSTATES_CACHE_TTL_HOURS"); ok {
+		cfg.Representatives.OpenStatesCacheTTLHours = hours
+	}
+	if mode := getenv("REP_SELECTION_MODE"); mode != "" {
+		cfg.Representatives.SelectionMode = mode
+	}
+	if file := getenv("TARGETING_RULES_FILE"); file != "" {
+		cfg.Representatives.TargetingRulesFile = file
+	}
+	if file := getenv("CONGRESS_LEGISLATORS_FILE"); file != "" {
+		cfg.Representatives.CongressLegislatorsFile = file
+	}
+	if file := getenv("REP_SOURCES_FILE"); file != "" {
+		cfg.Representatives.SourcesFile = file
+	}
+	if hours, ok := cfg.parseInt(getenv, "REP_SYNC_INTERVAL_HOURS"); ok {
+		cfg.Representatives.SyncIntervalHours = hours
+	}
 
-	if name := os.Getenv("USER_NAME"); name != "" {
+	if name := getenv("USER_NAME"); name != "" {
 		cfg.User.Name = name
 	}
-	if email := os.Getenv("USER_EMAIL"); email != "" {
+	if email := getenv("USER_EMAIL"); email != "" {
 		cfg.User.Email = email
 	}
-	if zip := os.Getenv("USER_ZIP_CODE"); zip != "" {
+	if zip := getenv("USER_ZIP_CODE"); zip != "" {
 		cfg.User.ZipCode = zip
 	}
-	if sendCopy := os.Getenv("SEND_COPY_TO_SELF"); sendCopy != "" {
-		cfg.User.SendCopyToSelf = sendCopy == "true"
+	if sendCopy, ok := cfg.parseBool(getenv, "SEND_COPY_TO_SELF"); ok {
+		cfg.User.SendCopyToSelf = sendCopy
 	}
 
-	if sendTime := os.Getenv("SCHEDULER_SEND_TIME"); sendTime != "" {
+	if sendTime := getenv("SCHEDULER_SEND_TIME"); sendTime != "" {
 		cfg.Scheduler.SendTime = sendTime
 	}
-	if tz := os.Getenv("SCHEDULER_TIMEZONE"); tz != "" {
+	if tz := getenv("SCHEDULER_TIMEZONE"); tz != "" {
 		cfg.Scheduler.Timezone = tz
 	}
-	if enabled := os.Getenv("SCHEDULER_ENABLED"); enabled != "" {
-		cfg.Scheduler.Enabled = enabled == "true"
+	if enabled, ok := cfg.parseBool(getenv, "SCHEDULER_ENABLED"); ok {
+		cfg.Scheduler.Enabled = enabled
 	}
 
-	if tone := os.Getenv("LETTER_TONE"); tone != "" {
+	if tone := getenv("LETTER_TONE"); tone != "" {
 		cfg.Letter.Tone = tone
 	}
-	if maxLength := os.Getenv("LETTER_MAX_LENGTH"); maxLength != "" {
-		if length, err := strconv.Atoi(maxLength); err == nil {
-			cfg.Letter.MaxLength = length
-		}
+	if length, ok := cfg.parseInt(getenv, "LETTER_MAX_LENGTH"); ok {
+		cfg.Letter.MaxLength = length
 	}
-	if method := os.Getenv("LETTER_GENERATION_METHOD"); method != "" {
+	if method := getenv("LETTER_GENERATION_METHOD"); method != "" {
 		cfg.Letter.GenerationMethod = method
 	}
+	threshold, attempts := getenv("LETTER_SIMILARITY_THRESHOLD"), getenv("LETTER_REGENERATE_ATTEMPTS")
+	cfg.Letter.SimilarityThreshold, cfg.Letter.RegenerateAttempts = ParseSimilaritySettings(threshold, attempts)
+	if _, err := strconv.ParseFloat(threshold, 64); threshold != "" && err != nil {
+		cfg.addLoadIssue("LETTER_SIMILARITY_THRESHOLD", fmt.Sprintf("%q is not a number", threshold))
+	}
+	if _, err := strconv.Atoi(attempts); attempts != "" && err != nil {
+		cfg.addLoadIssue("LETTER_REGENERATE_ATTEMPTS", fmt.Sprintf("%q is not a whole number", attempts))
+	}
 
-	if themes := os.Getenv("LETTER_THEMES"); themes != "" {
+	if themes := getenv("LETTER_THEMES"); themes != "" {
 		cfg.Letter.Themes = strings.Split(themes, ",")
 
 		for i, theme := range cfg.Letter.Themes {
@@ -261,24 +394,94 @@ func loadFromEnv(cfg *Config) {
 		if cfg.Letter.TemplateConfig == nil {
 			cfg.Letter.TemplateConfig = &TemplateConfig{}
 		}
-		if dir := os.Getenv("TEMPLATE_DIRECTORY"); dir != "" {
+		if dir := getenv("TEMPLATE_DIRECTORY"); dir != "" {
 			cfg.Letter.TemplateConfig.Directory = dir
 		}
-		if strategy := os.Getenv("TEMPLATE_ROTATION_STRATEGY"); strategy != "" {
+		if strategy := getenv("TEMPLATE_ROTATION_STRATEGY"); strategy != "" {
 			cfg.Letter.TemplateConfig.RotationStrategy = strategy
 		}
-		if personalize := os.Getenv("TEMPLATE_PERSONALIZE"); personalize != "" {
-			cfg.Letter.TemplateConfig.Personalize = personalize == "true"
+		if personalize, ok := cfg.parseBool(getenv, "TEMPLATE_PERSONALIZE"); ok {
+			cfg.Letter.TemplateConfig.Personalize = personalize
 		}
 	}
 
-	if zipUpdate := os.Getenv("ZIP_DATA_UPDATE"); zipUpdate != "" {
-		cfg.ZipDataUpdate = zipUpdate == "true"
+	if hours, ok := cfg.parseInt(getenv, "AUTH_SESSION_TTL_HOURS"); ok {
+		cfg.Auth.SessionTTLHours = hours
+	}
+	if secure, ok := cfg.parseBool(getenv, "AUTH_SECURE_COOKIES"); ok {
+		cfg.Auth.SecureCookies = secure
+	}
+	if token := getenv("AUTH_BOOTSTRAP_TOKEN"); token != "" {
+		cfg.Auth.BootstrapToken = token
 	}
 
-	if censusBureauURL := os.Getenv("CENSUS_BUREAU_URL"); censusBureauURL != "" {
+	if zipUpdate, ok := cfg.parseBool(getenv, "ZIP_DATA_UPDATE"); ok {
+		cfg.ZipDataUpdate = zipUpdate
+	}
+
+	if censusBureauURL := getenv("CENSUS_BUREAU_URL"); censusBureauURL != "" {
 		cfg.CensusBureauURL = censusBureauURL
 	}
+
+	if geocoder := getenv("ADDRESS_GEOCODER"); geocoder != "" {
+		cfg.Geocoding.AddressGeocoder = geocoder
+	}
+	if geocoderURL := getenv("ADDRESS_GEOCODER_URL"); geocoderURL != "" {
+		cfg.Geocoding.AddressGeocoderURL = geocoderURL
+	}
+	if geocoderFile := getenv("ADDRESS_GEOCODER_FILE"); geocoderFile != "" {
+		cfg.Geocoding.AddressGeocoderFile = geocoderFile
+	}
+}
+
+// parseInt returns the integer value of key. A value that is set but not a
+// whole number is recorded as an issue and ignored.
+func (cfg *Config) parseInt(getenv func(string) string, key string) (int, bool) {
+	value := getenv(key)
+	if value == "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		cfg.addLoadIssue(key, fmt.Sprintf("%q is not a whole number", value))
+		return 0, false
+	}
+	return n, true
+}
+
+// parseBool returns the boolean value of key. A value that is set but not
+// true or false is recorded as an issue and ignored.
+func (cfg *Config) parseBool(getenv func(string) string, key string) (bool, bool) {
+	value := getenv(key)
+	if value == "" {
+		return false, false
+	}
+	b, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		cfg.addLoadIssue(key, fmt.Sprintf("%q is not true or false", value))
+		return false, false
+	}
+	return b, true
+}
+
+func (cfg *Config) addLoadIssue(key, message string) {
+	cfg.loadIssues = append(cfg.loadIssues, Issue{Key: key, Severity: SeverityError, Message: message})
+}
+
+// ParseSimilaritySettings parses the near-duplicate threshold and the number
+// of regeneration attempts, falling back to 0.8 and 2 when unset or invalid.
+func ParseSimilaritySettings(threshold, attempts string) (float64, int) {
+	t, err := strconv.ParseFloat(threshold, 64)
+	if err != nil || t <= 0 || t > 1 {
+		t = 0.8
+	}
+
+	a, err := strconv.Atoi(attempts)
+	if err != nil || a < 0 {
+		a = 2
+	}
+
+	return t, a
 }
 
 func parsePostgreSQLURL(url string) (*DatabaseConfig, error) {
@@ -402,6 +605,32 @@ func setDefaults(cfg *Config) {
 			Personalize:      true,
 		}
 	}
+	if cfg.Letter.TemplateConfig != nil {
+		if cfg.Letter.TemplateConfig.Directory == "" {
+			cfg.Letter.TemplateConfig.Directory = "templates/"
+		}
+		if cfg.Letter.TemplateConfig.RotationStrategy == "" {
+			cfg.Letter.TemplateConfig.RotationStrategy = "random-unique"
+		}
+	}
+
+	if cfg.Auth.SessionTTLHours <= 0 {
+		cfg.Auth.SessionTTLHours = 24 * 7
+	}
+
+	if cfg.Representatives.SelectionMode == "" {
+		cfg.Representatives.SelectionMode = "ai"
+	}
+	if cfg.Representatives.OpenStatesBaseURL == "" {
+		cfg.Representatives.OpenStatesBaseURL = "https://v3.openstates.org"
+	}
+
+	if cfg.Geocoding.AddressGeocoder == "" {
+		cfg.Geocoding.AddressGeocoder = "census"
+	}
+	if cfg.Geocoding.AddressGeocoderURL == "" {
+		cfg.Geocoding.AddressGeocoderURL = "https://geocoding.geo.census.gov/geocoder"
+	}
 
 	if cfg.AI.OpenAI.Model == "" {
 		cfg.AI.OpenAI.Model = "gpt-4"
@@ -409,10 +638,6 @@ func setDefaults(cfg *Config) {
 	if cfg.AI.Anthropic.Model == "" {
 		cfg.AI.Anthropic.Model = "claude-3-sonnet-20240229"
 	}
-
-	if cfg.ZipDataUpdate == false {
-		cfg.ZipDataUpdate = true
-	}
 }
 
 func (cfg *Config) DatabaseURL() string {
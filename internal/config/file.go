@@ -0,0 +1,293 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvFile returns the path of the env file, set by ENV_FILE.
+func EnvFile() string {
+	if path := os.Getenv("ENV_FILE"); path != "" {
+		return path
+	}
+	return ".env"
+}
+
+// ConfigFile returns the path of the YAML config file, set by CONFIG_FILE.
+func ConfigFile() string {
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		return path
+	}
+	return "lettersmith.yaml"
+}
+
+// fileField maps a config file path to the variable it sets and to where its
+// effective value lives in Config.
+type fileField struct {
+	path  string
+	key   string
+	value func(*Config) interface{}
+}
+
+// fileFields lists every config file field in the order config print shows
+// them.
+var fileFields = []fileField{
+	{"server.host", "SERVER_HOST", func(c *Config) interface{} { return c.Server.Host }},
+	{"server.port", "PORT", func(c *Config) interface{} { return c.Server.Port }},
+
+	{"database.url", "DATABASE_URL", func(c *Config) interface{} { return c.DatabaseURL() }},
+	{"database.user", "POSTGRES_USER", func(c *Config) interface{} { return c.Database.User }},
+	{"database.password", "POSTGRES_PASSWORD", func(c *Config) interface{} { return c.Database.Password }},
+	{"database.name", "POSTGRES_DB", func(c *Config) interface{} { return c.Database.Name }},
+	{"database.port", "POSTGRES_PORT", func(c *Config) interface{} { return c.Database.Port }},
+	{"database.zip_data_update", "ZIP_DATA_UPDATE", func(c *Config) interface{} { return c.ZipDataUpdate }},
+	{"database.census_bureau_url", "CENSUS_BUREAU_URL", func(c *Config) interface{} { return c.CensusBureauURL }},
+
+	{"geocoding.address_geocoder", "ADDRESS_GEOCODER", func(c *Config) interface{} { return c.Geocoding.AddressGeocoder }},
+	{"geocoding.address_geocoder_url", "ADDRESS_GEOCODER_URL", func(c *Config) interface{} { return c.Geocoding.AddressGeocoderURL }},
+	{"geocoding.address_geocoder_file", "ADDRESS_GEOCODER_FILE", func(c *Config) interface{} { return c.Geocoding.AddressGeocoderFile }},
+
+	{"auth.session_ttl_hours", "AUTH_SESSION_TTL_HOURS", func(c *Config) interface{} { return c.Auth.SessionTTLHours }},
+	{"auth.secure_cookies", "AUTH_SECURE_COOKIES", func(c *Config) interface{} { return c.Auth.SecureCookies }},
+	{"auth.bootstrap_token", "AUTH_BOOTSTRAP_TOKEN", func(c *Config) interface{} { return c.Auth.BootstrapToken }},
+
+	{"user.name", "USER_NAME", func(c *Config) interface{} { return c.User.Name }},
+	{"user.email", "USER_EMAIL", func(c *Config) interface{} { return c.User.Email }},
+	{"user.zip_code", "USER_ZIP_CODE", func(c *Config) interface{} { return c.User.ZipCode }},
+	{"user.send_copy_to_self", "SEND_COPY_TO_SELF", func(c *Config) interface{} { return c.User.SendCopyToSelf }},
+
+	{"ai.provider", "AI_PROVIDER", func(c *Config) interface{} { return c.AI.Provider }},
+	{"ai.timeout", "AI_HTTP_TIMEOUT", func(c *Config) interface{} { return c.AI.Timeout }},
+	{"ai.proxy", "AI_HTTP_PROXY", func(c *Config) interface{} { return c.AI.ProxyURL }},
+	{"ai.prompt_directory", "PROMPT_TEMPLATE_DIRECTORY", func(c *Config) interface{} { return c.AI.PromptDirectory }},
+	{"ai.openai.api_key", "OPENAI_API_KEY", func(c *Config) interface{} { return c.AI.OpenAI.APIKey }},
+	{"ai.openai.model", "OPENAI_MODEL", func(c *Config) interface{} { return c.AI.OpenAI.Model }},
+	{"ai.openai.base_url", "OPENAI_BASE_URL", func(c *Config) interface{} { return c.AI.OpenAI.BaseURL }},
+	{"ai.anthropic.api_key", "ANTHROPIC_API_KEY", func(c *Config) interface{} { return c.AI.Anthropic.APIKey }},
+	{"ai.anthropic.model", "ANTHROPIC_MODEL", func(c *Config) interface{} { return c.AI.Anthropic.Model }},
+	{"ai.anthropic.base_url", "ANTHROPIC_BASE_URL", func(c *Config) interface{} { return c.AI.Anthropic.BaseURL }},
+
+	{"email.provider", "EMAIL_PROVIDER", func(c *Config) interface{} { return c.Email.Provider }},
+	{"email.smtp.host", "SMTP_HOST", func(c *Config) interface{} { return c.Email.SMTP.Host }},
+	{"email.smtp.port", "SMTP_PORT", func(c *Config) interface{} { return c.Email.SMTP.Port }},
+	{"email.smtp.username", "SMTP_USERNAME", func(c *Config) interface{} { return c.Email.SMTP.Username }},
+	{"email.smtp.password", "SMTP_PASSWORD", func(c *Config) interface{} { return c.Email.SMTP.Password }},
+	{"email.smtp.from", "SMTP_FROM", func(c *Config) interface{} { return c.Email.SMTP.From }},
+	{"email.sendgrid.api_key", "SENDGRID_API_KEY", func(c *Config) interface{} { return c.Email.SendGrid.APIKey }},
+	{"email.sendgrid.from", "SENDGRID_FROM", func(c *Config) interface{} { return c.Email.SendGrid.From }},
+	{"email.mailgun.api_key", "MAILGUN_API_KEY", func(c *Config) interface{} { return c.Email.Mailgun.APIKey }},
+	{"email.mailgun.domain", "MAILGUN_DOMAIN", func(c *Config) interface{} { return c.Email.Mailgun.Domain }},
+	{"email.mailgun.from", "MAILGUN_FROM", func(c *Config) interface{} { return c.Email.Mailgun.From }},
+
+	{"representatives.openstates_api_key", "OPENSTATES_API_KEY", func(c *Config) interface{} { return c.Representatives.OpenStatesAPIKey }},
+	{"representatives.openstates_base_url", "OPENSTATES_BASE_URL", func(c *Config) interface{} { return c.Representatives.OpenStatesBaseURL }},
+	{"representatives.openstates_cache_ttl_hours", "OPENSTATES_CACHE_TTL_HOURS", func(c *Config) interface{} { return c.Representatives.OpenStatesCacheTTLHours }},
+	{"representatives.selection_mode", "REP_SELECTION_MODE", func(c *Config) interface{} { return c.Representatives.SelectionMode }},
+	{"representatives.targeting_rules_file", "TARGETING_RULES_FILE", func(c *Config) interface{} { return c.Representatives.TargetingRulesFile }},
+	{"representatives.congress_legislators_file", "CONGRESS_LEGISLATORS_FILE", func(c *Config) interface{} { return c.Representatives.CongressLegislatorsFile }},
+	{"representatives.sources_file", "REP_SOURCES_FILE", func(c *Config) interface{} { return c.Representatives.SourcesFile }},
+	{"representatives.sync_interval_hours", "REP_SYNC_INTERVAL_HOURS", func(c *Config) interface{} { return c.Representatives.SyncIntervalHours }},
+
+	{"scheduler.enabled", "SCHEDULER_ENABLED", func(c *Config) interface{} { return c.Scheduler.Enabled }},
+	{"scheduler.send_time", "SCHEDULER_SEND_TIME", func(c *Config) interface{} { return c.Scheduler.SendTime }},
+	{"scheduler.timezone", "SCHEDULER_TIMEZONE", func(c *Config) interface{} { return c.Scheduler.Timezone }},
+
+	{"letter.generation_method", "LETTER_GENERATION_METHOD", func(c *Config) interface{} { return c.Letter.GenerationMethod }},
+	{"letter.tone", "LETTER_TONE", func(c *Config) interface{} { return c.Letter.Tone }},
+	{"letter.max_length", "LETTER_MAX_LENGTH", func(c *Config) interface{} { return c.Letter.MaxLength }},
+	{"letter.themes", "LETTER_THEMES", func(c *Config) interface{} { return c.Letter.Themes }},
+	{"letter.similarity_threshold", "LETTER_SIMILARITY_THRESHOLD", func(c *Config) interface{} { return c.Letter.SimilarityThreshold }},
+	{"letter.regenerate_attempts", "LETTER_REGENERATE_ATTEMPTS", func(c *Config) interface{} { return c.Letter.RegenerateAttempts }},
+	{"letter.templates.directory", "TEMPLATE_DIRECTORY", templateField(func(t *TemplateConfig) interface{} { return t.Directory })},
+	{"letter.templates.rotation_strategy", "TEMPLATE_ROTATION_STRATEGY", templateField(func(t *TemplateConfig) interface{} { return t.RotationStrategy })},
+	{"letter.templates.personalize", "TEMPLATE_PERSONALIZE", templateField(func(t *TemplateConfig) interface{} { return t.Personalize })},
+}
+
+// templateField reads a template setting, which only exists when letters are
+// generated from templates.
+func templateField(get func(*TemplateConfig) interface{}) func(*Config) interface{} {
+	return func(c *Config) interface{} {
+		if c.Letter.TemplateConfig == nil {
+			return nil
+		}
+		return get(c.Letter.TemplateConfig)
+	}
+}
+
+func lookupFileField(path string) (fileField, bool) {
+	for _, f := range fileFields {
+		if f.path == path {
+			return f, true
+		}
+	}
+	return fileField{}, false
+}
+
+// ReadConfigFile parses a YAML config file into values keyed by the
+// variables they set. Lists become comma-separated values, and a secret's
+// field may instead be given with a _file suffix naming the file to read it
+// from. Unknown fields are errors, so typos do not go unnoticed. A missing
+// file yields no values.
+func ReadConfigFile(path string) (map[string]string, error) {
+	values := make(map[string]string)
+	if path == "" {
+		return values, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return values, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if err := flattenConfigFile(doc, "", values); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return values, nil
+}
+
+func flattenConfigFile(section map[string]interface{}, prefix string, values map[string]string) error {
+	for name, raw := range section {
+		path := prefix + name
+		if raw == nil {
+			continue
+		}
+		if nested, ok := raw.(map[string]interface{}); ok {
+			if err := flattenConfigFile(nested, path+".", values); err != nil {
+				return err
+			}
+			continue
+		}
+
+		key, err := configFileKey(path)
+		if err != nil {
+			return err
+		}
+		value, err := configFileValue(path, raw)
+		if err != nil {
+			return err
+		}
+		values[key] = value
+	}
+	return nil
+}
+
+// configFileKey returns the variable a config file field sets.
+func configFileKey(path string) (string, error) {
+	if field, ok := lookupFileField(path); ok {
+		return field.key, nil
+	}
+	if base := strings.TrimSuffix(path, "_file"); base != path {
+		if field, ok := lookupFileField(base); ok && IsSecret(field.key) {
+			return field.key + "_FILE", nil
+		}
+	}
+	return "", fmt.Errorf("unknown field %s", path)
+}
+
+func configFileValue(path string, raw interface{}) (string, error) {
+	switch v := raw.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := configFileValue(path, item)
+			if err != nil {
+				return "", err
+			}
+			if strings.Contains(s, ",") {
+				return "", fmt.Errorf("%s: items may not contain commas", path)
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("%s: unsupported value %v", path, raw)
+	}
+}
+
+// WriteYAML writes the configuration in effect in the config file format.
+// Each value is annotated with where it came from, and secrets are masked.
+func (m *Manager) WriteYAML(w io.Writer) error {
+	snap := m.current.Load()
+
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	for _, field := range fileFields {
+		value := field.value(snap.config)
+		if value == nil {
+			continue
+		}
+
+		node, err := valueNode(value)
+		if err != nil {
+			return err
+		}
+		if IsSecret(field.key) && node.Value != "" {
+			node = &yaml.Node{Kind: yaml.ScalarNode, Value: "[REDACTED]"}
+		}
+
+		_, source, _ := snap.layers.lookup(field.key)
+		if source == "" {
+			source = SourceDefault
+		}
+		node.LineComment = source
+
+		insertNode(root, strings.Split(field.path, "."), node)
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func valueNode(value interface{}) (*yaml.Node, error) {
+	node := &yaml.Node{}
+	if err := node.Encode(value); err != nil {
+		return nil, err
+	}
+	if node.Kind == yaml.SequenceNode {
+		node.Style = yaml.FlowStyle
+	}
+	return node, nil
+}
+
+// insertNode adds value to a mapping node at path, creating the mappings in
+// between.
+func insertNode(mapping *yaml.Node, path []string, value *yaml.Node) {
+	for i := 0; i < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == path[0] {
+			insertNode(mapping.Content[i+1], path[1:], value)
+			return
+		}
+	}
+
+	key := &yaml.Node{Kind: yaml.ScalarNode, Value: path[0]}
+	if len(path) == 1 {
+		mapping.Content = append(mapping.Content, key, value)
+		return
+	}
+	child := &yaml.Node{Kind: yaml.MappingNode}
+	mapping.Content = append(mapping.Content, key, child)
+	insertNode(child, path[1:], value)
+}
@@ -0,0 +1,310 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Manager holds the current configuration and swaps in a new one when the
+// env file, the config file or the saved settings change, or on SIGHUP.
+// Readers never block: Current returns whatever configuration was last
+// loaded in full.
+type Manager struct {
+	store      *SettingsStore
+	envFile    string
+	configFile string
+
+	current atomic.Pointer[snapshot]
+
+	// mu serializes reloads and guards everything below.
+	mu              sync.Mutex
+	subscribers     []subscriber
+	fileModTimes    [2]time.Time
+	settingsVersion string
+}
+
+type snapshot struct {
+	config   *Config
+	settings []SettingValue
+	layers   layers
+}
+
+type subscriber struct {
+	name string
+	fn   func(*Config)
+}
+
+// NewManager returns a manager loaded from the environment, the env file and
+// the config file. Call Reload to layer the saved settings on top and to
+// learn of any errors.
+func NewManager(store *SettingsStore, envFile, configFile string) *Manager {
+	m := &Manager{store: store, envFile: envFile, configFile: configFile}
+
+	l, _ := m.readFiles(nil)
+	cfg, _ := load(l)
+	m.current.Store(&snapshot{config: cfg, settings: resolveSettings(l), layers: l})
+
+	return m
+}
+
+// Current returns the configuration in effect. It must not be modified.
+func (m *Manager) Current() *Config {
+	return m.current.Load().config
+}
+
+// Settings returns every runtime setting with its effective value and source.
+func (m *Manager) Settings() []SettingValue {
+	return m.current.Load().settings
+}
+
+// Values returns the effective value of every runtime setting keyed by name.
+func (m *Manager) Values() map[string]string {
+	settings := m.Settings()
+	values := make(map[string]string, len(settings))
+	for _, s := range settings {
+		values[s.Key] = s.Value
+	}
+	return values
+}
+
+// Subscribe registers fn to be called with the new configuration after every
+// reload. Subscribers run one at a time while the reload holds its lock, so
+// they should only rebuild what they derive from the configuration and must
+// not call back into Reload, Save or Reset.
+func (m *Manager) Subscribe(name string, fn func(*Config)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subscribers = append(m.subscribers, subscriber{name: name, fn: fn})
+}
+
+// Reload reads the env file and saved settings again, swaps in the result
+// and notifies subscribers. The previous configuration stays in effect when
+// the settings cannot be read, a secret cannot be decrypted or the result
+// has validation errors.
+func (m *Manager) Reload() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.reload()
+}
+
+// Save validates and saves runtime settings, then reloads. Nothing is saved
+// if the resulting configuration would have validation errors.
+func (m *Manager) Save(values map[string]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.check(values); err != nil {
+		return err
+	}
+	if err := m.store.Set(values); err != nil {
+		return err
+	}
+	return m.reload()
+}
+
+// Reset removes a saved setting, then reloads. Nothing is removed if the
+// value underneath would have validation errors.
+func (m *Manager) Reset(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.check(map[string]string{key: ""}); err != nil {
+		return err
+	}
+	if err := m.store.Reset(key); err != nil {
+		return err
+	}
+	return m.reload()
+}
+
+// check validates the configuration that saving changes would produce. An
+// empty value stands for removing the saved setting.
+func (m *Manager) check(changes map[string]string) error {
+	stored, err := m.store.Stored()
+	if err != nil {
+		return err
+	}
+	l, err := m.readFiles(stored)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range changes {
+		setting, ok := LookupSetting(key)
+		if !ok {
+			return fmt.Errorf("unknown setting %s", key)
+		}
+		if value, err = setting.Normalize(value); err != nil {
+			return err
+		}
+		if value == "" {
+			delete(stored, key)
+		} else {
+			stored[key] = value
+		}
+	}
+
+	cfg, err := load(l)
+	if err != nil {
+		return err
+	}
+	if issues := cfg.Validate(); HasErrors(issues) {
+		return &ValidationError{Issues: issues}
+	}
+	return nil
+}
+
+func (m *Manager) reload() error {
+	version, err := m.store.Version()
+	if err != nil {
+		return err
+	}
+	stored, err := m.store.Stored()
+	if err != nil {
+		return err
+	}
+	l, err := m.readFiles(stored)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := load(l)
+	if err != nil {
+		return err
+	}
+	if issues := cfg.Validate(); HasErrors(issues) {
+		return &ValidationError{Issues: issues}
+	}
+	m.current.Store(&snapshot{config: cfg, settings: resolveSettings(l), layers: l})
+	m.settingsVersion = version
+	m.fileModTimes = m.modTimes()
+
+	for _, s := range m.subscribers {
+		s.fn(cfg)
+	}
+	return nil
+}
+
+// readFiles reads the env and config files and layers them under stored.
+func (m *Manager) readFiles(stored map[string]string) (layers, error) {
+	envFile, err := ReadEnvFile(m.envFile)
+	if err != nil {
+		return layers{}, err
+	}
+	configFile, err := ReadConfigFile(m.configFile)
+	if err != nil {
+		return layers{}, err
+	}
+	return layers{stored: stored, envFile: envFile, configFile: configFile, box: m.store.box}, nil
+}
+
+func (m *Manager) modTimes() [2]time.Time {
+	return [2]time.Time{modTime(m.envFile), modTime(m.configFile)}
+}
+
+// changed reports whether either file or the saved settings differ from
+// what was last loaded.
+func (m *Manager) changed() (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, t := range m.modTimes() {
+		if !t.Equal(m.fileModTimes[i]) {
+			return true, nil
+		}
+	}
+	version, err := m.store.Version()
+	if err != nil {
+		return false, err
+	}
+	return version != m.settingsVersion, nil
+}
+
+// Watch reloads on SIGHUP and whenever a check every interval finds a file
+// or the saved settings changed, until ctx is done. Errors are passed to
+// report and the previous configuration stays in effect.
+func (m *Manager) Watch(ctx context.Context, interval time.Duration, report func(error)) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			if err := m.Reload(); err != nil {
+				report(fmt.Errorf("reload on SIGHUP failed: %w", err))
+			}
+		case <-ticker.C:
+			changed, err := m.changed()
+			if err != nil {
+				report(err)
+				continue
+			}
+			if changed {
+				if err := m.Reload(); err != nil {
+					report(fmt.Errorf("reload failed: %w", err))
+				}
+			}
+		}
+	}
+}
+
+// ReadEnvFile parses a dotenv-style file of KEY=value lines. A missing file
+// yields no values.
+func ReadEnvFile(path string) (map[string]string, error) {
+	values := make(map[string]string)
+	if path == "" {
+		return values, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return values, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(strings.TrimPrefix(parts[0], "export "))
+		value := strings.TrimSpace(parts[1])
+		if len(value) > 1 && ((value[0] == '"' && value[len(value)-1] == '"') || (value[0] == '\'' && value[len(value)-1] == '\'')) {
+			value = value[1 : len(value)-1]
+		}
+		values[key] = value
+	}
+
+	return values, nil
+}
+
+func modTime(path string) time.Time {
+	if path == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
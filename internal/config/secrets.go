@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/yourdatasucks/lettersmith/internal/secrets"
+)
+
+// environmentSecrets are secrets that are not runtime settings. Runtime
+// settings mark theirs with Secret.
+var environmentSecrets = []string{"POSTGRES_PASSWORD", "DATABASE_URL", "AUTH_BOOTSTRAP_TOKEN", "SECRETS_MASTER_KEY"}
+
+// IsSecret reports whether key holds a secret: one that is encrypted when
+// saved, may be read from a KEY_FILE, and is never returned or logged.
+func IsSecret(key string) bool {
+	if setting, ok := LookupSetting(key); ok {
+		return setting.Secret
+	}
+	return containsString(environmentSecrets, key)
+}
+
+// layers are the places a value can come from. Saved settings take
+// precedence over the env file, then the environment, then the config file.
+type layers struct {
+	stored     map[string]string
+	envFile    map[string]string
+	configFile map[string]string
+	box        *secrets.Box
+}
+
+// lookup resolves key through the layers and reports where the value came
+// from. A secret that is not set directly is read from the file named by
+// KEY_FILE, as Docker secrets are mounted, and encrypted values are
+// decrypted.
+func (l layers) lookup(key string) (string, string, error) {
+	value, source := l.layered(key)
+
+	if value == "" && IsSecret(key) {
+		if path, pathSource := l.layered(key + "_FILE"); path != "" {
+			secret, err := secrets.ReadFile(path)
+			if err != nil {
+				return "", "", fmt.Errorf("failed to read %s_FILE: %w", key, err)
+			}
+			value, source = secret, pathSource
+		}
+	}
+
+	value, err := l.box.Decrypt(key, value)
+	if err != nil {
+		return "", "", err
+	}
+	return value, source, nil
+}
+
+// layered returns the first value set for key and its source. A key present
+// but empty in the env file still hides the layers below it.
+func (l layers) layered(key string) (string, string) {
+	if value, ok := l.stored[key]; ok {
+		return value, SourceDatabase
+	}
+	if value, ok := l.envFile[key]; ok {
+		return value, SourceFile
+	}
+	if value := os.Getenv(key); value != "" {
+		return value, SourceEnvironment
+	}
+	if value, ok := l.configFile[key]; ok {
+		return value, SourceConfigFile
+	}
+	return "", ""
+}
+
+// SecretValues returns every secret in the configuration, so that they can
+// be masked wherever output might include them.
+func (cfg *Config) SecretValues() []string {
+	return []string{
+		cfg.Database.Password,
+		cfg.AI.OpenAI.APIKey,
+		cfg.AI.Anthropic.APIKey,
+		cfg.Email.SMTP.Password,
+		cfg.Email.SendGrid.APIKey,
+		cfg.Email.Mailgun.APIKey,
+		cfg.Representatives.OpenStatesAPIKey,
+		cfg.Auth.BootstrapToken,
+	}
+}
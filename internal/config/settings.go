@@ -0,0 +1,242 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SettingType describes how a setting's value is parsed and validated.
+type SettingType string
+
+const (
+	TypeString SettingType = "string"
+	TypeInt    SettingType = "int"
+	TypeFloat  SettingType = "float"
+	TypeBool   SettingType = "bool"
+	TypeList   SettingType = "list"
+)
+
+// Where a setting's effective value came from.
+const (
+	SourceDefault     = "default"
+	SourceConfigFile  = "config_file"
+	SourceEnvironment = "environment"
+	SourceFile        = "file"
+	SourceDatabase    = "database"
+)
+
+// Setting is a runtime setting that can be changed without a restart. Keys
+// match the environment variables they override.
+type Setting struct {
+	Key         string      `json:"key"`
+	Type        SettingType `json:"type"`
+	Default     string      `json:"default,omitempty"`
+	Options     []string    `json:"options,omitempty"`
+	Secret      bool        `json:"secret,omitempty"`
+	Description string      `json:"description"`
+	min, max    float64
+	check       func(string) error
+}
+
+// SettingValue is a setting together with its effective value.
+type SettingValue struct {
+	Setting
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// Settings lists every runtime setting. Database and server settings are not
+// here: they are needed before the database is reachable and only change on
+// restart.
+var Settings = []Setting{
+	{Key: "ZIP_DATA_UPDATE", Type: TypeBool, Default: "true", Description: "Download Census ZIP code data at startup"},
+	{Key: "CENSUS_BUREAU_URL", Type: TypeString, Description: "Override the Census Bureau gazetteer download URL"},
+
+	{Key: "USER_NAME", Type: TypeString, Description: "Default name offered when creating the first administrator"},
+	{Key: "USER_EMAIL", Type: TypeString, Description: "Default email offered when creating the first administrator"},
+	{Key: "USER_ZIP_CODE", Type: TypeString, Description: "Default ZIP code offered when creating the first administrator", check: checkZipCode},
+	{Key: "SEND_COPY_TO_SELF", Type: TypeBool, Default: "true", Description: "Send a copy of each letter to its author"},
+
+	{Key: "AI_PROVIDER", Type: TypeString, Options: []string{"openai", "anthropic"}, Description: "AI provider used to write letters"},
+	{Key: "OPENAI_API_KEY", Type: TypeString, Secret: true, Description: "OpenAI API key"},
+	{Key: "OPENAI_MODEL", Type: TypeString, Default: "gpt-4", Description: "OpenAI model"},
+	{Key: "OPENAI_BASE_URL", Type: TypeString, Description: "OpenAI-compatible API endpoint", check: checkURL},
+	{Key: "ANTHROPIC_API_KEY", Type: TypeString, Secret: true, Description: "Anthropic API key"},
+	{Key: "ANTHROPIC_MODEL", Type: TypeString, Default: "claude-3-sonnet-20240229", Description: "Anthropic model"},
+	{Key: "ANTHROPIC_BASE_URL", Type: TypeString, Description: "Anthropic-compatible API endpoint", check: checkURL},
+	{Key: "AI_HTTP_TIMEOUT", Type: TypeInt, min: 1, max: 600, Description: "Seconds to wait for the AI provider (default 60)"},
+	{Key: "AI_HTTP_PROXY", Type: TypeString, Description: "HTTP proxy for AI provider requests", check: checkURL},
+	{Key: "PROMPT_TEMPLATE_DIRECTORY", Type: TypeString, Description: "Directory with prompt overrides"},
+
+	{Key: "EMAIL_PROVIDER", Type: TypeString, Options: []string{"smtp", "sendgrid", "mailgun"}, Description: "Email delivery provider"},
+	{Key: "SMTP_HOST", Type: TypeString, Description: "SMTP server host"},
+	{Key: "SMTP_PORT", Type: TypeInt, min: 1, max: 65535, Description: "SMTP server port"},
+	{Key: "SMTP_USERNAME", Type: TypeString, Description: "SMTP username"},
+	{Key: "SMTP_PASSWORD", Type: TypeString, Secret: true, Description: "SMTP password"},
+	{Key: "SMTP_FROM", Type: TypeString, Description: "SMTP sender address"},
+	{Key: "SENDGRID_API_KEY", Type: TypeString, Secret: true, Description: "SendGrid API key"},
+	{Key: "SENDGRID_FROM", Type: TypeString, Description: "SendGrid sender address"},
+	{Key: "MAILGUN_API_KEY", Type: TypeString, Secret: true, Description: "Mailgun API key"},
+	{Key: "MAILGUN_DOMAIN", Type: TypeString, Description: "Mailgun sending domain"},
+	{Key: "MAILGUN_FROM", Type: TypeString, Description: "Mailgun sender address"},
+
+	{Key: "OPENSTATES_API_KEY", Type: TypeString, Secret: true, Description: "OpenStates API key"},
+	{Key: "OPENSTATES_BASE_URL", Type: TypeString, Default: "https://v3.openstates.org", Description: "OpenStates v3-compatible API endpoint", check: checkURL},
+	{Key: "OPENSTATES_CACHE_TTL_HOURS", Type: TypeInt, Default: "6", min: 0, max: 168, Description: "Hours OpenStates responses are reused (0 disables the cache)"},
+	{Key: "REP_SELECTION_MODE", Type: TypeString, Default: "ai", Options: []string{"ai", "rules"}, Description: "How representatives are chosen for a letter"},
+	{Key: "TARGETING_RULES_FILE", Type: TypeString, Description: "JSON targeting rules overriding the built-in defaults"},
+	{Key: "CONGRESS_LEGISLATORS_FILE", Type: TypeString, Description: "congress-legislators YAML or JSON file with the members of Congress"},
+	{Key: "REP_SOURCES_FILE", Type: TypeString, Description: "JSON file listing extra representative sources: CSV/JSON files and JSON APIs"},
+	{Key: "REP_SYNC_INTERVAL_HOURS", Type: TypeInt, Default: "24", min: 0, max: 720, Description: "Hours between background syncs of every user's representatives (0 disables)"},
+
+	{Key: "ADDRESS_GEOCODER", Type: TypeString, Default: "census", Options: []string{"census", "file", "off"}, Description: "How street addresses are geocoded"},
+	{Key: "ADDRESS_GEOCODER_URL", Type: TypeString, Default: "https://geocoding.geo.census.gov/geocoder", Description: "Census Geocoder-compatible API for street addresses", check: checkURL},
+	{Key: "ADDRESS_GEOCODER_FILE", Type: TypeString, Description: "JSON file of addresses and coordinates, for ADDRESS_GEOCODER=file"},
+
+	{Key: "SCHEDULER_SEND_TIME", Type: TypeString, Default: "09:00", Description: "Daily send time (HH:MM)", check: checkClock},
+	{Key: "SCHEDULER_TIMEZONE", Type: TypeString, Default: "America/Los_Angeles", Description: "Time zone for the daily send time", check: checkTimezone},
+	{Key: "SCHEDULER_ENABLED", Type: TypeBool, Default: "true", Description: "Send scheduled letters"},
+
+	{Key: "LETTER_GENERATION_METHOD", Type: TypeString, Default: "ai", Options: []string{"ai", "templates"}, Description: "How letters are written"},
+	{Key: "LETTER_TONE", Type: TypeString, Default: "professional", Description: "Default letter tone"},
+	{Key: "LETTER_MAX_LENGTH", Type: TypeInt, Default: "500", min: 50, max: 2000, Description: "Default maximum letter length in words"},
+	{Key: "LETTER_THEMES", Type: TypeList, Default: "data privacy protection,consumer rights,corporate accountability,transparent data practices", Description: "Comma-separated letter themes"},
+	{Key: "LETTER_SIMILARITY_THRESHOLD", Type: TypeFloat, Default: "0.8", min: 0.01, max: 1, Description: "Similarity (0-1) at which a letter counts as a near-duplicate"},
+	{Key: "LETTER_REGENERATE_ATTEMPTS", Type: TypeInt, Default: "2", min: 0, max: 10, Description: "Regeneration attempts for near-duplicate letters"},
+	{Key: "TEMPLATE_DIRECTORY", Type: TypeString, Default: "templates/", Description: "Directory of letter templates"},
+	{Key: "TEMPLATE_ROTATION_STRATEGY", Type: TypeString, Default: "random-unique", Options: []string{"random-unique", "sequential", "random"}, Description: "Order in which templates are used"},
+	{Key: "TEMPLATE_PERSONALIZE", Type: TypeBool, Default: "true", Description: "Personalize templates with user details"},
+}
+
+// LookupSetting returns the runtime setting with the given key.
+func LookupSetting(key string) (Setting, bool) {
+	for _, s := range Settings {
+		if s.Key == key {
+			return s, true
+		}
+	}
+	return Setting{}, false
+}
+
+// Normalize validates a value for the setting and returns it in canonical
+// form. An empty value is always valid and means "not set".
+func (s Setting) Normalize(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+
+	switch s.Type {
+	case TypeInt:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return "", fmt.Errorf("%s must be a whole number", s.Key)
+		}
+		if err := s.checkRange(float64(n)); err != nil {
+			return "", err
+		}
+		value = strconv.Itoa(n)
+	case TypeFloat:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", fmt.Errorf("%s must be a number", s.Key)
+		}
+		if err := s.checkRange(f); err != nil {
+			return "", err
+		}
+		value = strconv.FormatFloat(f, 'f', -1, 64)
+	case TypeBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("%s must be true or false", s.Key)
+		}
+		value = strconv.FormatBool(b)
+	case TypeList:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		value = strings.Join(items, ",")
+	}
+
+	if len(s.Options) > 0 && !containsString(s.Options, value) {
+		return "", fmt.Errorf("%s must be one of: %s", s.Key, strings.Join(s.Options, ", "))
+	}
+	if s.check != nil {
+		if err := s.check(value); err != nil {
+			return "", fmt.Errorf("%s: %w", s.Key, err)
+		}
+	}
+
+	return value, nil
+}
+
+func (s Setting) checkRange(n float64) error {
+	if s.min == 0 && s.max == 0 {
+		return nil
+	}
+	if n < s.min || n > s.max {
+		return fmt.Errorf("%s must be between %s and %s", s.Key,
+			strconv.FormatFloat(s.min, 'f', -1, 64), strconv.FormatFloat(s.max, 'f', -1, 64))
+	}
+	return nil
+}
+
+// resolveSettings returns every setting with its effective value and
+// source, falling back to the defaults. Secrets are decrypted; a secret that
+// cannot be read is shown as unset.
+func resolveSettings(l layers) []SettingValue {
+	values := make([]SettingValue, 0, len(Settings))
+	for _, s := range Settings {
+		v := SettingValue{Setting: s, Value: s.Default, Source: SourceDefault}
+		if value, source, err := l.lookup(s.Key); err == nil && source != "" {
+			v.Value, v.Source = value, source
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+func checkZipCode(value string) error {
+	if len(value) != 5 {
+		return fmt.Errorf("must be a 5-digit ZIP code")
+	}
+	if _, err := strconv.Atoi(value); err != nil {
+		return fmt.Errorf("must be a 5-digit ZIP code")
+	}
+	return nil
+}
+
+func checkURL(value string) error {
+	if !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
+		return fmt.Errorf("must be an http:// or https:// URL")
+	}
+	return nil
+}
+
+func checkClock(value string) error {
+	if _, err := time.Parse("15:04", value); err != nil {
+		return fmt.Errorf("must be a time in HH:MM format")
+	}
+	return nil
+}
+
+func checkTimezone(value string) error {
+	if _, err := time.LoadLocation(value); err != nil {
+		return fmt.Errorf("unknown time zone %q", value)
+	}
+	return nil
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
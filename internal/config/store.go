@@ -0,0 +1,166 @@
+package config
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"github.com/yourdatasucks/lettersmith/internal/secrets"
+)
+
+// SettingsStore keeps runtime settings in the configurations table, where
+// they override the environment. Secrets are saved encrypted with box.
+type SettingsStore struct {
+	db  *sql.DB
+	box *secrets.Box
+}
+
+func NewSettingsStore(db *sql.DB, box *secrets.Box) *SettingsStore {
+	return &SettingsStore{db: db, box: box}
+}
+
+// Stored returns the settings saved in the database keyed by name. Secrets
+// are returned as saved, encrypted.
+func (s *SettingsStore) Stored() (map[string]string, error) {
+	rows, err := s.db.Query(`SELECT key, value FROM configurations WHERE key = ANY($1)`, pq.Array(settingKeys()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load settings: %w", err)
+	}
+	defer rows.Close()
+
+	stored := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan setting: %w", err)
+		}
+		stored[key] = value
+	}
+	return stored, rows.Err()
+}
+
+// Version summarizes the saved settings so a change can be noticed without
+// loading them all.
+func (s *SettingsStore) Version() (string, error) {
+	var count int
+	var latest sql.NullTime
+	err := s.db.QueryRow(`
+		SELECT COUNT(*), MAX(updated_at) FROM configurations WHERE key = ANY($1)
+	`, pq.Array(settingKeys())).Scan(&count, &latest)
+	if err != nil {
+		return "", fmt.Errorf("failed to check settings: %w", err)
+	}
+	return fmt.Sprintf("%d:%d", count, latest.Time.UnixNano()), nil
+}
+
+// Set validates and saves settings. An empty value removes the saved
+// setting so the environment or default applies again. Secrets are
+// encrypted, and cannot be saved without a master key. Nothing is saved
+// unless every value is valid.
+func (s *SettingsStore) Set(values map[string]string) error {
+	normalized := make(map[string]string, len(values))
+	for key, value := range values {
+		setting, ok := LookupSetting(key)
+		if !ok {
+			return fmt.Errorf("unknown setting %s", key)
+		}
+		v, err := setting.Normalize(value)
+		if err != nil {
+			return err
+		}
+		if setting.Secret && v != "" {
+			if v, err = s.encrypt(key, v); err != nil {
+				return err
+			}
+		}
+		normalized[key] = v
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for key, value := range normalized {
+		if value == "" {
+			_, err = tx.Exec(`DELETE FROM configurations WHERE key = $1`, key)
+		} else {
+			setting, _ := LookupSetting(key)
+			_, err = tx.Exec(`
+				INSERT INTO configurations (key, value, description)
+				VALUES ($1, $2, $3)
+				ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value
+			`, key, value, setting.Description)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save setting %s: %w", key, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save settings: %w", err)
+	}
+	return nil
+}
+
+// Reset removes a saved setting.
+func (s *SettingsStore) Reset(key string) error {
+	if _, ok := LookupSetting(key); !ok {
+		return fmt.Errorf("unknown setting %s", key)
+	}
+	if _, err := s.db.Exec(`DELETE FROM configurations WHERE key = $1`, key); err != nil {
+		return fmt.Errorf("failed to reset setting %s: %w", key, err)
+	}
+	return nil
+}
+
+// EncryptStored encrypts secrets saved before a master key was configured and
+// returns how many it encrypted.
+func (s *SettingsStore) EncryptStored() (int, error) {
+	stored, err := s.Stored()
+	if err != nil {
+		return 0, err
+	}
+
+	plaintext := make(map[string]string)
+	for key, value := range stored {
+		if IsSecret(key) && !secrets.IsEncrypted(value) {
+			plaintext[key] = value
+		}
+	}
+	if len(plaintext) == 0 {
+		return 0, nil
+	}
+
+	if err := s.Set(plaintext); err != nil {
+		return 0, err
+	}
+	return len(plaintext), nil
+}
+
+func (s *SettingsStore) encrypt(key, value string) (string, error) {
+	if secrets.IsEncrypted(value) {
+		// Already encrypted, e.g. copied from an env file: keep it only if
+		// it decrypts with this key.
+		if _, err := s.box.Decrypt(key, value); err != nil {
+			return "", err
+		}
+		return value, nil
+	}
+
+	encrypted, err := s.box.Encrypt(key, value)
+	if err != nil {
+		return "", fmt.Errorf("cannot save %s: %w", key, err)
+	}
+	return encrypted, nil
+}
+
+func settingKeys() []string {
+	keys := make([]string, len(Settings))
+	for i, setting := range Settings {
+		keys[i] = setting.Key
+	}
+	return keys
+}
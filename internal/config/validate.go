@@ -0,0 +1,162 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Severity says whether an issue stops the server from starting.
+type Severity string
+
+const (
+	// SeverityError marks a configuration the server refuses to run with.
+	SeverityError Severity = "error"
+	// SeverityWarning marks a configuration that runs but is likely wrong.
+	SeverityWarning Severity = "warning"
+)
+
+// Issue is one problem found by Validate, keyed by the environment variable
+// or setting that causes it.
+type Issue struct {
+	Key      string   `json:"key"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s: %s", i.Key, i.Message)
+}
+
+// ValidationError is returned when a configuration has errors.
+type ValidationError struct {
+	Issues []Issue
+}
+
+func (e *ValidationError) Error() string {
+	var messages []string
+	for _, issue := range e.Issues {
+		if issue.Severity == SeverityError {
+			messages = append(messages, issue.String())
+		}
+	}
+	return "invalid configuration: " + strings.Join(messages, "; ")
+}
+
+// HasErrors reports whether any issue is an error.
+func HasErrors(issues []Issue) bool {
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// modelPrefixes are the model name prefixes each provider's own API serves.
+// Compatible endpoints set through a base URL may serve any name.
+var modelPrefixes = map[string][]string{
+	"openai":    {"gpt-", "o1", "o3", "o4", "chatgpt-"},
+	"anthropic": {"claude-"},
+}
+
+// Validate checks the configuration and returns every issue found, including
+// values that could not be parsed while loading. Unset values are not issues:
+// what must be configured before letters can be sent is reported by the
+// system status instead.
+func (cfg *Config) Validate() []Issue {
+	issues := append([]Issue{}, cfg.loadIssues...)
+	add := func(key string, severity Severity, format string, args ...interface{}) {
+		issues = append(issues, Issue{Key: key, Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+
+	checkPort := func(key string, port int) {
+		if port < 1 || port > 65535 {
+			add(key, SeverityError, "port %d is out of range (1-65535)", port)
+		}
+	}
+	checkPort("PORT", cfg.Server.Port)
+	checkPort("POSTGRES_PORT", cfg.Database.Port)
+	if cfg.Email.SMTP.Port != 0 {
+		checkPort("SMTP_PORT", cfg.Email.SMTP.Port)
+	}
+
+	checkOption := func(key, value string) {
+		setting, _ := LookupSetting(key)
+		if value != "" && !containsString(setting.Options, value) {
+			add(key, SeverityError, "%q is not one of: %s", value, strings.Join(setting.Options, ", "))
+		}
+	}
+	checkOption("AI_PROVIDER", cfg.AI.Provider)
+	checkOption("EMAIL_PROVIDER", cfg.Email.Provider)
+	checkOption("LETTER_GENERATION_METHOD", cfg.Letter.GenerationMethod)
+	checkOption("REP_SELECTION_MODE", cfg.Representatives.SelectionMode)
+	checkOption("ADDRESS_GEOCODER", cfg.Geocoding.AddressGeocoder)
+	if cfg.Letter.TemplateConfig != nil {
+		checkOption("TEMPLATE_ROTATION_STRATEGY", cfg.Letter.TemplateConfig.RotationStrategy)
+	}
+
+	checkRange := func(key string, n int) {
+		setting, _ := LookupSetting(key)
+		if err := setting.checkRange(float64(n)); err != nil {
+			add(key, SeverityError, "%d is out of range (%g-%g)", n, setting.min, setting.max)
+		}
+	}
+	checkRange("LETTER_MAX_LENGTH", cfg.Letter.MaxLength)
+	checkRange("REP_SYNC_INTERVAL_HOURS", cfg.Representatives.SyncIntervalHours)
+	checkRange("OPENSTATES_CACHE_TTL_HOURS", cfg.Representatives.OpenStatesCacheTTLHours)
+	if cfg.AI.Timeout != 0 {
+		checkRange("AI_HTTP_TIMEOUT", cfg.AI.Timeout)
+	}
+
+	if _, err := time.LoadLocation(cfg.Scheduler.Timezone); err != nil {
+		add("SCHEDULER_TIMEZONE", SeverityError, "unknown time zone %q", cfg.Scheduler.Timezone)
+	}
+	if _, err := time.Parse("15:04", cfg.Scheduler.SendTime); err != nil {
+		add("SCHEDULER_SEND_TIME", SeverityError, "%q is not a time in HH:MM format", cfg.Scheduler.SendTime)
+	}
+
+	switch cfg.AI.Provider {
+	case "openai":
+		checkModel(add, "OPENAI_MODEL", cfg.AI.Provider, cfg.AI.OpenAI.Model, cfg.AI.OpenAI.BaseURL)
+	case "anthropic":
+		checkModel(add, "ANTHROPIC_MODEL", cfg.AI.Provider, cfg.AI.Anthropic.Model, cfg.AI.Anthropic.BaseURL)
+	}
+
+	if cfg.Letter.GenerationMethod == "templates" && cfg.Letter.TemplateConfig != nil {
+		checkDirectory(add, "TEMPLATE_DIRECTORY", cfg.Letter.TemplateConfig.Directory)
+	}
+	if cfg.Geocoding.AddressGeocoder == "file" && cfg.Geocoding.AddressGeocoderFile == "" {
+		add("ADDRESS_GEOCODER_FILE", SeverityError, "must be set when ADDRESS_GEOCODER is file")
+	}
+	if cfg.AI.PromptDirectory != "" {
+		checkDirectory(add, "PROMPT_TEMPLATE_DIRECTORY", cfg.AI.PromptDirectory)
+	}
+
+	return issues
+}
+
+func checkModel(add func(string, Severity, string, ...interface{}), key, provider, model, baseURL string) {
+	if baseURL != "" {
+		return
+	}
+	for _, prefix := range modelPrefixes[provider] {
+		if strings.HasPrefix(model, prefix) {
+			return
+		}
+	}
+	add(key, SeverityWarning, "%q is not a known %s model name", model, provider)
+}
+
+func checkDirectory(add func(string, Severity, string, ...interface{}), key, dir string) {
+	info, err := os.Stat(dir)
+	switch {
+	case os.IsNotExist(err):
+		add(key, SeverityWarning, "directory %s does not exist", dir)
+	case err != nil:
+		add(key, SeverityWarning, "cannot read directory %s: %v", dir, err)
+	case !info.IsDir():
+		add(key, SeverityWarning, "%s is not a directory", dir)
+	}
+}
@@ -0,0 +1,201 @@
+package geocoding
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrAddressNotFound is returned when a geocoder has no match for an address.
+var ErrAddressNotFound = errors.New("address not found")
+
+// AddressMatch is where a street address was found.
+type AddressMatch struct {
+	MatchedAddress string  `json:"matched_address"`
+	Latitude       float64 `json:"latitude"`
+	Longitude      float64 `json:"longitude"`
+	State          string  `json:"state"`
+	ZipCode        string  `json:"zip_code"`
+}
+
+// AddressGeocoder turns a one-line street address into coordinates.
+type AddressGeocoder interface {
+	GeocodeAddress(ctx context.Context, address string) (*AddressMatch, error)
+}
+
+// CensusAddressGeocoder uses the Census Geocoder's one-line address
+// endpoint, or any API compatible with it, at BaseURL.
+type CensusAddressGeocoder struct {
+	BaseURL string
+	client  *http.Client
+}
+
+func NewCensusAddressGeocoder(baseURL string) *CensusAddressGeocoder {
+	return &CensusAddressGeocoder{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type censusAddressResponse struct {
+	Result struct {
+		AddressMatches []struct {
+			MatchedAddress string `json:"matchedAddress"`
+			Coordinates    struct {
+				X float64 `json:"x"`
+				Y float64 `json:"y"`
+			} `json:"coordinates"`
+			AddressComponents struct {
+				State string `json:"state"`
+				Zip   string `json:"zip"`
+			} `json:"addressComponents"`
+		} `json:"addressMatches"`
+	} `json:"result"`
+}
+
+func (g *CensusAddressGeocoder) GeocodeAddress(ctx context.Context, address string) (*AddressMatch, error) {
+	params := url.Values{}
+	params.Add("address", address)
+	params.Add("benchmark", "Public_AR_Current")
+	params.Add("format", "json")
+
+	req, err := http.NewRequestWithContext(ctx, "GET", g.BaseURL+"/locations/onelineaddress?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "Lettersmith/1.0")
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call address geocoder: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("address geocoder returned status %d", resp.StatusCode)
+	}
+
+	var result censusAddressResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode address geocoder response: %w", err)
+	}
+
+	if len(result.Result.AddressMatches) == 0 {
+		return nil, ErrAddressNotFound
+	}
+	match := result.Result.AddressMatches[0]
+	return &AddressMatch{
+		MatchedAddress: match.MatchedAddress,
+		Latitude:       match.Coordinates.Y,
+		Longitude:      match.Coordinates.X,
+		State:          match.AddressComponents.State,
+		ZipCode:        match.AddressComponents.Zip,
+	}, nil
+}
+
+// FileAddressGeocoder answers from a JSON file mapping addresses to matches,
+// for running without network access and in tests. Addresses are compared
+// like the cache keys: ignoring case, punctuation and repeated spaces.
+type FileAddressGeocoder struct {
+	matches map[string]AddressMatch
+}
+
+func LoadFileAddressGeocoder(path string) (*FileAddressGeocoder, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read address file: %w", err)
+	}
+
+	var matches map[string]AddressMatch
+	if err := json.Unmarshal(data, &matches); err != nil {
+		return nil, fmt.Errorf("invalid address file %s: %w", path, err)
+	}
+
+	g := &FileAddressGeocoder{matches: make(map[string]AddressMatch, len(matches))}
+	for address, match := range matches {
+		if match.MatchedAddress == "" {
+			match.MatchedAddress = address
+		}
+		g.matches[NormalizeAddress(address)] = match
+	}
+	return g, nil
+}
+
+func (g *FileAddressGeocoder) GeocodeAddress(ctx context.Context, address string) (*AddressMatch, error) {
+	match, ok := g.matches[NormalizeAddress(address)]
+	if !ok {
+		return nil, ErrAddressNotFound
+	}
+	return &match, nil
+}
+
+// NormalizeAddress reduces an address to the form used as its cache key.
+func NormalizeAddress(address string) string {
+	address = strings.ToUpper(address)
+	address = strings.Map(func(r rune) rune {
+		if r == ',' || r == '.' || r == '#' {
+			return ' '
+		}
+		return r
+	}, address)
+	return strings.Join(strings.Fields(address), " ")
+}
+
+// CachedAddressGeocoder remembers the matches of another geocoder in the
+// address_geocodes table, so that each address is only looked up once.
+// Addresses that were not found are not cached.
+type CachedAddressGeocoder struct {
+	db      *sql.DB
+	backend AddressGeocoder
+}
+
+func NewCachedAddressGeocoder(db *sql.DB, backend AddressGeocoder) *CachedAddressGeocoder {
+	return &CachedAddressGeocoder{db: db, backend: backend}
+}
+
+func (g *CachedAddressGeocoder) GeocodeAddress(ctx context.Context, address string) (*AddressMatch, error) {
+	key := NormalizeAddress(address)
+	if key == "" {
+		return nil, ErrAddressNotFound
+	}
+
+	var match AddressMatch
+	err := g.db.QueryRowContext(ctx, `
+		SELECT matched_address, latitude, longitude, state, zip_code
+		FROM address_geocodes WHERE address = $1
+	`, key).Scan(&match.MatchedAddress, &match.Latitude, &match.Longitude, &match.State, &match.ZipCode)
+	if err == nil {
+		return &match, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	found, err := g.backend.GeocodeAddress(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = g.db.ExecContext(ctx, `
+		INSERT INTO address_geocodes (address, matched_address, latitude, longitude, state, zip_code)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (address) DO UPDATE SET
+			matched_address = EXCLUDED.matched_address,
+			latitude = EXCLUDED.latitude,
+			longitude = EXCLUDED.longitude,
+			state = EXCLUDED.state,
+			zip_code = EXCLUDED.zip_code,
+			created_at = CURRENT_TIMESTAMP
+	`, key, found.MatchedAddress, found.Latitude, found.Longitude, found.State, found.ZipCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cache geocoded address: %w", err)
+	}
+	return found, nil
+}
@@ -0,0 +1,93 @@
+package geocoding
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/yourdatasucks/lettersmith/internal/reps"
+)
+
+const censusGeographiesURL = "https://geocoding.geo.census.gov/geocoder/geographies/coordinates"
+
+type censusGeographiesResponse struct {
+	Result struct {
+		Geographies map[string][]struct {
+			GEOID string `json:"GEOID"`
+		} `json:"geographies"`
+	} `json:"result"`
+}
+
+// GetCongressionalDistrict returns the congressional district containing the
+// ZIP code's centroid, such as "12", or reps.AtLargeDistrict for at-large
+// states and territories. The Census Geocoder is asked once per ZIP code and
+// the answer cached in zip_coordinates.
+func (zg *ZipGeocoder) GetCongressionalDistrict(zipCode string) (string, error) {
+	coords, err := zg.GetCoordinates(zipCode)
+	if err != nil {
+		return "", err
+	}
+	zipCode = zipCode[:5]
+
+	var cached sql.NullString
+	err = zg.db.QueryRow("SELECT congressional_district FROM zip_coordinates WHERE zip_code = $1", zipCode).Scan(&cached)
+	if err != nil {
+		return "", fmt.Errorf("database error: %w", err)
+	}
+	if cached.Valid {
+		return cached.String, nil
+	}
+
+	district, err := lookupCongressionalDistrict(coords.Latitude, coords.Longitude)
+	if err != nil {
+		return "", fmt.Errorf("failed to find congressional district for ZIP %s: %w", zipCode, err)
+	}
+
+	_, err = zg.db.Exec("UPDATE zip_coordinates SET congressional_district = $1 WHERE zip_code = $2", district, zipCode)
+	if err != nil {
+		return "", fmt.Errorf("failed to store congressional district: %w", err)
+	}
+	return district, nil
+}
+
+// lookupCongressionalDistrict asks the Census Geocoder which congressional
+// district contains the point.
+func lookupCongressionalDistrict(latitude, longitude float64) (string, error) {
+	params := url.Values{}
+	params.Add("x", fmt.Sprintf("%.6f", longitude))
+	params.Add("y", fmt.Sprintf("%.6f", latitude))
+	params.Add("benchmark", "Public_AR_Current")
+	params.Add("vintage", "Current_Current")
+	params.Add("layers", "all")
+	params.Add("format", "json")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(censusGeographiesURL + "?" + params.Encode())
+	if err != nil {
+		return "", fmt.Errorf("failed to call Census Geocoder: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("census geocoder returned status %d", resp.StatusCode)
+	}
+
+	var result censusGeographiesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode Census Geocoder response: %w", err)
+	}
+
+	// The layer is named after the current Congress, e.g. "119th
+	// Congressional Districts".
+	for layer, districts := range result.Result.Geographies {
+		if !strings.HasSuffix(layer, "Congressional Districts") || len(districts) == 0 {
+			continue
+		}
+		return reps.CongressionalDistrictFromGEOID(districts[0].GEOID)
+	}
+	return "", fmt.Errorf("no congressional district at %.6f, %.6f", latitude, longitude)
+}
@@ -2,10 +2,15 @@ package geocoding
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 )
 
+// ErrZipNotFound is returned by GetCoordinates for a malformed ZIP code or
+// one without coordinates.
+var ErrZipNotFound = errors.New("ZIP code not found")
+
 type Coordinates struct {
 	Latitude  float64
 	Longitude float64
@@ -43,7 +48,7 @@ func (zg *ZipGeocoder) GetCoordinates(zipCode string) (*Coordinates, error) {
 		zipCode = zipCode[:5]
 	}
 	if len(zipCode) != 5 {
-		return nil, fmt.Errorf("invalid ZIP code format: %s", zipCode)
+		return nil, fmt.Errorf("%w: invalid ZIP code format %q", ErrZipNotFound, zipCode)
 	}
 
 	var coords Coordinates
@@ -61,7 +66,7 @@ func (zg *ZipGeocoder) GetCoordinates(zipCode string) (*Coordinates, error) {
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("ZIP code %s not found", zipCode)
+		return nil, fmt.Errorf("%w: %s", ErrZipNotFound, zipCode)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("database error: %w", err)
@@ -125,6 +130,11 @@ func (zg *ZipGeocoder) LoadZipData() error {
 	return nil
 }
 
-
-
-
+func (zg *ZipGeocoder) GetCoordinatesForDisplay(zipCode string) string {
+	coords, err := zg.GetCoordinates(zipCode)
+	if err != nil {
+		return fmt.Sprintf("Error: %v", err)
+	}
+	return fmt.Sprintf("ZIP %s: %s, %s (%.6f, %.6f)",
+		zipCode, coords.City, coords.State, coords.Latitude, coords.Longitude)
+}
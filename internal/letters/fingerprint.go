@@ -0,0 +1,142 @@
+package letters
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+const (
+	shingleSize   = 4
+	signatureSize = 128
+
+	// fingerprintHistory bounds how many past letters to one representative
+	// a new letter is compared against.
+	fingerprintHistory = 500
+)
+
+// Signature is a MinHash signature over word shingles. The fraction of
+// positions two signatures share estimates the Jaccard similarity of the
+// letters' shingle sets.
+type Signature []int64
+
+// Match is a previously sent letter that a new letter is too similar to.
+type Match struct {
+	LetterID   int     `json:"letter_id"`
+	Similarity float64 `json:"similarity"`
+}
+
+var wordPattern = regexp.MustCompile(`[a-z0-9']+`)
+
+var minhashSeeds = func() []uint64 {
+	seeds := make([]uint64, signatureSize)
+	for i := range seeds {
+		seeds[i] = mix64(uint64(i + 1))
+	}
+	return seeds
+}()
+
+func Fingerprint(content string) Signature {
+	words := wordPattern.FindAllString(strings.ToLower(content), -1)
+
+	var shingles []string
+	if len(words) < shingleSize {
+		shingles = []string{strings.Join(words, " ")}
+	} else {
+		for i := 0; i+shingleSize <= len(words); i++ {
+			shingles = append(shingles, strings.Join(words[i:i+shingleSize], " "))
+		}
+	}
+
+	mins := make([]uint64, signatureSize)
+	for i := range mins {
+		mins[i] = ^uint64(0)
+	}
+
+	for _, shingle := range shingles {
+		h := fnv.New64a()
+		h.Write([]byte(shingle))
+		base := h.Sum64()
+		for i, seed := range minhashSeeds {
+			if v := mix64(base ^ seed); v < mins[i] {
+				mins[i] = v
+			}
+		}
+	}
+
+	sig := make(Signature, signatureSize)
+	for i, v := range mins {
+		sig[i] = int64(v)
+	}
+	return sig
+}
+
+func Similarity(a, b Signature) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	same := 0
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(a))
+}
+
+// mix64 is the splitmix64 finalizer; it turns one base hash into many
+// independent-looking hash functions.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+func (s *Store) SaveFingerprint(letterID, representativeID int, sig Signature) error {
+	_, err := s.db.Exec(`
+		INSERT INTO letter_fingerprints (letter_id, representative_id, signature)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (letter_id) DO UPDATE SET signature = EXCLUDED.signature
+	`, letterID, representativeID, pq.Int64Array(sig))
+	if err != nil {
+		return fmt.Errorf("failed to save letter fingerprint: %w", err)
+	}
+	return nil
+}
+
+// FindSimilar returns the most similar letter already sent to the
+// representative whose similarity is at least threshold, or nil.
+func (s *Store) FindSimilar(representativeID int, sig Signature, threshold float64) (*Match, error) {
+	rows, err := s.db.Query(`
+		SELECT letter_id, signature FROM letter_fingerprints
+		WHERE representative_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`, representativeID, fingerprintHistory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query letter fingerprints: %w", err)
+	}
+	defer rows.Close()
+
+	var best *Match
+	for rows.Next() {
+		var letterID int
+		var stored pq.Int64Array
+		if err := rows.Scan(&letterID, &stored); err != nil {
+			return nil, fmt.Errorf("failed to scan letter fingerprint: %w", err)
+		}
+
+		score := Similarity(sig, Signature(stored))
+		if score >= threshold && (best == nil || score > best.Similarity) {
+			best = &Match{LetterID: letterID, Similarity: score}
+		}
+	}
+
+	return best, rows.Err()
+}
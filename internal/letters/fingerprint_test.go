@@ -0,0 +1,80 @@
+package letters
+
+import (
+	"strings"
+	"testing"
+)
+
+const letter = `Dear Senator Doe,
+
+I am writing as a constituent in Springfield about the sale of personal data by brokers who
+collect location histories, purchase records and browsing habits without meaningful consent.
+Last year my family received dozens of calls from companies that knew our address, our children's
+school and the clinic we visit, and none of them would say where they had bought that information.
+
+Illinois residents deserve the right to see what brokers hold about them, to have it deleted on
+request, and to know before it is sold. I ask you to cosponsor the Data Broker Accountability Act,
+to require brokers to register with the state, and to fund enforcement so that deletion requests are
+honored within thirty days rather than ignored.
+
+Thank you for your time and for your work on behalf of families across our state.
+
+Sincerely,
+Jane Smith`
+
+const unrelated = `Dear Council Member Lee,
+
+Our neighborhood park has had broken lights along the east walking path since early spring, and
+the playground fence near Maple Street is leaning into the sidewalk. Parents who walk their kids to
+the bus stop in the morning now cross the street to avoid it. Please ask the parks department to
+schedule repairs before winter, and consider adding a crosswalk at the corner of Maple and Fifth
+where drivers rarely slow down.
+
+Best regards,
+Sam Rivera`
+
+func TestSimilarity(t *testing.T) {
+	edited := strings.Replace(letter, "dozens of calls", "many calls", 1)
+	edited = strings.Replace(edited, "thirty days", "a month", 1)
+
+	tests := []struct {
+		name     string
+		a, b     string
+		min, max float64
+	}{
+		{"identical", letter, letter, 1, 1},
+		{"case and punctuation only", letter, strings.ToUpper(strings.ReplaceAll(letter, ",", "")), 1, 1},
+		{"lightly edited", letter, edited, 0.8, 1},
+		{"unrelated", letter, unrelated, 0, 0.05},
+		{"short identical", "Stop data sales", "stop data sales!", 1, 1},
+		{"short different", "Stop data sales", "Fix the park", 0, 0},
+		{"short and long", "Stop data sales", letter, 0, 0.05},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Similarity(Fingerprint(tt.a), Fingerprint(tt.b))
+			if got < tt.min || got > tt.max {
+				t.Errorf("Similarity = %.3f, want between %.2f and %.2f", got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestFingerprintSize(t *testing.T) {
+	for _, content := range []string{"", "one", letter} {
+		if sig := Fingerprint(content); len(sig) != signatureSize {
+			t.Errorf("Fingerprint(%.10q) has %d values, want %d", content, len(sig), signatureSize)
+		}
+	}
+}
+
+func TestSimilarityMismatchedSignatures(t *testing.T) {
+	sig := Fingerprint(letter)
+	if got := Similarity(sig, sig[:10]); got != 0 {
+		t.Errorf("Similarity of signatures of different sizes = %v, want 0", got)
+	}
+	if got := Similarity(nil, nil); got != 0 {
+		t.Errorf("Similarity of empty signatures = %v, want 0", got)
+	}
+}
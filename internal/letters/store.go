@@ -0,0 +1,131 @@
+package letters
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type Letter struct {
+	ID               int        `json:"id"`
+	UserID           *int       `json:"user_id,omitempty"`
+	RepresentativeID int        `json:"representative_id"`
+	Subject          string     `json:"subject"`
+	Content          string     `json:"content"`
+	AIProvider       string     `json:"ai_provider"`
+	AIModel          string     `json:"ai_model"`
+	Theme            string     `json:"theme"`
+	Tone             string     `json:"tone"`
+	PromptTemplateID *int       `json:"prompt_template_id,omitempty"`
+	PromptVersion    *string    `json:"prompt_version,omitempty"`
+	SentAt           *time.Time `json:"sent_at,omitempty"`
+	EmailProvider    *string    `json:"email_provider,omitempty"`
+	EmailStatus      string     `json:"email_status"`
+	EmailError       *string    `json:"email_error,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Create saves a letter in the pending state and fills in its ID.
+func (s *Store) Create(l *Letter) error {
+	query := `
+		INSERT INTO letters (user_id, representative_id, subject, content, ai_provider, ai_model,
+		                     theme, tone, prompt_template_id, prompt_version, email_status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, 'pending')
+		RETURNING id, email_status, created_at
+	`
+
+	err := s.db.QueryRow(query, l.UserID, l.RepresentativeID, l.Subject, l.Content,
+		l.AIProvider, l.AIModel, l.Theme, l.Tone, l.PromptTemplateID, l.PromptVersion,
+	).Scan(&l.ID, &l.EmailStatus, &l.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save letter: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) MarkSent(id int, emailProvider string) error {
+	_, err := s.db.Exec(`
+		UPDATE letters SET email_status = 'sent', email_provider = $2, sent_at = CURRENT_TIMESTAMP, email_error = NULL
+		WHERE id = $1
+	`, id, emailProvider)
+	if err != nil {
+		return fmt.Errorf("failed to mark letter sent: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) MarkFailed(id int, emailProvider string, sendErr error) error {
+	_, err := s.db.Exec(`
+		UPDATE letters SET email_status = 'failed', email_provider = $2, email_error = $3
+		WHERE id = $1
+	`, id, emailProvider, sendErr.Error())
+	if err != nil {
+		return fmt.Errorf("failed to mark letter failed: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) GetByID(id int) (*Letter, error) {
+	rows, err := s.db.Query(selectLetters+" WHERE id = $1", id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get letter: %w", err)
+	}
+	defer rows.Close()
+
+	letters, err := scanLetters(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(letters) == 0 {
+		return nil, fmt.Errorf("letter not found")
+	}
+
+	return &letters[0], nil
+}
+
+// History returns a user's most recent letters, newest first.
+func (s *Store) History(userID, limit int) ([]Letter, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+
+	rows, err := s.db.Query(selectLetters+" WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2", userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query letters: %w", err)
+	}
+	defer rows.Close()
+
+	return scanLetters(rows)
+}
+
+const selectLetters = `
+	SELECT id, user_id, COALESCE(representative_id, 0), subject, content, ai_provider, ai_model,
+	       theme, tone, prompt_template_id, prompt_version, sent_at, email_provider,
+	       COALESCE(email_status, 'pending'), email_error, created_at
+	FROM letters`
+
+func scanLetters(rows *sql.Rows) ([]Letter, error) {
+	var letters []Letter
+	for rows.Next() {
+		var l Letter
+		err := rows.Scan(
+			&l.ID, &l.UserID, &l.RepresentativeID, &l.Subject, &l.Content, &l.AIProvider, &l.AIModel,
+			&l.Theme, &l.Tone, &l.PromptTemplateID, &l.PromptVersion, &l.SentAt, &l.EmailProvider,
+			&l.EmailStatus, &l.EmailError, &l.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan letter: %w", err)
+		}
+		letters = append(letters, l)
+	}
+	return letters, rows.Err()
+}
@@ -0,0 +1,305 @@
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+type Severity string
+
+const (
+	SeverityLow    Severity = "low"
+	SeverityMedium Severity = "medium"
+	SeverityHigh   Severity = "high"
+)
+
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// Report is the result of linting one letter. Blocking is true when any
+// finding is severe enough that the letter must not be sent as-is.
+type Report struct {
+	Findings []Finding `json:"findings"`
+	Blocking bool      `json:"blocking"`
+}
+
+// Input is everything the linter needs to know about a letter and who it is
+// from and to.
+type Input struct {
+	Content             string
+	ConstituentName     string
+	ConstituentZipCode  string
+	ConstituentState    string
+	RepresentativeName  string
+	RepresentativeTitle string
+	RepresentativeState string
+}
+
+type rule func(in Input) []Finding
+
+var rules = []rule{
+	checkPlaceholders,
+	checkSalutation,
+	checkSignature,
+	checkDuplicateParagraphs,
+	checkZipAndState,
+}
+
+func Run(in Input) Report {
+	report := Report{Findings: []Finding{}}
+	for _, r := range rules {
+		for _, f := range r(in) {
+			report.Findings = append(report.Findings, f)
+			if f.Severity == SeverityHigh {
+				report.Blocking = true
+			}
+		}
+	}
+	return report
+}
+
+var placeholderPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\[(your|insert|representative'?s?|senator'?s?|recipient'?s?|name|full name|date|address|city|state|zip|phone|email)[^\]]{0,40}\]`),
+	regexp.MustCompile(`\{\{[^}]*\}?\}?`),
+	regexp.MustCompile(`(?i)</?user_input>`),
+	regexp.MustCompile(`\b(XXX+|TODO|TBD|lorem ipsum)\b`),
+}
+
+func checkPlaceholders(in Input) []Finding {
+	var findings []Finding
+	seen := make(map[string]bool)
+	for _, re := range placeholderPatterns {
+		for _, match := range re.FindAllString(in.Content, -1) {
+			if seen[match] {
+				continue
+			}
+			seen[match] = true
+			findings = append(findings, Finding{
+				Rule:     "unfilled-placeholder",
+				Severity: SeverityHigh,
+				Message:  fmt.Sprintf("Letter contains an unfilled placeholder: %s", match),
+			})
+		}
+	}
+	return findings
+}
+
+// honorifics maps words that may appear in a salutation to the title family
+// they belong to, so "Dear Senator" can be checked against a House member.
+var honorifics = map[string]string{
+	"senator":        "senator",
+	"representative": "representative",
+	"congressman":    "representative",
+	"congresswoman":  "representative",
+	"congressmember": "representative",
+	"assemblymember": "assembly",
+	"assemblyman":    "assembly",
+	"assemblywoman":  "assembly",
+	"delegate":       "delegate",
+	"councilmember":  "council",
+	"councilman":     "council",
+	"councilwoman":   "council",
+	"supervisor":     "supervisor",
+	"commissioner":   "commissioner",
+	"mayor":          "mayor",
+	"governor":       "governor",
+	"member":         "member",
+	"legislator":     "member",
+	"rep":            "representative",
+	"sen":            "senator",
+}
+
+// titleWords are the honorifics checked, in order, when a title names no
+// chamber, so a title always maps to the same family.
+var titleWords = []string{
+	"senator", "representative", "congressman", "congresswoman", "congressmember",
+	"assemblymember", "assemblyman", "assemblywoman", "delegate",
+	"councilmember", "councilman", "councilwoman", "supervisor", "commissioner",
+	"mayor", "governor", "member", "legislator", "rep", "sen",
+}
+
+func titleFamily(title string) string {
+	lower := strings.ToLower(title)
+	switch {
+	case strings.Contains(lower, "senator") || strings.Contains(lower, "senate"):
+		return "senator"
+	case strings.Contains(lower, "assembly"):
+		return "assembly"
+	case strings.Contains(lower, "delegate"):
+		return "delegate"
+	case strings.Contains(lower, "representative") || strings.Contains(lower, "house"):
+		return "representative"
+	case strings.Contains(lower, "council"):
+		return "council"
+	}
+	words := strings.FieldsFunc(lower, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z')
+	})
+	for _, honorific := range titleWords {
+		for _, word := range words {
+			if word == honorific {
+				return honorifics[honorific]
+			}
+		}
+	}
+	return ""
+}
+
+func checkSalutation(in Input) []Finding {
+	var salutation string
+	for _, line := range strings.Split(in.Content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(strings.ToLower(trimmed), "dear ") {
+			salutation = trimmed
+			break
+		}
+	}
+
+	if salutation == "" {
+		return []Finding{{
+			Rule:     "salutation",
+			Severity: SeverityMedium,
+			Message:  "Letter has no \"Dear ...\" salutation",
+		}}
+	}
+
+	var findings []Finding
+
+	if lastName := lastWord(in.RepresentativeName); lastName != "" &&
+		!strings.Contains(strings.ToLower(salutation), strings.ToLower(lastName)) {
+		findings = append(findings, Finding{
+			Rule:     "salutation",
+			Severity: SeverityHigh,
+			Message:  fmt.Sprintf("Salutation %q does not name the selected representative %s", salutation, in.RepresentativeName),
+		})
+	}
+
+	expected := titleFamily(in.RepresentativeTitle)
+	if expected != "" {
+		for _, word := range strings.Fields(strings.ToLower(salutation)) {
+			word = strings.Trim(word, ".,:;")
+			if family, ok := honorifics[word]; ok && family != expected && family != "member" {
+				findings = append(findings, Finding{
+					Rule:     "salutation",
+					Severity: SeverityHigh,
+					Message:  fmt.Sprintf("Salutation %q uses the wrong title for %s %s", salutation, in.RepresentativeTitle, in.RepresentativeName),
+				})
+				break
+			}
+		}
+	}
+
+	return findings
+}
+
+const signatureWindow = 6
+
+func checkSignature(in Input) []Finding {
+	if in.ConstituentName == "" {
+		return nil
+	}
+
+	var lines []string
+	for _, line := range strings.Split(in.Content, "\n") {
+		if trimmed := strings.TrimSpace(line); trimmed != "" {
+			lines = append(lines, trimmed)
+		}
+	}
+	if len(lines) > signatureWindow {
+		lines = lines[len(lines)-signatureWindow:]
+	}
+
+	closing := strings.ToLower(strings.Join(lines, "\n"))
+	if !strings.Contains(closing, strings.ToLower(strings.TrimSpace(in.ConstituentName))) {
+		return []Finding{{
+			Rule:     "signature",
+			Severity: SeverityHigh,
+			Message:  fmt.Sprintf("Letter is not signed with the constituent's name (%s)", in.ConstituentName),
+		}}
+	}
+
+	return nil
+}
+
+var (
+	whitespace      = regexp.MustCompile(`\s+`)
+	paragraphBreaks = regexp.MustCompile(`\n\s*\n`)
+)
+
+func checkDuplicateParagraphs(in Input) []Finding {
+	var findings []Finding
+	seen := make(map[string]int)
+
+	for i, paragraph := range paragraphBreaks.Split(in.Content, -1) {
+		normalized := strings.ToLower(strings.TrimSpace(whitespace.ReplaceAllString(paragraph, " ")))
+		// Short lines such as "Sincerely," legitimately repeat
+		if len(strings.Fields(normalized)) < 8 {
+			continue
+		}
+		if first, ok := seen[normalized]; ok {
+			findings = append(findings, Finding{
+				Rule:     "duplicate-paragraph",
+				Severity: SeverityMedium,
+				Message:  fmt.Sprintf("Paragraph %d repeats paragraph %d", i+1, first+1),
+			})
+			continue
+		}
+		seen[normalized] = i
+	}
+
+	return findings
+}
+
+// zipPattern matches ZIP codes where an address or signature puts them:
+// after a state code, as in "Springfield, IL 62704", or after the word ZIP.
+// Other five-digit numbers, like amounts and bill numbers, are not ZIP codes.
+var zipPattern = regexp.MustCompile(`(?:\b(?:AL|AK|AZ|AR|CA|CO|CT|DE|DC|FL|GA|HI|ID|IL|IN|IA|KS|KY|LA|ME|MD|MA|MI|MN|MS|MO|MT|NE|NV|NH|NJ|NM|NY|NC|ND|OH|OK|OR|PA|RI|SC|SD|TN|TX|UT|VT|VA|WA|WV|WI|WY|AS|GU|MP|PR|VI),?|(?i:\bzip(?:\s+code)?:?))[ \t]+(\d{5}(?:-\d{4})?)\b`)
+
+func checkZipAndState(in Input) []Finding {
+	var findings []Finding
+
+	if in.ConstituentZipCode != "" {
+		userZip := in.ConstituentZipCode
+		if len(userZip) > 5 {
+			userZip = userZip[:5]
+		}
+		for _, groups := range zipPattern.FindAllStringSubmatch(in.Content, -1) {
+			match := groups[1]
+			if match[:5] != userZip {
+				findings = append(findings, Finding{
+					Rule:     "zip-mismatch",
+					Severity: SeverityHigh,
+					Message:  fmt.Sprintf("Letter mentions ZIP code %s but the constituent's ZIP code is %s", match, userZip),
+				})
+			}
+		}
+	}
+
+	if in.ConstituentState != "" && in.RepresentativeState != "" &&
+		!strings.EqualFold(in.ConstituentState, in.RepresentativeState) {
+		findings = append(findings, Finding{
+			Rule:     "state-mismatch",
+			Severity: SeverityHigh,
+			Message:  fmt.Sprintf("Constituent ZIP is in %s but %s represents %s", in.ConstituentState, in.RepresentativeName, in.RepresentativeState),
+		})
+	}
+
+	return findings
+}
+
+func lastWord(name string) string {
+	fields := strings.Fields(name)
+	for i := len(fields) - 1; i >= 0; i-- {
+		word := strings.Trim(fields[i], ".,")
+		switch strings.ToLower(word) {
+		case "jr", "sr", "ii", "iii", "iv":
+			continue
+		}
+		return word
+	}
+	return ""
+}
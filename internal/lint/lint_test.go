@@ -0,0 +1,209 @@
+package lint
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const cleanLetter = `Dear Representative Doe,
+
+I live in Springfield and I am writing about data brokers selling location data without consent.
+
+Please support a bill requiring brokers to honor deletion requests within thirty days.
+
+Sincerely,
+Jane Smith
+Springfield, IL 62704`
+
+func input(content string) Input {
+	return Input{
+		Content:             content,
+		ConstituentName:     "Jane Smith",
+		ConstituentZipCode:  "62704",
+		ConstituentState:    "IL",
+		RepresentativeName:  "John Doe",
+		RepresentativeTitle: "Representative",
+		RepresentativeState: "IL",
+	}
+}
+
+func ruleNames(report Report) []string {
+	names := []string{}
+	for _, f := range report.Findings {
+		names = append(names, f.Rule)
+	}
+	return names
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       Input
+		rules    []string
+		blocking bool
+	}{
+		{
+			name:  "clean letter",
+			in:    input(cleanLetter),
+			rules: []string{},
+		},
+		{
+			name:     "bracket placeholder",
+			in:       input(strings.Replace(cleanLetter, "Jane Smith", "[Your Name]", 1)),
+			rules:    []string{"unfilled-placeholder", "signature"},
+			blocking: true,
+		},
+		{
+			name:     "template placeholder",
+			in:       input(strings.Replace(cleanLetter, "thirty days", "{{.Days}} days", 1)),
+			rules:    []string{"unfilled-placeholder"},
+			blocking: true,
+		},
+		{
+			name:     "input delimiter",
+			in:       input(strings.Replace(cleanLetter, "Jane Smith\n", "<user_input>Jane Smith</user_input>\n", 1)),
+			rules:    []string{"unfilled-placeholder", "unfilled-placeholder"},
+			blocking: true,
+		},
+		{
+			name:     "no salutation",
+			in:       input(strings.Replace(cleanLetter, "Dear Representative Doe,", "Hello,", 1)),
+			rules:    []string{"salutation"},
+			blocking: false,
+		},
+		{
+			name:     "salutation names someone else",
+			in:       input(strings.Replace(cleanLetter, "Representative Doe", "Representative Roe", 1)),
+			rules:    []string{"salutation"},
+			blocking: true,
+		},
+		{
+			name:  "congresswoman for a representative",
+			in:    input(strings.Replace(cleanLetter, "Representative Doe", "Congresswoman Doe", 1)),
+			rules: []string{},
+		},
+		{
+			name:     "senator for a representative",
+			in:       input(strings.Replace(cleanLetter, "Representative Doe", "Senator Doe", 1)),
+			rules:    []string{"salutation"},
+			blocking: true,
+		},
+		{
+			name: "council member title",
+			in: func() Input {
+				in := input(strings.Replace(cleanLetter, "Representative Doe", "Councilmember Doe", 1))
+				in.RepresentativeTitle = "City Council Member"
+				return in
+			}(),
+			rules: []string{},
+		},
+		{
+			name:     "unsigned",
+			in:       input(strings.Replace(cleanLetter, "Jane Smith\n", "", 1)),
+			rules:    []string{"signature"},
+			blocking: true,
+		},
+		{
+			name:     "name only outside the signature window",
+			in:       input(strings.Replace(strings.Replace(cleanLetter, "Jane Smith\n", "", 1), "I live", "I am Jane Smith. I live", 1) + "\n\nP.S. One.\nTwo.\nThree.\nFour."),
+			rules:    []string{"signature"},
+			blocking: true,
+		},
+		{
+			name: "duplicate paragraph",
+			in: input(strings.Replace(cleanLetter, "Sincerely,",
+				"Please support a bill requiring brokers to honor deletion requests within thirty days.\n\nSincerely,", 1)),
+			rules: []string{"duplicate-paragraph"},
+		},
+		{
+			name:  "repeated short lines",
+			in:    input(strings.Replace(cleanLetter, "Sincerely,", "Thank you.\n\nThank you.\n\nSincerely,", 1)),
+			rules: []string{},
+		},
+		{
+			name:     "other ZIP code in the address",
+			in:       input(strings.Replace(cleanLetter, "IL 62704", "IL 62701", 1)),
+			rules:    []string{"zip-mismatch"},
+			blocking: true,
+		},
+		{
+			name:     "other ZIP code after the word ZIP",
+			in:       input(cleanLetter + "\nZIP code: 10001"),
+			rules:    []string{"zip-mismatch"},
+			blocking: true,
+		},
+		{
+			name:  "bill numbers and amounts are not ZIP codes",
+			in:    input(strings.Replace(cleanLetter, "thirty days.", "thirty days, as HB 54321 would, and fine them $10000 for each of the 12345 residents affected in 20231.", 1)),
+			rules: []string{},
+		},
+		{
+			name: "representative of another state",
+			in: func() Input {
+				in := input(cleanLetter)
+				in.RepresentativeState = "WI"
+				return in
+			}(),
+			rules:    []string{"state-mismatch"},
+			blocking: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Run(tt.in)
+			if got := ruleNames(report); !reflect.DeepEqual(got, tt.rules) {
+				t.Errorf("rules = %v, want %v (findings %+v)", got, tt.rules, report.Findings)
+			}
+			if report.Blocking != tt.blocking {
+				t.Errorf("blocking = %t, want %t", report.Blocking, tt.blocking)
+			}
+		})
+	}
+}
+
+func TestTitleFamily(t *testing.T) {
+	tests := map[string]string{
+		"U.S. Senator":         "senator",
+		"State Senate Member":  "senator",
+		"Representative":       "representative",
+		"House Member":         "representative",
+		"Assembly Member":      "assembly",
+		"Delegate":             "delegate",
+		"City Council Member":  "council",
+		"Mayor":                "mayor",
+		"County Supervisor":    "supervisor",
+		"Member of Parliament": "member",
+		"Presenter":            "",
+		"":                     "",
+	}
+	for title, want := range tests {
+		for i := 0; i < 20; i++ {
+			if got := titleFamily(title); got != want {
+				t.Fatalf("titleFamily(%q) = %q, want %q", title, got, want)
+			}
+		}
+	}
+}
+
+func TestZipPattern(t *testing.T) {
+	tests := map[string][]string{
+		"Springfield, IL 62704":             {"62704"},
+		"Springfield IL 62704-1234":         {"62704-1234"},
+		"ZIP: 62704 and zip code 62701":     {"62704", "62701"},
+		"HB 54321 passed":                   nil,
+		"a $10000 fine":                     nil,
+		"12345 residents":                   nil,
+		"SB 12345 and AB 23456 in FY 20231": nil,
+	}
+	for text, want := range tests {
+		var got []string
+		for _, groups := range zipPattern.FindAllStringSubmatch(text, -1) {
+			got = append(got, groups[1])
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("zipPattern in %q = %v, want %v", text, got, want)
+		}
+	}
+}
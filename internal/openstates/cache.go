@@ -0,0 +1,57 @@
+package openstates
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Cache stores API responses by request URL.
+type Cache interface {
+	// Get returns a fresh response for the URL, if there is one.
+	Get(ctx context.Context, requestURL string) ([]byte, bool, error)
+	Set(ctx context.Context, requestURL string, body []byte) error
+}
+
+// DBCache keeps responses in the openstates_cache table for ttl.
+type DBCache struct {
+	db  *sql.DB
+	ttl time.Duration
+}
+
+func NewDBCache(db *sql.DB, ttl time.Duration) *DBCache {
+	return &DBCache{db: db, ttl: ttl}
+}
+
+func (c *DBCache) Get(ctx context.Context, requestURL string) ([]byte, bool, error) {
+	var body []byte
+	err := c.db.QueryRowContext(ctx, `
+		SELECT body FROM openstates_cache WHERE url = $1 AND fetched_at > $2
+	`, requestURL, time.Now().Add(-c.ttl)).Scan(&body)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("database error: %w", err)
+	}
+	return body, true, nil
+}
+
+// Set stores a response and drops the expired ones.
+func (c *DBCache) Set(ctx context.Context, requestURL string, body []byte) error {
+	now := time.Now()
+	_, err := c.db.ExecContext(ctx, `
+		INSERT INTO openstates_cache (url, body, fetched_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (url) DO UPDATE SET body = EXCLUDED.body, fetched_at = EXCLUDED.fetched_at
+	`, requestURL, body, now)
+	if err != nil {
+		return fmt.Errorf("failed to cache OpenStates response: %w", err)
+	}
+
+	if _, err := c.db.ExecContext(ctx, "DELETE FROM openstates_cache WHERE fetched_at <= $1", now.Add(-c.ttl)); err != nil {
+		return fmt.Errorf("failed to expire OpenStates responses: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+// Package openstates is a client for the OpenStates v3 API
+// (https://v3.openstates.org) that follows pagination, backs off when rate
+// limited and caches responses.
+package openstates
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultBaseURL = "https://v3.openstates.org"
+
+const (
+	perPage = 50
+
+	// Rate-limited requests are retried up to maxRetries times, waiting
+	// for Retry-After or, without one, an exponential backoff starting at
+	// initialBackoff. A longer wait than maxWait is not worth holding a
+	// request open for, so the error is returned instead.
+	maxRetries     = 5
+	initialBackoff = time.Second
+	maxWait        = 2 * time.Minute
+)
+
+// httpClient is shared by every Client, so that connections are reused.
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+type Client struct {
+	BaseURL string
+	apiKey  string
+	cache   Cache
+}
+
+// NewClient returns a client for the API at baseURL. Responses are cached in
+// cache unless it is nil.
+func NewClient(baseURL, apiKey string, cache Cache) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		cache:   cache,
+	}
+}
+
+// PeopleGeo returns every legislator whose district contains the point, with
+// their offices and links.
+func (c *Client) PeopleGeo(ctx context.Context, latitude, longitude float64) ([]Person, error) {
+	params := url.Values{}
+	params.Set("lat", fmt.Sprintf("%.6f", latitude))
+	params.Set("lng", fmt.Sprintf("%.6f", longitude))
+	params.Add("include", "offices")
+	params.Add("include", "links")
+	return c.people(ctx, "/people.geo", params)
+}
+
+// maxIDsPerRequest keeps PeopleByID's URLs to a reasonable length.
+const maxIDsPerRequest = 25
+
+// PeopleByID returns the people with the given OpenStates IDs, with their
+// offices and links. IDs OpenStates does not know are left out.
+func (c *Client) PeopleByID(ctx context.Context, ids []string) ([]Person, error) {
+	var people []Person
+	for start := 0; start < len(ids); start += maxIDsPerRequest {
+		end := start + maxIDsPerRequest
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		params := url.Values{}
+		for _, id := range ids[start:end] {
+			params.Add("id", id)
+		}
+		params.Add("include", "offices")
+		params.Add("include", "links")
+
+		batch, err := c.people(ctx, "/people", params)
+		if err != nil {
+			return nil, err
+		}
+		people = append(people, batch...)
+	}
+	return people, nil
+}
+
+// people fetches every page of a people endpoint.
+func (c *Client) people(ctx context.Context, path string, params url.Values) ([]Person, error) {
+	var people []Person
+	params.Set("per_page", strconv.Itoa(perPage))
+	for page := 1; ; page++ {
+		params.Set("page", strconv.Itoa(page))
+
+		var response peopleResponse
+		if err := c.get(ctx, path, params, &response); err != nil {
+			return nil, err
+		}
+		people = append(people, response.Results...)
+
+		if page >= response.Pagination.MaxPage {
+			return people, nil
+		}
+	}
+}
+
+// get decodes the response to a GET request into v, from the cache when it
+// has a fresh copy.
+func (c *Client) get(ctx context.Context, path string, params url.Values, v interface{}) error {
+	requestURL := c.BaseURL + path + "?" + params.Encode()
+
+	if c.cache != nil {
+		body, ok, err := c.cache.Get(ctx, requestURL)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return decode(body, v)
+		}
+	}
+
+	body, err := c.fetch(ctx, requestURL)
+	if err != nil {
+		return err
+	}
+	if err := decode(body, v); err != nil {
+		return err
+	}
+
+	if c.cache != nil {
+		if err := c.cache.Set(ctx, requestURL, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetch makes a request, retrying while it is rate limited.
+func (c *Client) fetch(ctx context.Context, requestURL string) ([]byte, error) {
+	backoff := initialBackoff
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("X-API-KEY", c.apiKey)
+		req.Header.Set("User-Agent", "Lettersmith/1.0")
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to call OpenStates API: %w", err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read OpenStates response: %w", err)
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusOK:
+			return body, nil
+		case resp.StatusCode != http.StatusTooManyRequests:
+			return nil, fmt.Errorf("OpenStates API returned status %d", resp.StatusCode)
+		}
+
+		wait := retryAfter(resp.Header.Get("Retry-After"), backoff)
+		if attempt == maxRetries || wait > maxWait {
+			return nil, fmt.Errorf("OpenStates API rate limit exceeded, retry after %s", wait.Round(time.Second))
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// retryAfter returns how long a Retry-After header, in seconds or as a date,
+// says to wait, or fallback without a usable one.
+func retryAfter(header string, fallback time.Duration) time.Duration {
+	header = strings.TrimSpace(header)
+	if seconds, err := strconv.Atoi(header); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait
+		}
+		return 0
+	}
+	return fallback
+}
+
+func decode(body []byte, v interface{}) error {
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to decode OpenStates response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package openstates
+
+// Person is a legislator as returned by the OpenStates v3 API.
+type Person struct {
+	ID           string        `json:"id"`
+	Name         string        `json:"name"`
+	Party        string        `json:"party"`
+	CurrentRole  *Role         `json:"current_role"`
+	Jurisdiction *Jurisdiction `json:"jurisdiction"`
+	Email        string        `json:"email"`
+	Image        string        `json:"image"`
+	Links        []Link        `json:"links"`
+	Offices      []Office      `json:"offices"`
+}
+
+type Role struct {
+	Title             string `json:"title"`
+	OrgClassification string `json:"org_classification"`
+	District          string `json:"district"`
+	DivisionID        string `json:"division_id"`
+}
+
+// Jurisdiction says where a person serves: its classification is "country"
+// for members of Congress, "state" or "municipality".
+type Jurisdiction struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	Classification string `json:"classification"`
+}
+
+type Link struct {
+	URL  string `json:"url"`
+	Note string `json:"note"`
+}
+
+type Office struct {
+	Classification string `json:"classification"`
+	Name           string `json:"name"`
+	Address        string `json:"address"`
+	Voice          string `json:"voice"`
+	Fax            string `json:"fax"`
+}
+
+// Pagination describes the page of a paginated response.
+type Pagination struct {
+	PerPage    int `json:"per_page"`
+	Page       int `json:"page"`
+	MaxPage    int `json:"max_page"`
+	TotalItems int `json:"total_items"`
+}
+
+type peopleResponse struct {
+	Results    []Person   `json:"results"`
+	Pagination Pagination `json:"pagination"`
+}
@@ -0,0 +1,193 @@
+package prompts
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/yourdatasucks/lettersmith/internal/ai"
+)
+
+type Version struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Version   int       `json:"version"`
+	Content   string    `json:"content"`
+	Notes     *string   `json:"notes,omitempty"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Store resolves the prompt used for generation. An active database version
+// wins, then a file in the configured directory, then the embedded default.
+type Store struct {
+	db        *sql.DB
+	directory string
+}
+
+func NewStore(db *sql.DB, directory string) *Store {
+	return &Store{db: db, directory: directory}
+}
+
+func (s *Store) Active(name string) (*ai.PromptTemplate, error) {
+	var v Version
+	err := s.db.QueryRow(`
+		SELECT id, version, content FROM prompt_templates
+		WHERE name = $1 AND is_active
+	`, name).Scan(&v.ID, &v.Version, &v.Content)
+
+	if err == nil {
+		return &ai.PromptTemplate{
+			ID:      v.ID,
+			Name:    name,
+			Version: v.Version,
+			Source:  ai.PromptSourceDatabase,
+			Content: v.Content,
+		}, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to load active prompt: %w", err)
+	}
+
+	if s.directory != "" {
+		content, err := os.ReadFile(filepath.Join(s.directory, name+".txt"))
+		if err == nil {
+			return &ai.PromptTemplate{
+				Name:    name,
+				Source:  ai.PromptSourceFile,
+				Content: string(content),
+			}, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read prompt file: %w", err)
+		}
+	}
+
+	return ai.DefaultPromptTemplate()
+}
+
+func (s *Store) List(name string) ([]Version, error) {
+	rows, err := s.db.Query(`
+		SELECT id, name, version, content, notes, is_active, created_at
+		FROM prompt_templates
+		WHERE name = $1
+		ORDER BY version DESC
+	`, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query prompt versions: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []Version
+	for rows.Next() {
+		var v Version
+		if err := rows.Scan(&v.ID, &v.Name, &v.Version, &v.Content, &v.Notes, &v.IsActive, &v.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan prompt version: %w", err)
+		}
+		versions = append(versions, v)
+	}
+
+	return versions, rows.Err()
+}
+
+func (s *Store) Get(name string, version int) (*Version, error) {
+	var v Version
+	err := s.db.QueryRow(`
+		SELECT id, name, version, content, notes, is_active, created_at
+		FROM prompt_templates
+		WHERE name = $1 AND version = $2
+	`, name, version).Scan(&v.ID, &v.Name, &v.Version, &v.Content, &v.Notes, &v.IsActive, &v.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("prompt version not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prompt version: %w", err)
+	}
+
+	return &v, nil
+}
+
+// Create validates content and stores it as the next version of name.
+func (s *Store) Create(name, content, notes string, activate bool) (*Version, error) {
+	if _, err := ai.ValidatePromptTemplate(content); err != nil {
+		return nil, fmt.Errorf("invalid prompt template: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Serialise version numbering per name
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", name); err != nil {
+		return nil, fmt.Errorf("failed to lock prompt versions: %w", err)
+	}
+
+	var next int
+	if err := tx.QueryRow("SELECT COALESCE(MAX(version), 0) + 1 FROM prompt_templates WHERE name = $1", name).Scan(&next); err != nil {
+		return nil, fmt.Errorf("failed to determine next version: %w", err)
+	}
+
+	if activate {
+		if _, err := tx.Exec("UPDATE prompt_templates SET is_active = false WHERE name = $1 AND is_active", name); err != nil {
+			return nil, fmt.Errorf("failed to deactivate previous version: %w", err)
+		}
+	}
+
+	var v Version
+	err = tx.QueryRow(`
+		INSERT INTO prompt_templates (name, version, content, notes, is_active)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, name, version, content, notes, is_active, created_at
+	`, name, next, content, nullString(notes), activate).Scan(
+		&v.ID, &v.Name, &v.Version, &v.Content, &v.Notes, &v.IsActive, &v.CreatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store prompt version: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &v, nil
+}
+
+// Activate makes version the active prompt for name. Version 0 deactivates
+// every stored version so the file or embedded default is used again.
+func (s *Store) Activate(name string, version int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE prompt_templates SET is_active = false WHERE name = $1 AND is_active", name); err != nil {
+		return fmt.Errorf("failed to deactivate previous version: %w", err)
+	}
+
+	if version > 0 {
+		result, err := tx.Exec("UPDATE prompt_templates SET is_active = true WHERE name = $1 AND version = $2", name, version)
+		if err != nil {
+			return fmt.Errorf("failed to activate prompt version: %w", err)
+		}
+		if rowsAffected, err := result.RowsAffected(); err != nil {
+			return fmt.Errorf("failed to get rows affected: %w", err)
+		} else if rowsAffected == 0 {
+			return fmt.Errorf("prompt version not found")
+		}
+	}
+
+	return tx.Commit()
+}
+
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,332 @@
+package reps
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Formats of ExportRepresentatives and ParseImport.
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// exportColumns are the CSV columns of an export. The updatable fields can
+// be edited and imported again; the others identify the representative.
+var exportColumns = append([]string{"id", "external_id", "source", "overridden_fields", "active", "state", "level", "chamber"}, UpdatableFields...)
+
+// ListRepresentatives returns every stored representative, active or not,
+// with their contacts, by state, title and name.
+func (s *Service) ListRepresentatives() ([]Representative, error) {
+	rows, err := s.db.Query(`SELECT ` + representativeColumns + ` FROM representatives ORDER BY state, title, name, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query representatives: %w", err)
+	}
+	defer rows.Close()
+
+	representatives := []Representative{}
+	for rows.Next() {
+		rep, err := scanRepresentative(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan representative: %w", err)
+		}
+		representatives = append(representatives, rep)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.loadContacts(representatives); err != nil {
+		return nil, err
+	}
+	return representatives, nil
+}
+
+// ExportRepresentatives writes representatives as CSV, one row each with
+// exportColumns, or as a JSON list.
+func ExportRepresentatives(w io.Writer, format string, representatives []Representative) error {
+	if format == FormatJSON {
+		return json.NewEncoder(w).Encode(representatives)
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(exportColumns); err != nil {
+		return err
+	}
+	for _, rep := range representatives {
+		values := exportValues(rep)
+		row := make([]string, len(exportColumns))
+		for i, column := range exportColumns {
+			row[i] = values[column]
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func exportValues(rep Representative) map[string]string {
+	return map[string]string{
+		"id":                strconv.Itoa(rep.ID),
+		"external_id":       stringValue(rep.ExternalID),
+		"source":            rep.Source,
+		"overridden_fields": strings.Join(rep.Overridden, " "),
+		"active":            strconv.FormatBool(rep.Active),
+		"state":             rep.State,
+		"level":             rep.Level,
+		"chamber":           rep.Chamber,
+		"name":              rep.Name,
+		"title":             rep.Title,
+		"district":          stringValue(rep.District),
+		"party":             stringValue(rep.Party),
+		"email":             stringValue(rep.Email),
+		"phone":             stringValue(rep.Phone),
+		"office_address":    stringValue(rep.OfficeAddress),
+		"website":           stringValue(rep.Website),
+	}
+}
+
+// ParseImport reads the records of a CSV file with a header row or a JSON
+// list of objects.
+func ParseImport(r io.Reader, format string) ([]map[string]interface{}, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read import: %w", err)
+	}
+
+	if format == FormatCSV {
+		records, err := csvRecords(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		return records, nil
+	}
+
+	var document interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	return recordsAt(document, "")
+}
+
+// ImportChange is a representative an import adds or updates. Fields holds
+// the new values, with the old ones for updates. Override is set for updates
+// of synced representatives, whose fields are kept over later syncs.
+type ImportChange struct {
+	Row      int                    `json:"row"`
+	ID       int                    `json:"id,omitempty"`
+	Name     string                 `json:"name"`
+	Fields   map[string]FieldChange `json:"fields"`
+	Override bool                   `json:"override,omitempty"`
+}
+
+// ImportError is a row that cannot be imported.
+type ImportError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// ImportResult is what an import changes, or would change on a dry run.
+type ImportResult struct {
+	DryRun    bool           `json:"dry_run"`
+	Inserts   []ImportChange `json:"inserts"`
+	Updates   []ImportChange `json:"updates"`
+	Unchanged int            `json:"unchanged"`
+	Errors    []ImportError  `json:"errors"`
+}
+
+// importFields are the fields read from each record: the external ID to
+// match by, the updatable fields and the state and level of representatives
+// the import adds.
+var importFields = append(append([]string{"external_id"}, UpdatableFields...), "state", "level")
+
+// importValues reads the fields of a record and checks its email and phone.
+func importValues(record map[string]interface{}) (map[string]string, error) {
+	values := map[string]string{}
+	for _, field := range importFields {
+		value, err := recordString(record, field)
+		if err != nil {
+			return nil, err
+		}
+		values[field] = value
+	}
+	if email := values["email"]; email != "" {
+		if err := checkEmail(email); err != nil {
+			return nil, err
+		}
+	}
+	if phone := values["phone"]; phone != "" {
+		if err := checkPhone(phone); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// importKey identifies representatives without an external ID by name and
+// district, ignoring case.
+func importKey(name, district string) string {
+	return strings.ToLower(strings.TrimSpace(name)) + "\x00" + strings.ToLower(strings.TrimSpace(district))
+}
+
+// ImportRepresentatives applies the records of an import. A record is matched
+// to a representative by its external_id or, without one, by name and
+// district. Its non-empty updatable fields replace those of the match, as
+// overrides that syncs keep when the match is synced; a record matching none
+// adds a manual representative. Emails and phone
+// numbers are checked, and when any record has an error nothing is changed.
+// Rows are numbered from 1, not counting a CSV header.
+func (s *Service) ImportRepresentatives(records []map[string]interface{}, dryRun bool) (*ImportResult, error) {
+	existing, err := s.ListRepresentatives()
+	if err != nil {
+		return nil, err
+	}
+	byExternalID := map[string]*Representative{}
+	byKey := map[string][]*Representative{}
+	for i := range existing {
+		rep := &existing[i]
+		if rep.ExternalID != nil {
+			byExternalID[*rep.ExternalID] = rep
+		}
+		key := importKey(rep.Name, stringValue(rep.District))
+		byKey[key] = append(byKey[key], rep)
+	}
+
+	result := &ImportResult{DryRun: dryRun, Inserts: []ImportChange{}, Updates: []ImportChange{}, Errors: []ImportError{}}
+	var inserts []Representative
+	updates := map[int]map[string]string{}
+	matchedRows := map[int]int{}
+	insertedRows := map[string]int{}
+
+	for i, record := range records {
+		row := i + 1
+		fail := func(format string, args ...interface{}) {
+			result.Errors = append(result.Errors, ImportError{Row: row, Error: fmt.Sprintf(format, args...)})
+		}
+
+		values, err := importValues(record)
+		if err != nil {
+			fail("%v", err)
+			continue
+		}
+
+		var match *Representative
+		if id := values["external_id"]; id != "" {
+			match = byExternalID[id]
+			if match == nil {
+				fail("no representative has external_id %q", id)
+				continue
+			}
+		} else {
+			if values["name"] == "" {
+				fail("name or external_id is required")
+				continue
+			}
+			matches := byKey[importKey(values["name"], values["district"])]
+			if len(matches) > 1 {
+				fail("%d representatives are named %s in district %q, match by external_id", len(matches), values["name"], values["district"])
+				continue
+			}
+			if len(matches) == 1 {
+				match = matches[0]
+			}
+		}
+
+		if match == nil {
+			rep := Representative{
+				Name:          values["name"],
+				Title:         values["title"],
+				State:         values["state"],
+				Level:         strings.ToLower(values["level"]),
+				District:      optionalString(values["district"]),
+				Party:         optionalString(values["party"]),
+				Email:         optionalString(values["email"]),
+				Phone:         optionalString(values["phone"]),
+				OfficeAddress: optionalString(values["office_address"]),
+				Website:       optionalString(values["website"]),
+			}
+			if err := rep.normalize(); err != nil {
+				fail("%v", err)
+				continue
+			}
+			key := importKey(rep.Name, values["district"])
+			if other, ok := insertedRows[key]; ok {
+				fail("adds the same representative as row %d", other)
+				continue
+			}
+			insertedRows[key] = row
+
+			fields := map[string]FieldChange{}
+			for _, field := range importFields[1:] {
+				if values[field] != "" {
+					value := values[field]
+					fields[field] = FieldChange{New: &value}
+				}
+			}
+			inserts = append(inserts, rep)
+			result.Inserts = append(result.Inserts, ImportChange{Row: row, Name: rep.Name, Fields: fields})
+			continue
+		}
+
+		if other, ok := matchedRows[match.ID]; ok {
+			fail("matches the same representative as row %d", other)
+			continue
+		}
+		matchedRows[match.ID] = row
+
+		current := exportValues(*match)
+		fields := map[string]FieldChange{}
+		changes := map[string]string{}
+		for _, field := range UpdatableFields {
+			value := values[field]
+			if value == "" || value == current[field] {
+				continue
+			}
+			fields[field] = FieldChange{Old: optionalString(current[field]), New: &value}
+			changes[field] = value
+		}
+		if len(changes) == 0 {
+			result.Unchanged++
+			continue
+		}
+		updates[match.ID] = changes
+		result.Updates = append(result.Updates, ImportChange{Row: row, ID: match.ID, Name: match.Name, Fields: fields,
+			Override: match.Source != SourceManual})
+	}
+
+	if dryRun || len(result.Errors) > 0 {
+		return result, nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, update := range result.Updates {
+		if err := updateRepresentative(tx, update.ID, updates[update.ID]); err != nil {
+			return nil, fmt.Errorf("row %d: %w", update.Row, err)
+		}
+		if err := recordChange(tx, update.ID, ChangeUpdated, update.Fields); err != nil {
+			return nil, err
+		}
+	}
+	for i := range inserts {
+		if err := createRepresentative(tx, &inserts[i]); err != nil {
+			return nil, fmt.Errorf("row %d: %w", result.Inserts[i].Row, err)
+		}
+		result.Inserts[i].ID = inserts[i].ID
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit import: %w", err)
+	}
+	return result, nil
+}
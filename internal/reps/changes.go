@@ -0,0 +1,175 @@
+package reps
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Kinds of change recorded by syncs.
+const (
+	ChangeAdded    = "added"
+	ChangeUpdated  = "updated"
+	ChangeDeparted = "departed" // no longer returned by any source
+	ChangeReturned = "returned" // returned again after departing
+)
+
+// FieldChange is the value of a field before and after a sync.
+type FieldChange struct {
+	Old *string `json:"old"`
+	New *string `json:"new"`
+}
+
+// Change is an entry of the representative changelog.
+type Change struct {
+	ID               int                    `json:"id"`
+	RepresentativeID int                    `json:"representative_id"`
+	Name             string                 `json:"name"`
+	Title            string                 `json:"title"`
+	State            string                 `json:"state"`
+	Change           string                 `json:"change"`
+	Fields           map[string]FieldChange `json:"fields,omitempty"`
+	CreatedAt        time.Time              `json:"created_at"`
+}
+
+// diffRepresentatives returns the fields a sync changes: membership details
+// and the preferred contacts.
+func diffRepresentatives(old, new Representative) map[string]FieldChange {
+	fields := map[string]FieldChange{}
+	compare := func(field string, before, after *string) {
+		if stringValue(before) != stringValue(after) {
+			fields[field] = FieldChange{Old: before, New: after}
+		}
+	}
+
+	compare("name", &old.Name, &new.Name)
+	compare("title", &old.Title, &new.Title)
+	compare("state", &old.State, &new.State)
+	compare("district", old.District, new.District)
+	compare("party", old.Party, new.Party)
+	compare("level", optionalString(old.Level), optionalString(new.Level))
+	compare("chamber", optionalString(old.Chamber), optionalString(new.Chamber))
+	compare("email", old.Email, new.Email)
+	compare("phone", old.Phone, new.Phone)
+	compare("office_address", old.OfficeAddress, new.OfficeAddress)
+	compare("website", old.Website, new.Website)
+	return fields
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func recordChange(tx *sql.Tx, representativeID int, change string, fields map[string]FieldChange) error {
+	var encoded interface{}
+	if len(fields) > 0 {
+		data, err := json.Marshal(fields)
+		if err != nil {
+			return fmt.Errorf("failed to encode changed fields: %w", err)
+		}
+		encoded = string(data)
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO representative_changes (representative_id, change, fields)
+		VALUES ($1, $2, $3)
+	`, representativeID, change, encoded)
+	if err != nil {
+		return fmt.Errorf("failed to record representative change: %w", err)
+	}
+	return nil
+}
+
+// SyncStarted returns the database time a sync of every location starts at,
+// to pass to MarkDeparted once it has finished.
+func (s *Service) SyncStarted() (time.Time, error) {
+	var now time.Time
+	if err := s.db.QueryRow("SELECT CURRENT_TIMESTAMP").Scan(&now); err != nil {
+		return time.Time{}, fmt.Errorf("database error: %w", err)
+	}
+	return now, nil
+}
+
+// MarkDeparted marks every active synced representative not seen since the
+// given time as inactive and returns how many there were. Manual ones are
+// left alone. Call it only after a sync that reached every source for every
+// location, or representatives who are still in office would be marked as
+// departed.
+func (s *Service) MarkDeparted(since time.Time) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		UPDATE representatives SET active = FALSE, updated_at = CURRENT_TIMESTAMP
+		WHERE active AND external_id IS NOT NULL AND source <> 'manual'
+			AND (last_seen_at IS NULL OR last_seen_at < $1)
+		RETURNING id
+	`, since)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark departed representatives: %w", err)
+	}
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan representative: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		if err := recordChange(tx, id, ChangeDeparted, nil); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit departed representatives: %w", err)
+	}
+	return len(ids), nil
+}
+
+// ListChanges returns the most recent changelog entries, newest first.
+func (s *Service) ListChanges(limit int) ([]Change, error) {
+	rows, err := s.db.Query(`
+		SELECT c.id, c.representative_id, r.name, r.title, r.state, c.change, c.fields, c.created_at
+		FROM representative_changes c
+		JOIN representatives r ON r.id = c.representative_id
+		ORDER BY c.created_at DESC, c.id DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query representative changes: %w", err)
+	}
+	defer rows.Close()
+
+	changes := []Change{}
+	for rows.Next() {
+		var change Change
+		var fields []byte
+		if err := rows.Scan(&change.ID, &change.RepresentativeID, &change.Name, &change.Title,
+			&change.State, &change.Change, &fields, &change.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan representative change: %w", err)
+		}
+		if len(fields) > 0 {
+			if err := json.Unmarshal(fields, &change.Fields); err != nil {
+				return nil, fmt.Errorf("invalid fields of representative change %d: %w", change.ID, err)
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
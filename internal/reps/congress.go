@@ -0,0 +1,190 @@
+package reps
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CongressLegislator is one person in the congress-legislators dataset
+// (https://github.com/unitedstates/congress-legislators). The YAML and JSON
+// editions share this layout.
+type CongressLegislator struct {
+	ID struct {
+		Bioguide string `yaml:"bioguide"`
+	} `yaml:"id"`
+	Name struct {
+		First        string `yaml:"first"`
+		Last         string `yaml:"last"`
+		OfficialFull string `yaml:"official_full"`
+	} `yaml:"name"`
+	Terms []CongressTerm `yaml:"terms"`
+}
+
+// CongressTerm is one term served. District is set for House members only,
+// with 0 for at-large seats and delegates.
+type CongressTerm struct {
+	Type        string `yaml:"type"` // sen or rep
+	Start       string `yaml:"start"`
+	End         string `yaml:"end"`
+	State       string `yaml:"state"`
+	District    *int   `yaml:"district"`
+	Party       string `yaml:"party"`
+	URL         string `yaml:"url"`
+	ContactForm string `yaml:"contact_form"`
+	Office      string `yaml:"office"`
+	Address     string `yaml:"address"`
+	Phone       string `yaml:"phone"`
+	Fax         string `yaml:"fax"`
+}
+
+// At-large House seats and delegates are stored with this district, matching
+// the value the geocoder caches for their ZIP codes.
+const AtLargeDistrict = "AL"
+
+// congressJurisdiction is the OCD jurisdiction of every member of Congress.
+const congressJurisdiction = "ocd-jurisdiction/country:us/government"
+
+// congressPhotoURL is where the congress-legislators project publishes
+// official portraits, by Bioguide ID.
+const congressPhotoURL = "https://theunitedstates.io/images/congress/225x275/%s.jpg"
+
+// Territories send a non-voting delegate, or for Puerto Rico a resident
+// commissioner, to the House instead of a representative.
+var delegateTitles = map[string]string{
+	"AS": "Delegate",
+	"DC": "Delegate",
+	"GU": "Delegate",
+	"MP": "Delegate",
+	"VI": "Delegate",
+	"PR": "Resident Commissioner",
+}
+
+// LoadCongressLegislators reads a legislators-current file. JSON is a subset
+// of YAML, so both editions are read by the YAML decoder.
+func LoadCongressLegislators(path string) ([]CongressLegislator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read congress legislators file: %w", err)
+	}
+
+	var legislators []CongressLegislator
+	if err := yaml.Unmarshal(data, &legislators); err != nil {
+		return nil, fmt.Errorf("invalid congress legislators file %s: %w", path, err)
+	}
+	return legislators, nil
+}
+
+// currentTerm returns the term being served on the given date, if any.
+func (l *CongressLegislator) currentTerm(today string) *CongressTerm {
+	for i := len(l.Terms) - 1; i >= 0; i-- {
+		term := &l.Terms[i]
+		if term.Start <= today && today <= term.End {
+			return term
+		}
+	}
+	return nil
+}
+
+// representative maps a legislator serving the given term to a
+// representative keyed by their Bioguide ID.
+func (l *CongressLegislator) representative(term *CongressTerm) (Representative, error) {
+	name := l.Name.OfficialFull
+	if name == "" {
+		name = l.Name.First + " " + l.Name.Last
+	}
+
+	rep := Representative{
+		Name:         name,
+		State:        term.State,
+		Party:        optionalString(term.Party),
+		ExternalID:   optionalString("bioguide/" + l.ID.Bioguide),
+		Level:        LevelFederal,
+		Jurisdiction: optionalString(congressJurisdiction),
+		TermStart:    optionalString(term.Start),
+		TermEnd:      optionalString(term.End),
+		PhotoURL:     optionalString(fmt.Sprintf(congressPhotoURL, l.ID.Bioguide)),
+		Source:       SourceCongress,
+	}
+
+	// The dataset only lists the Washington office.
+	if term.Address != "" || term.Phone != "" {
+		rep.Offices = []Office{{
+			Classification: OfficeCapitol,
+			Name:           term.Office,
+			Address:        term.Address,
+			Phone:          term.Phone,
+			Fax:            term.Fax,
+		}}
+	}
+	if term.URL != "" {
+		rep.Links = append(rep.Links, Link{URL: term.URL, Note: "Official website"})
+	}
+	if term.ContactForm != "" {
+		rep.Links = append(rep.Links, Link{URL: term.ContactForm, Note: "Contact form"})
+	}
+	rep.flattenContacts()
+
+	switch term.Type {
+	case "sen":
+		rep.Title = "U.S. Senator"
+		rep.Chamber = ChamberUpper
+	case "rep":
+		rep.Title = "U.S. Representative"
+		if title, ok := delegateTitles[term.State]; ok {
+			rep.Title = title
+		}
+		rep.Chamber = ChamberLower
+		district := AtLargeDistrict
+		if term.District != nil && *term.District > 0 {
+			district = strconv.Itoa(*term.District)
+		}
+		rep.District = &district
+	default:
+		return rep, fmt.Errorf("unknown term type %q", term.Type)
+	}
+
+	return rep, nil
+}
+
+// ImportCongressLegislators stores every member of Congress serving today
+// from a legislators-current file and returns how many were stored.
+func (s *Service) ImportCongressLegislators(path string) (int, error) {
+	legislators, err := LoadCongressLegislators(path)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	today := time.Now().Format("2006-01-02")
+	count := 0
+	for i := range legislators {
+		legislator := &legislators[i]
+		term := legislator.currentTerm(today)
+		if term == nil || legislator.ID.Bioguide == "" {
+			continue
+		}
+
+		rep, err := legislator.representative(term)
+		if err != nil {
+			return 0, fmt.Errorf("legislator %s: %w", legislator.ID.Bioguide, err)
+		}
+		if err := upsertRepresentative(tx, rep); err != nil {
+			return 0, fmt.Errorf("failed to store representative %s: %w", rep.Name, err)
+		}
+		count++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit representatives: %w", err)
+	}
+	return count, nil
+}
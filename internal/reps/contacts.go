@@ -0,0 +1,242 @@
+package reps
+
+import (
+	"database/sql"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// PreferredOffice returns the office letters are addressed to: the first
+// capitol office with an address, else the first office with one.
+func (r *Representative) PreferredOffice() *Office {
+	var fallback *Office
+	for i := range r.Offices {
+		office := &r.Offices[i]
+		if office.Address == "" {
+			continue
+		}
+		if office.Classification == OfficeCapitol {
+			return office
+		}
+		if fallback == nil {
+			fallback = office
+		}
+	}
+	return fallback
+}
+
+// flattenContacts fills the single Email, Phone, OfficeAddress and Website
+// fields from the preferred office, emails and links where the source did
+// not set them directly.
+func (r *Representative) flattenContacts() {
+	if office := r.PreferredOffice(); office != nil {
+		if r.OfficeAddress == nil {
+			r.OfficeAddress = optionalString(office.Address)
+		}
+		if r.Phone == nil {
+			r.Phone = optionalString(office.Phone)
+		}
+	}
+	if r.Phone == nil {
+		for _, office := range r.Offices {
+			if office.Phone != "" {
+				r.Phone = optionalString(office.Phone)
+				break
+			}
+		}
+	}
+	if r.Email == nil && len(r.Emails) > 0 {
+		r.Email = optionalString(r.Emails[0])
+	}
+	if r.Website == nil && len(r.Links) > 0 {
+		r.Website = optionalString(r.Links[0].URL)
+	}
+}
+
+// addEmail appends email to Emails unless it is empty or already there.
+func (r *Representative) addEmail(email string) {
+	if email == "" {
+		return
+	}
+	for _, existing := range r.Emails {
+		if existing == email {
+			return
+		}
+	}
+	r.Emails = append(r.Emails, email)
+}
+
+// checkEmail accepts a bare email address, such as jlee@example.gov.
+func checkEmail(email string) error {
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email || !strings.Contains(email[strings.LastIndex(email, "@"):], ".") {
+		return fmt.Errorf("invalid email address %q", email)
+	}
+	return nil
+}
+
+// phoneExtension matches an extension at the end of a phone number, such as
+// " ext. 12" or "x12".
+var phoneExtension = regexp.MustCompile(`(?i)\s*(x|ext\.?)\s*\d+$`)
+
+// checkPhone accepts a phone number of 7 to 15 digits written with spaces,
+// dashes, dots, parentheses and a leading +, and an optional extension.
+func checkPhone(phone string) error {
+	number := phoneExtension.ReplaceAllString(phone, "")
+	digits := 0
+	for i, r := range number {
+		switch {
+		case r >= '0' && r <= '9':
+			digits++
+		case r == '+' && i == 0, strings.ContainsRune(" -.()", r):
+		default:
+			return fmt.Errorf("invalid phone number %q", phone)
+		}
+	}
+	if digits < 7 || digits > 15 {
+		return fmt.Errorf("invalid phone number %q", phone)
+	}
+	return nil
+}
+
+// saveContacts replaces the stored offices, links and emails of the
+// representative with the given ID by those of rep.
+func saveContacts(tx *sql.Tx, id int, rep Representative) error {
+	for _, table := range []string{"representative_offices", "representative_links", "representative_emails"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE representative_id = $1", id); err != nil {
+			return fmt.Errorf("failed to clear %s: %w", table, err)
+		}
+	}
+
+	for i, office := range rep.Offices {
+		_, err := tx.Exec(`
+			INSERT INTO representative_offices (representative_id, position, classification, name, address, phone, fax, email)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`, id, i, office.Classification, nullString(office.Name), nullString(office.Address),
+			nullString(office.Phone), nullString(office.Fax), nullString(office.Email))
+		if err != nil {
+			return fmt.Errorf("failed to store office: %w", err)
+		}
+	}
+
+	for i, link := range rep.Links {
+		_, err := tx.Exec(`
+			INSERT INTO representative_links (representative_id, position, url, note)
+			VALUES ($1, $2, $3, $4)
+		`, id, i, link.URL, nullString(link.Note))
+		if err != nil {
+			return fmt.Errorf("failed to store link: %w", err)
+		}
+	}
+
+	for i, email := range rep.Emails {
+		_, err := tx.Exec(`
+			INSERT INTO representative_emails (representative_id, position, email)
+			VALUES ($1, $2, $3)
+		`, id, i, email)
+		if err != nil {
+			return fmt.Errorf("failed to store email: %w", err)
+		}
+	}
+
+	return nil
+}
+
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// loadContacts fills in the offices, links and emails of representatives.
+func (s *Service) loadContacts(representatives []Representative) error {
+	return loadContacts(s.db, representatives)
+}
+
+func loadContacts(db queryer, representatives []Representative) error {
+	if len(representatives) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*Representative, len(representatives))
+	ids := make([]int64, len(representatives))
+	for i := range representatives {
+		rep := &representatives[i]
+		rep.Offices = []Office{}
+		rep.Links = []Link{}
+		rep.Emails = []string{}
+		byID[rep.ID] = rep
+		ids[i] = int64(rep.ID)
+	}
+
+	rows, err := db.Query(`
+		SELECT representative_id, classification, COALESCE(name, ''), COALESCE(address, ''),
+			COALESCE(phone, ''), COALESCE(fax, ''), COALESCE(email, '')
+		FROM representative_offices
+		WHERE representative_id = ANY($1)
+		ORDER BY representative_id, position
+	`, pq.Int64Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to query offices: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var office Office
+		if err := rows.Scan(&id, &office.Classification, &office.Name, &office.Address,
+			&office.Phone, &office.Fax, &office.Email); err != nil {
+			return fmt.Errorf("failed to scan office: %w", err)
+		}
+		byID[id].Offices = append(byID[id].Offices, office)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = db.Query(`
+		SELECT representative_id, url, COALESCE(note, '')
+		FROM representative_links
+		WHERE representative_id = ANY($1)
+		ORDER BY representative_id, position
+	`, pq.Int64Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to query links: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var link Link
+		if err := rows.Scan(&id, &link.URL, &link.Note); err != nil {
+			return fmt.Errorf("failed to scan link: %w", err)
+		}
+		byID[id].Links = append(byID[id].Links, link)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = db.Query(`
+		SELECT representative_id, email
+		FROM representative_emails
+		WHERE representative_id = ANY($1)
+		ORDER BY representative_id, position
+	`, pq.Int64Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to query emails: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var email string
+		if err := rows.Scan(&id, &email); err != nil {
+			return fmt.Errorf("failed to scan email: %w", err)
+		}
+		byID[id].Emails = append(byID[id].Emails, email)
+	}
+	return rows.Err()
+}
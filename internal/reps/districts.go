@@ -0,0 +1,470 @@
+package reps
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// District is one district overlapping a ZIP code. LandShare is the part of
+// the ZIP code's land area inside the district, from 0 to 1.
+type District struct {
+	State     string  `json:"state"`
+	District  string  `json:"district"`
+	Name      string  `json:"name,omitempty"`
+	LandShare float64 `json:"land_share"`
+}
+
+// Districts are the districts a user has chosen to live in, for the ZIP
+// codes split between several.
+type Districts struct {
+	Congressional string `json:"congressional,omitempty"`
+	StateUpper    string `json:"state_upper,omitempty"`
+	StateLower    string `json:"state_lower,omitempty"`
+}
+
+// Kinds of district, as used in DistrictResolution.NeedsChoice.
+const (
+	KindCongressional = "congressional"
+	KindStateUpper    = "state_upper"
+	KindStateLower    = "state_lower"
+)
+
+// DistrictResolution is what is known about the districts of a ZIP code.
+// Each list is empty when no relationship file of that kind was imported.
+type DistrictResolution struct {
+	ZipCode       string     `json:"zip_code"`
+	Congressional []District `json:"congressional"`
+	StateUpper    []District `json:"state_upper"`
+	StateLower    []District `json:"state_lower"`
+	Chosen        Districts  `json:"chosen"`
+	// NeedsChoice lists the kinds of district the ZIP code is split across
+	// that the user has not chosen between.
+	NeedsChoice []string `json:"needs_choice"`
+}
+
+// districtKind returns the kind of district a representative is elected
+// from, or "" for senators and representatives without a level.
+func districtKind(rep Representative) string {
+	switch {
+	case rep.Level == LevelFederal && rep.Chamber == ChamberLower:
+		return KindCongressional
+	case rep.Level == LevelState && rep.Chamber == ChamberUpper:
+		return KindStateUpper
+	case rep.Level == LevelState && rep.Chamber == ChamberLower:
+		return KindStateLower
+	}
+	return ""
+}
+
+var districtKinds = []string{KindCongressional, KindStateUpper, KindStateLower}
+
+// choice returns the field of d holding the district of the given kind.
+func (d *Districts) choice(kind string) *string {
+	switch kind {
+	case KindCongressional:
+		return &d.Congressional
+	case KindStateUpper:
+		return &d.StateUpper
+	case KindStateLower:
+		return &d.StateLower
+	}
+	return nil
+}
+
+// candidates returns the districts of the given kind and the one chosen.
+func (r *DistrictResolution) candidates(kind string) ([]District, string) {
+	switch kind {
+	case KindCongressional:
+		return r.Congressional, r.Chosen.Congressional
+	case KindStateUpper:
+		return r.StateUpper, r.Chosen.StateUpper
+	case KindStateLower:
+		return r.StateLower, r.Chosen.StateLower
+	}
+	return nil, ""
+}
+
+// Includes reports whether rep represents the ZIP code. Representatives of a
+// split ZIP code are all included until the user chooses a district, and so
+// are those whose named district cannot be compared with the imported ones.
+func (r *DistrictResolution) Includes(rep Representative) bool {
+	candidates, chosen := r.candidates(districtKind(rep))
+	if rep.District == nil {
+		return len(candidates) == 0
+	}
+	if chosen != "" {
+		candidates = chosenDistricts(candidates, chosen)
+	}
+	return len(candidates) == 0 || containsDistrict(candidates, *rep.District) ||
+		!comparable(candidates, *rep.District)
+}
+
+// Check returns an error when a chosen district is not one the ZIP code
+// overlaps.
+func (r *DistrictResolution) Check(chosen Districts) error {
+	for _, kind := range districtKinds {
+		district := *chosen.choice(kind)
+		if district == "" {
+			continue
+		}
+		candidates, _ := r.candidates(kind)
+		if !containsDistrict(candidates, district) {
+			return fmt.Errorf("ZIP code %s is not in %s district %q", r.ZipCode, kind, district)
+		}
+	}
+	return nil
+}
+
+func chosenDistricts(districts []District, chosen string) []District {
+	for _, d := range districts {
+		if sameDistrict(d.District, chosen) {
+			return []District{d}
+		}
+	}
+	return nil
+}
+
+func containsDistrict(districts []District, district string) bool {
+	for _, d := range districts {
+		if d.matches(district) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether district is d, by code or by name. States such as
+// Massachusetts, New Hampshire and Vermont name their legislative districts
+// ("1st Barnstable", "Belknap 1", "Addison-1"), which OpenStates uses instead
+// of the Census code.
+func (d District) matches(district string) bool {
+	if sameDistrict(d.District, district) {
+		return true
+	}
+	name := districtName(d.Name)
+	return name != "" && name == districtName(district)
+}
+
+// comparable reports whether district can be told apart from the districts:
+// numbered and at-large districts always can, named ones only when the
+// relationship file had district names.
+func comparable(districts []District, district string) bool {
+	if number := normalizeDistrict(district); number == AtLargeDistrict || strings.Trim(number, "0123456789") == "" {
+		return true
+	}
+	for _, d := range districts {
+		if d.Name != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// sameDistrict compares district codes, ignoring leading zeros, case and the
+// state prefix OpenStates gives congressional districts ("CA-12").
+func sameDistrict(a, b string) bool {
+	return normalizeDistrict(a) == normalizeDistrict(b)
+}
+
+func normalizeDistrict(d string) string {
+	if len(d) > 3 && d[2] == '-' {
+		d = d[3:]
+	}
+	if trimmed := strings.TrimLeft(d, "0"); trimmed != "" {
+		d = trimmed
+	}
+	return strings.ToUpper(strings.TrimSpace(d))
+}
+
+// districtWords are the words of Census district names (NAMELSAD) around the
+// name OpenStates uses, as in "State House District Addison-1".
+var districtWords = map[string]bool{
+	"state": true, "house": true, "senate": true, "assembly": true,
+	"legislative": true, "congressional": true, "district": true,
+}
+
+// districtName reduces a district name to its distinguishing words, lower
+// case and without punctuation, so that "1st Barnstable District" and
+// "1st Barnstable" are the same.
+func districtName(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	kept := words[:0]
+	for _, word := range words {
+		if !districtWords[word] {
+			kept = append(kept, word)
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+// ResolveDistricts looks up the districts overlapping zipCode, largest share
+// first, and which the user still needs to choose between. Choices that the
+// ZIP code does not overlap, e.g. left over from a previous ZIP code, are
+// ignored. Without a congressional relationship file, the district cached
+// for the ZIP code's centroid is used.
+func (s *Service) ResolveDistricts(zipCode string, chosen Districts) (*DistrictResolution, error) {
+	r := &DistrictResolution{
+		ZipCode:       zipCode,
+		Congressional: []District{},
+		StateUpper:    []District{},
+		StateLower:    []District{},
+		NeedsChoice:   []string{},
+	}
+
+	rows, err := s.db.Query(`
+		SELECT 'congressional', state, district, COALESCE(name, ''),
+		       land_area::float / SUM(land_area) OVER ()
+		FROM zcta_congressional_districts WHERE zcta = $1
+		UNION ALL
+		SELECT 'state_' || chamber, state, district, COALESCE(name, ''),
+		       land_area::float / SUM(land_area) OVER (PARTITION BY chamber)
+		FROM zcta_legislative_districts WHERE zcta = $1
+		ORDER BY 5 DESC, 3
+	`, zipCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up districts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var kind string
+		var d District
+		if err := rows.Scan(&kind, &d.State, &d.District, &d.Name, &d.LandShare); err != nil {
+			return nil, fmt.Errorf("failed to scan district: %w", err)
+		}
+		switch kind {
+		case KindCongressional:
+			r.Congressional = append(r.Congressional, d)
+		case KindStateUpper:
+			r.StateUpper = append(r.StateUpper, d)
+		case KindStateLower:
+			r.StateLower = append(r.StateLower, d)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to look up districts: %w", err)
+	}
+
+	if len(r.Congressional) == 0 {
+		var state string
+		var district sql.NullString
+		err := s.db.QueryRow(`
+			SELECT state, congressional_district FROM zip_coordinates WHERE zip_code = $1
+		`, zipCode).Scan(&state, &district)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, fmt.Errorf("failed to look up congressional district: %w", err)
+		}
+		if district.Valid {
+			r.Congressional = append(r.Congressional, District{State: state, District: district.String, LandShare: 1})
+		}
+	}
+
+	for _, kind := range districtKinds {
+		candidates, _ := r.candidates(kind)
+		for _, candidate := range candidates {
+			if candidate.matches(*chosen.choice(kind)) {
+				*r.Chosen.choice(kind) = candidate.District
+			}
+		}
+		if len(candidates) > 1 && *r.Chosen.choice(kind) == "" {
+			r.NeedsChoice = append(r.NeedsChoice, kind)
+		}
+	}
+
+	return r, nil
+}
+
+// CongressionalDistrictFromGEOID converts a congressional district GEOID (two
+// digit state FIPS code followed by the district number) to the district
+// stored with representatives.
+func CongressionalDistrictFromGEOID(geoid string) (string, error) {
+	if len(geoid) != 4 {
+		return "", fmt.Errorf("unexpected congressional district GEOID %q", geoid)
+	}
+	switch number := strings.TrimLeft(geoid[2:], "0"); number {
+	case "", "98":
+		// 00 is an at-large seat, 98 a non-voting delegate.
+		return AtLargeDistrict, nil
+	case "ZZ":
+		return "", fmt.Errorf("not in a congressional district")
+	default:
+		return number, nil
+	}
+}
+
+// stateFIPS maps state FIPS codes to postal abbreviations.
+var stateFIPS = map[string]string{
+	"01": "AL", "02": "AK", "04": "AZ", "05": "AR", "06": "CA", "08": "CO", "09": "CT",
+	"10": "DE", "11": "DC", "12": "FL", "13": "GA", "15": "HI", "16": "ID", "17": "IL",
+	"18": "IN", "19": "IA", "20": "KS", "21": "KY", "22": "LA", "23": "ME", "24": "MD",
+	"25": "MA", "26": "MI", "27": "MN", "28": "MS", "29": "MO", "30": "MT", "31": "NE",
+	"32": "NV", "33": "NH", "34": "NJ", "35": "NM", "36": "NY", "37": "NC", "38": "ND",
+	"39": "OH", "40": "OK", "41": "OR", "42": "PA", "44": "RI", "45": "SC", "46": "SD",
+	"47": "TN", "48": "TX", "49": "UT", "50": "VT", "51": "VA", "53": "WA", "54": "WV",
+	"55": "WI", "56": "WY", "60": "AS", "66": "GU", "69": "MP", "72": "PR", "78": "VI",
+}
+
+// relationshipFile describes the columns of a Census ZCTA relationship file.
+type relationshipFile struct {
+	kind                     string
+	zcta, geoid, name, areas int
+}
+
+// parseRelationshipHeader finds the columns of a relationship file such as
+// tab20_zcta520_cd118_natl.txt, whose district columns are named after the
+// district type: GEOID_CD118_20, GEOID_SLDU22_20 or GEOID_SLDL22_20.
+func parseRelationshipHeader(header []string) (*relationshipFile, error) {
+	f := &relationshipFile{zcta: -1, geoid: -1, name: -1, areas: -1}
+	for i, column := range header {
+		column = strings.ToUpper(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		switch {
+		case strings.HasPrefix(column, "GEOID_ZCTA5"):
+			f.zcta = i
+		case strings.HasPrefix(column, "GEOID_CD"):
+			f.kind, f.geoid = KindCongressional, i
+		case strings.HasPrefix(column, "GEOID_SLDU"):
+			f.kind, f.geoid = KindStateUpper, i
+		case strings.HasPrefix(column, "GEOID_SLDL"):
+			f.kind, f.geoid = KindStateLower, i
+		case strings.HasPrefix(column, "NAMELSAD_") && !strings.HasPrefix(column, "NAMELSAD_ZCTA5"):
+			f.name = i
+		case column == "AREALAND_PART":
+			f.areas = i
+		}
+	}
+	if f.zcta < 0 || f.geoid < 0 || f.areas < 0 {
+		return nil, fmt.Errorf("not a ZCTA to congressional or state legislative district relationship file")
+	}
+	return f, nil
+}
+
+// ImportDistrictRelationships replaces the districts of one kind with those
+// in a Census ZCTA relationship file, and returns the kind and the number of
+// ZCTA and district pairs imported. Files are pipe, comma or tab delimited.
+func (s *Service) ImportDistrictRelationships(in io.Reader) (string, int, error) {
+	// The delimiter is taken from the header line.
+	buffered := bufio.NewReader(in)
+	header, err := buffered.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", 0, fmt.Errorf("failed to read relationship file: %w", err)
+	}
+	reader := csv.NewReader(io.MultiReader(strings.NewReader(header), buffered))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	switch {
+	case strings.Contains(header, "|"):
+		reader.Comma = '|'
+	case strings.Contains(header, "\t"):
+		reader.Comma = '\t'
+	}
+
+	columns, err := reader.Read()
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read relationship file: %w", err)
+	}
+	file, err := parseRelationshipHeader(columns)
+	if err != nil {
+		return "", 0, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var stmt *sql.Stmt
+	if file.kind == KindCongressional {
+		_, err = tx.Exec("DELETE FROM zcta_congressional_districts")
+		if err == nil {
+			stmt, err = tx.Prepare(`
+				INSERT INTO zcta_congressional_districts (zcta, state, district, name, land_area)
+				VALUES ($1, $2, $3, $4, $5)
+				ON CONFLICT DO NOTHING
+			`)
+		}
+	} else {
+		chamber := ChamberUpper
+		if file.kind == KindStateLower {
+			chamber = ChamberLower
+		}
+		_, err = tx.Exec("DELETE FROM zcta_legislative_districts WHERE chamber = $1", chamber)
+		if err == nil {
+			stmt, err = tx.Prepare(`
+				INSERT INTO zcta_legislative_districts (zcta, state, chamber, district, name, land_area)
+				VALUES ($1, $2, '` + chamber + `', $3, $4, $5)
+				ON CONFLICT DO NOTHING
+			`)
+		}
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to prepare district import: %w", err)
+	}
+	defer stmt.Close()
+
+	count := 0
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", 0, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		field := func(i int) string {
+			if i < 0 || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		// Rows for district parts outside any ZCTA, or sharing only
+		// water with one, do not place anyone in the district.
+		zcta, geoid := field(file.zcta), field(file.geoid)
+		landArea, _ := strconv.ParseInt(field(file.areas), 10, 64)
+		if len(zcta) != 5 || len(geoid) < 3 || landArea <= 0 {
+			continue
+		}
+
+		state, ok := stateFIPS[geoid[:2]]
+		if !ok {
+			return "", 0, fmt.Errorf("line %d: unknown state FIPS code in GEOID %q", line, geoid)
+		}
+
+		var district string
+		if file.kind == KindCongressional {
+			district, err = CongressionalDistrictFromGEOID(geoid)
+			if err != nil {
+				continue
+			}
+		} else {
+			district = strings.TrimLeft(geoid[2:], "0")
+			if district == "" || strings.Trim(district, "Z") == "" {
+				// ZZZ marks areas without a legislative district.
+				continue
+			}
+		}
+
+		if _, err := stmt.Exec(zcta, state, district, nullString(field(file.name)), landArea); err != nil {
+			return "", 0, fmt.Errorf("line %d: failed to store district: %w", line, err)
+		}
+		count++
+	}
+
+	if count == 0 {
+		return "", 0, fmt.Errorf("relationship file contains no districts")
+	}
+	if err := tx.Commit(); err != nil {
+		return "", 0, fmt.Errorf("failed to commit districts: %w", err)
+	}
+	return file.kind, count, nil
+}
@@ -0,0 +1,163 @@
+package reps
+
+import "testing"
+
+func TestSameDistrict(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"12", "12", true},
+		{"012", "12", true},
+		{"CA-12", "12", true},
+		{"ca-12", "012", true},
+		{"AL", "al", true},
+		{" 7 ", "7", true},
+		{"0", "0", true},
+		{"12", "1", false},
+		{"CA-12", "CA-2", false},
+		{"Addison-1", "Addison-1", true},
+		{"Addison-1", "Addison-2", false},
+		{"", "12", false},
+	}
+	for _, tt := range tests {
+		if got := sameDistrict(tt.a, tt.b); got != tt.want {
+			t.Errorf("sameDistrict(%q, %q) = %t, want %t", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestDistrictName(t *testing.T) {
+	tests := map[string]string{
+		"State House District Addison-1": "addison 1",
+		"Addison-1":                      "addison 1",
+		"1st Barnstable District":        "1st barnstable",
+		"State Senate District 12":       "12",
+		"Congressional District 5":       "5",
+		"Cape and Islands District":      "cape and islands",
+		"State House District":           "",
+		"":                               "",
+	}
+	for name, want := range tests {
+		if got := districtName(name); got != want {
+			t.Errorf("districtName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func stateLower(district string) Representative {
+	return Representative{Level: LevelState, Chamber: ChamberLower, District: optionalString(district)}
+}
+
+func TestIncludes(t *testing.T) {
+	numbered := []District{
+		{State: "IL", District: "95", Name: "State House District 95", LandShare: 0.7},
+		{State: "IL", District: "96", Name: "State House District 96", LandShare: 0.3},
+	}
+	named := []District{
+		{State: "MA", District: "1", Name: "1st Barnstable District", LandShare: 0.6},
+		{State: "MA", District: "2", Name: "2nd Barnstable District", LandShare: 0.4},
+	}
+	unnamed := []District{
+		{State: "VT", District: "1", LandShare: 1},
+	}
+
+	tests := []struct {
+		name       string
+		resolution DistrictResolution
+		rep        Representative
+		want       bool
+	}{
+		{"no districts imported", DistrictResolution{}, stateLower("95"), true},
+		{"no district on the representative", DistrictResolution{StateLower: numbered}, stateLower(""), false},
+		{"no district and no districts imported", DistrictResolution{}, stateLower(""), true},
+		{"split, not chosen", DistrictResolution{StateLower: numbered}, stateLower("96"), true},
+		{"other number", DistrictResolution{StateLower: numbered}, stateLower("97"), false},
+		{"chosen", DistrictResolution{StateLower: numbered, Chosen: Districts{StateLower: "95"}}, stateLower("95"), true},
+		{"not chosen", DistrictResolution{StateLower: numbered, Chosen: Districts{StateLower: "95"}}, stateLower("96"), false},
+		{"named", DistrictResolution{StateLower: named}, stateLower("1st Barnstable"), true},
+		{"other name", DistrictResolution{StateLower: named}, stateLower("3rd Barnstable"), false},
+		{"named and chosen", DistrictResolution{StateLower: named, Chosen: Districts{StateLower: "2"}}, stateLower("2nd Barnstable"), true},
+		{"named, other chosen", DistrictResolution{StateLower: named, Chosen: Districts{StateLower: "2"}}, stateLower("1st Barnstable"), false},
+		{"named without imported names", DistrictResolution{StateLower: unnamed}, stateLower("Addison-1"), true},
+		{"numbered without imported names", DistrictResolution{StateLower: unnamed}, stateLower("2"), false},
+		{
+			"other kind of district",
+			DistrictResolution{StateLower: numbered},
+			Representative{Level: LevelState, Chamber: ChamberUpper, District: optionalString("48")},
+			true,
+		},
+		{
+			"congressional",
+			DistrictResolution{Congressional: []District{{State: "CA", District: "12", LandShare: 1}}},
+			Representative{Level: LevelFederal, Chamber: ChamberLower, District: optionalString("CA-12")},
+			true,
+		},
+		{
+			"other congressional district",
+			DistrictResolution{Congressional: []District{{State: "CA", District: "12", LandShare: 1}}},
+			Representative{Level: LevelFederal, Chamber: ChamberLower, District: optionalString("CA-11")},
+			false,
+		},
+		{
+			"at large",
+			DistrictResolution{Congressional: []District{{State: "WY", District: AtLargeDistrict, LandShare: 1}}},
+			Representative{Level: LevelFederal, Chamber: ChamberLower, District: optionalString("AL")},
+			true,
+		},
+		{
+			"senator",
+			DistrictResolution{Congressional: []District{{State: "CA", District: "12", LandShare: 1}}},
+			Representative{Level: LevelFederal, Chamber: ChamberUpper},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.resolution.Includes(tt.rep); got != tt.want {
+				t.Errorf("Includes = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	r := DistrictResolution{
+		ZipCode:    "02601",
+		StateLower: []District{{State: "MA", District: "1", Name: "1st Barnstable District"}},
+	}
+	for _, chosen := range []Districts{{}, {StateLower: "1"}, {StateLower: "1st Barnstable"}} {
+		if err := r.Check(chosen); err != nil {
+			t.Errorf("Check(%+v) = %v", chosen, err)
+		}
+	}
+	for _, chosen := range []Districts{{StateLower: "2"}, {StateUpper: "1"}} {
+		if err := r.Check(chosen); err == nil {
+			t.Errorf("Check(%+v) succeeded", chosen)
+		}
+	}
+}
+
+func TestCongressionalDistrictFromGEOID(t *testing.T) {
+	tests := []struct {
+		geoid   string
+		want    string
+		wantErr bool
+	}{
+		{"0612", "12", false},
+		{"0601", "1", false},
+		{"3610", "10", false},
+		{"5600", AtLargeDistrict, false},
+		{"1198", AtLargeDistrict, false},
+		{"06ZZ", "", true},
+		{"612", "", true},
+		{"06012", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := CongressionalDistrictFromGEOID(tt.geoid)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("CongressionalDistrictFromGEOID(%q) = %q, %v; want %q, error %t", tt.geoid, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package reps
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileSource reads representatives from a CSV file with a header row or a
+// JSON file, such as a city council directory. The file is read again on
+// every sync, so edits are picked up, and every representative in it is
+// returned whatever the location, so each needs a state.
+type FileSource struct {
+	config SourceConfig
+}
+
+func (src *FileSource) Name() string { return src.config.Name }
+
+func (src *FileSource) Representatives(ctx context.Context, location Location) ([]Representative, error) {
+	data, err := os.ReadFile(src.config.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", src.config.Path, err)
+	}
+
+	var records []map[string]interface{}
+	if strings.EqualFold(filepath.Ext(src.config.Path), ".csv") {
+		records, err = csvRecords(data)
+	} else {
+		var document interface{}
+		if err = json.Unmarshal(data, &document); err == nil {
+			records, err = recordsAt(document, src.config.Results)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid file %s: %w", src.config.Path, err)
+	}
+
+	return src.config.fromRecords(records, "")
+}
+
+// csvRecords reads the rows of a CSV file as records keyed by the header,
+// skipping the byte order mark spreadsheets tend to add.
+func csvRecords(data []byte) ([]map[string]interface{}, error) {
+	reader := csv.NewReader(strings.NewReader(strings.TrimPrefix(string(data), "\ufeff")))
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("missing header row")
+	}
+
+	header := rows[0]
+	records := make([]map[string]interface{}, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		record := make(map[string]interface{}, len(header))
+		for i, column := range header {
+			if row[i] != "" {
+				record[strings.TrimSpace(column)] = row[i]
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
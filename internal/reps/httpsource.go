@@ -0,0 +1,81 @@
+package reps
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTPSource asks a JSON API for the representatives of each location, with
+// a FieldMapping from its records to representatives. Records without a
+// state are in the location's.
+type HTTPSource struct {
+	config SourceConfig
+	client *http.Client
+}
+
+func newHTTPSource(config SourceConfig) *HTTPSource {
+	headers := make(map[string]string, len(config.Headers))
+	for name, value := range config.Headers {
+		headers[name] = os.ExpandEnv(value)
+	}
+	config.Headers = headers
+
+	return &HTTPSource{
+		config: config,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (src *HTTPSource) Name() string { return src.config.Name }
+
+func (src *HTTPSource) Representatives(ctx context.Context, location Location) ([]Representative, error) {
+	requestURL := strings.NewReplacer(
+		"{zip}", url.QueryEscape(location.ZipCode),
+		"{state}", url.QueryEscape(location.State),
+		"{lat}", strconv.FormatFloat(location.Latitude, 'f', 6, 64),
+		"{lng}", strconv.FormatFloat(location.Longitude, 'f', 6, 64),
+	).Replace(src.config.URL)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "Lettersmith/1.0")
+	for name, value := range src.config.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := src.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call %s: %w", src.config.Name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned status %d", src.config.Name, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	var document interface{}
+	if err := json.Unmarshal(body, &document); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	records, err := recordsAt(document, src.config.Results)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected response: %w", err)
+	}
+
+	return src.config.fromRecords(records, location.State)
+}
@@ -0,0 +1,109 @@
+package reps
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/yourdatasucks/lettersmith/internal/openstates"
+)
+
+// ocdState returns the state, DC or territory an OCD division or jurisdiction
+// ID lies in, such as "SC" for "ocd-division/country:us/state:sc/sldu:12", or
+// "" when it names none.
+func ocdState(id string) string {
+	for _, part := range strings.Split(id, "/") {
+		kind, code, ok := strings.Cut(part, ":")
+		if !ok || len(code) != 2 {
+			continue
+		}
+		switch kind {
+		case "state", "district", "territory":
+			return strings.ToUpper(code)
+		}
+	}
+	return ""
+}
+
+// jurisdictionLevel returns the level of government of an OpenStates
+// jurisdiction, by its classification or, without one, its ID.
+func jurisdictionLevel(classification, id string) string {
+	switch classification {
+	case "country":
+		return LevelFederal
+	case "municipality":
+		return LevelLocal
+	case "state":
+		return LevelState
+	}
+
+	switch {
+	case id == congressJurisdiction:
+		return LevelFederal
+	case strings.Contains(id, "/place:"), strings.Contains(id, "/county:"):
+		return LevelLocal
+	}
+	return LevelState
+}
+
+// BackfillFromOpenStates refetches every stored OpenStates person and stores
+// them again, correcting the state and level of rows synced before they were
+// taken from the jurisdiction. People OpenStates no longer knows are left
+// alone. It returns how many representatives were refreshed.
+func (s *Service) BackfillFromOpenStates(ctx context.Context, client *openstates.Client) (int, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT external_id, state FROM representatives
+		WHERE source = 'openstates'
+		ORDER BY id
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query representatives: %w", err)
+	}
+
+	var ids []string
+	states := map[string]string{}
+	for rows.Next() {
+		var id, state string
+		if err := rows.Scan(&id, &state); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan representative: %w", err)
+		}
+		ids = append(ids, id)
+		states[id] = state
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	people, err := client.PeopleByID(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	count := 0
+	for _, person := range people {
+		state, ok := states[person.ID]
+		if !ok {
+			continue
+		}
+		if err := upsertRepresentative(tx, fromOpenStates(person, state)); err != nil {
+			return 0, fmt.Errorf("failed to store representative %s: %w", person.Name, err)
+		}
+		count++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit representatives: %w", err)
+	}
+	return count, nil
+}
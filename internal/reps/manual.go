@@ -0,0 +1,145 @@
+package reps
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+var zipCodePattern = regexp.MustCompile(`^\d{5}$`)
+
+// normalize trims and checks the fields of a representative that did not come
+// from OpenStates or the congress-legislators dataset.
+func (r *Representative) normalize() error {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Title = strings.TrimSpace(r.Title)
+	r.State = strings.ToUpper(strings.TrimSpace(r.State))
+
+	switch {
+	case r.Name == "":
+		return fmt.Errorf("name is required")
+	case r.Title == "":
+		return fmt.Errorf("title is required")
+	case r.State != "" && len(r.State) != 2:
+		return fmt.Errorf("state must be a two-letter code")
+	}
+	switch r.Level {
+	case "", LevelFederal, LevelState, LevelLocal:
+	default:
+		return fmt.Errorf("level must be federal, state or local")
+	}
+	switch r.Chamber {
+	case "", ChamberUpper, ChamberLower:
+	default:
+		return fmt.Errorf("chamber must be upper or lower")
+	}
+	if r.Email != nil {
+		if err := checkEmail(*r.Email); err != nil {
+			return err
+		}
+	}
+	if r.Phone != nil {
+		if err := checkPhone(*r.Phone); err != nil {
+			return err
+		}
+	}
+	for _, email := range r.Emails {
+		if err := checkEmail(email); err != nil {
+			return err
+		}
+	}
+	for _, office := range r.Offices {
+		if office.Phone != "" {
+			if err := checkPhone(office.Phone); err != nil {
+				return err
+			}
+		}
+		if office.Email != "" {
+			if err := checkEmail(office.Email); err != nil {
+				return err
+			}
+		}
+	}
+
+	var zipCodes []string
+	for _, zipCode := range r.ZipCodes {
+		zipCode = strings.TrimSpace(zipCode)
+		if !zipCodePattern.MatchString(zipCode) {
+			return fmt.Errorf("invalid ZIP code %q", zipCode)
+		}
+		zipCodes = append(zipCodes, zipCode)
+	}
+	r.ZipCodes = zipCodes
+	return nil
+}
+
+// CreateRepresentative stores a recipient added by hand, such as a council
+// member or an agency's privacy officer, and fills in its ID and timestamps.
+// It is marked as manual, so syncs never change or deactivate it. Without a
+// state it is offered to every user.
+func (s *Service) CreateRepresentative(rep *Representative) error {
+	if err := rep.normalize(); err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := createRepresentative(tx, rep); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit representative: %w", err)
+	}
+
+	if rep.Offices == nil {
+		rep.Offices = []Office{}
+	}
+	if rep.Links == nil {
+		rep.Links = []Link{}
+	}
+	if rep.Emails == nil {
+		rep.Emails = []string{}
+	}
+	return nil
+}
+
+// createRepresentative inserts a normalized manual representative.
+func createRepresentative(tx *sql.Tx, rep *Representative) error {
+	for i := range rep.Offices {
+		if rep.Offices[i].Classification == "" {
+			rep.Offices[i].Classification = OfficeDistrict
+		}
+	}
+	if rep.Email != nil {
+		rep.addEmail(*rep.Email)
+	}
+	rep.flattenContacts()
+	rep.ExternalID = nil
+	rep.Source = SourceManual
+	rep.Active = true
+
+	err := tx.QueryRow(`
+		INSERT INTO representatives (name, title, state, district, party, email, phone, office_address, website,
+			level, chamber, jurisdiction, division_id, term_start, term_end, photo_url, zip_codes, source, active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, TRUE)
+		RETURNING id, created_at, updated_at
+	`, rep.Name, rep.Title, rep.State, rep.District, rep.Party, rep.Email, rep.Phone, rep.OfficeAddress, rep.Website,
+		nullString(rep.Level), nullString(rep.Chamber), rep.Jurisdiction, rep.DivisionID,
+		rep.TermStart, rep.TermEnd, rep.PhotoURL, pq.Array(rep.ZipCodes), rep.Source).Scan(&rep.ID, &rep.CreatedAt, &rep.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create representative: %w", err)
+	}
+
+	if err := saveContacts(tx, rep.ID, *rep); err != nil {
+		return err
+	}
+	return recordChange(tx, rep.ID, ChangeAdded, nil)
+}
@@ -0,0 +1,187 @@
+package reps
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FieldMapping maps representative fields to the keys of a source's records,
+// such as {"name": "full_name", "email": "contact.email"}. Keys are dotted
+// paths into nested objects. Fields that are not mapped are read from the
+// key of the same name.
+type FieldMapping map[string]string
+
+// mappedFields are the fields a FieldMapping can set.
+var mappedFields = []string{
+	"external_id", "name", "title", "state", "district", "party", "email", "phone",
+	"office_address", "website", "level", "chamber", "photo_url", "zip_codes",
+}
+
+func (m FieldMapping) check() error {
+	for field := range m {
+		known := false
+		for _, mapped := range mappedFields {
+			known = known || field == mapped
+		}
+		if !known {
+			return fmt.Errorf("unknown field %q, use one of %s", field, strings.Join(mappedFields, ", "))
+		}
+	}
+	return nil
+}
+
+func (m FieldMapping) key(field string) string {
+	if key, ok := m[field]; ok {
+		return key
+	}
+	return field
+}
+
+// representative builds a representative from a record. Its address and
+// phone become a district office, its email and website its first email and
+// link.
+func (m FieldMapping) representative(record map[string]interface{}) (Representative, error) {
+	values := map[string]string{}
+	for _, field := range mappedFields {
+		if field == "zip_codes" {
+			continue
+		}
+		value, err := recordString(record, m.key(field))
+		if err != nil {
+			return Representative{}, fmt.Errorf("%s: %w", field, err)
+		}
+		values[field] = value
+	}
+
+	zipCodes, err := recordList(record, m.key("zip_codes"))
+	if err != nil {
+		return Representative{}, fmt.Errorf("zip_codes: %w", err)
+	}
+
+	rep := Representative{
+		Name:       values["name"],
+		Title:      values["title"],
+		State:      values["state"],
+		District:   optionalString(values["district"]),
+		Party:      optionalString(values["party"]),
+		Email:      optionalString(values["email"]),
+		ExternalID: optionalString(values["external_id"]),
+		Level:      strings.ToLower(values["level"]),
+		Chamber:    strings.ToLower(values["chamber"]),
+		PhotoURL:   optionalString(values["photo_url"]),
+		ZipCodes:   zipCodes,
+	}
+	if values["office_address"] != "" || values["phone"] != "" {
+		rep.Offices = []Office{{
+			Classification: OfficeDistrict,
+			Address:        values["office_address"],
+			Phone:          values["phone"],
+		}}
+	}
+	rep.addEmail(values["email"])
+	if values["website"] != "" {
+		rep.Links = []Link{{URL: values["website"]}}
+	}
+	return rep, nil
+}
+
+// lookup returns the value at a dotted path of a record. A key containing
+// dots, like a CSV column, is matched as a whole first.
+func lookup(record map[string]interface{}, path string) interface{} {
+	if value, ok := record[path]; ok {
+		return value
+	}
+
+	var value interface{} = record
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
+
+// recordString returns the text at a path of a record, or "" when there is
+// nothing there.
+func recordString(record map[string]interface{}, path string) (string, error) {
+	text, ok := textValue(lookup(record, path))
+	if !ok {
+		return "", fmt.Errorf("%s is not text", path)
+	}
+	return text, nil
+}
+
+// textValue formats a decoded JSON value that is text, a number or a boolean.
+func textValue(value interface{}) (string, bool) {
+	switch value := value.(type) {
+	case nil:
+		return "", true
+	case string:
+		return strings.TrimSpace(value), true
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), true
+	case bool:
+		return strconv.FormatBool(value), true
+	}
+	return "", false
+}
+
+// recordList returns the list at a path of a record, given as a JSON array
+// or as text separated by commas, semicolons or spaces.
+func recordList(record map[string]interface{}, path string) ([]string, error) {
+	var list []string
+	switch value := lookup(record, path).(type) {
+	case nil:
+	case string:
+		list = strings.FieldsFunc(value, func(r rune) bool {
+			return r == ',' || r == ';' || r == ' ' || r == '\t'
+		})
+	case []interface{}:
+		for _, item := range value {
+			text, ok := textValue(item)
+			if !ok {
+				return nil, fmt.Errorf("%s holds a value that is not text", path)
+			}
+			if text != "" {
+				list = append(list, text)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%s is not a list", path)
+	}
+	return list, nil
+}
+
+// recordsAt returns the records in the list at a dotted path of a decoded
+// JSON document, or in the document itself when path is "".
+func recordsAt(document interface{}, path string) ([]map[string]interface{}, error) {
+	value := document
+	if path != "" {
+		object, ok := document.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected an object holding %s", path)
+		}
+		value = lookup(object, path)
+	}
+
+	list, ok := value.([]interface{})
+	if !ok {
+		if path == "" {
+			return nil, fmt.Errorf("expected a list of records")
+		}
+		return nil, fmt.Errorf("expected a list of records at %s", path)
+	}
+
+	records := make([]map[string]interface{}, len(list))
+	for i, item := range list {
+		record, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("record %d is not an object", i+1)
+		}
+		records[i] = record
+	}
+	return records, nil
+}
@@ -0,0 +1,202 @@
+package reps
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// UpdateRepresentative changes the UpdatableFields given in updates, as text
+// or null to clear them; other fields are ignored. On a synced
+// representative the changed fields become overrides, which later syncs
+// keep instead of the source's values.
+func (s *Service) UpdateRepresentative(id int, updates map[string]interface{}) error {
+	values := map[string]string{}
+	for _, field := range UpdatableFields {
+		value, ok := updates[field]
+		if !ok {
+			continue
+		}
+		switch value := value.(type) {
+		case nil:
+			values[field] = ""
+		case string:
+			values[field] = strings.TrimSpace(value)
+		default:
+			return fmt.Errorf("%s must be text", field)
+		}
+	}
+	if len(values) == 0 {
+		return fmt.Errorf("no valid fields to update")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := updateRepresentative(tx, id, values); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// updateRepresentative sets fields of a representative and its contacts, see
+// setField, and marks those that changed on a synced representative as
+// overridden.
+func updateRepresentative(tx *sql.Tx, id int, values map[string]string) error {
+	rep, err := scanRepresentative(tx.QueryRow(`
+		SELECT `+representativeColumns+` FROM representatives WHERE id = $1 FOR UPDATE
+	`, id))
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("representative not found")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read representative: %w", err)
+	}
+	list := []Representative{rep}
+	if err := loadContacts(tx, list); err != nil {
+		return err
+	}
+	rep = list[0]
+
+	current := exportValues(rep)
+	for _, field := range UpdatableFields {
+		value, ok := values[field]
+		if !ok || value == current[field] {
+			continue
+		}
+		switch {
+		case (field == "name" || field == "title") && value == "":
+			return fmt.Errorf("%s is required", field)
+		case field == "email" && value != "":
+			if err := checkEmail(value); err != nil {
+				return err
+			}
+		case field == "phone" && value != "":
+			if err := checkPhone(value); err != nil {
+				return err
+			}
+		}
+
+		rep.setField(field, value, current[field])
+		if rep.Source != SourceManual && !contains(rep.Overridden, field) {
+			rep.Overridden = append(rep.Overridden, field)
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE representatives SET name = $2, title = $3, district = $4, party = $5, email = $6, phone = $7,
+			office_address = $8, website = $9, overridden_fields = $10, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, id, rep.Name, rep.Title, rep.District, rep.Party, rep.Email, rep.Phone, rep.OfficeAddress, rep.Website,
+		pq.Array(overriddenFields(rep.Overridden)))
+	if err != nil {
+		return fmt.Errorf("failed to update representative: %w", err)
+	}
+	return saveContacts(tx, id, rep)
+}
+
+// applyOverrides replaces the fields of a synced representative that an
+// administrator overrode by the values stored in previous.
+func (r *Representative) applyOverrides(previous Representative) {
+	stored := exportValues(previous)
+	current := exportValues(*r)
+	r.Offices = append([]Office(nil), r.Offices...)
+	for _, field := range previous.Overridden {
+		if stored[field] != current[field] {
+			r.setField(field, stored[field], current[field])
+		}
+	}
+	r.Overridden = previous.Overridden
+}
+
+// setField sets one of UpdatableFields, replacing old. An email or website
+// also becomes the first of the representative's emails or links, and a
+// phone or address replaces old in its offices, or is set on the preferred
+// office when no office has old. An empty value clears the field.
+func (r *Representative) setField(field, value, old string) {
+	switch field {
+	case "name":
+		r.Name = value
+	case "title":
+		r.Title = value
+	case "district":
+		r.District = optionalString(value)
+	case "party":
+		r.Party = optionalString(value)
+	case "email":
+		r.Email = optionalString(value)
+		emails := []string{}
+		if value != "" {
+			emails = append(emails, value)
+		}
+		for _, email := range r.Emails {
+			if email != value && email != old {
+				emails = append(emails, email)
+			}
+		}
+		r.Emails = emails
+	case "website":
+		r.Website = optionalString(value)
+		links := []Link{}
+		if value != "" {
+			links = append(links, Link{URL: value})
+		}
+		for _, link := range r.Links {
+			if link.URL != value && link.URL != old {
+				links = append(links, link)
+			}
+		}
+		r.Links = links
+	case "phone":
+		r.Phone = optionalString(value)
+		r.setOfficeField(func(office *Office) *string { return &office.Phone }, value, old)
+	case "office_address":
+		r.OfficeAddress = optionalString(value)
+		r.setOfficeField(func(office *Office) *string { return &office.Address }, value, old)
+	}
+}
+
+func (r *Representative) setOfficeField(field func(*Office) *string, value, old string) {
+	replaced := false
+	for i := range r.Offices {
+		if old != "" && *field(&r.Offices[i]) == old {
+			*field(&r.Offices[i]) = value
+			replaced = true
+		}
+	}
+	if replaced || value == "" {
+		return
+	}
+
+	office := r.PreferredOffice()
+	if office == nil && len(r.Offices) > 0 {
+		office = &r.Offices[0]
+	}
+	if office == nil {
+		r.Offices = append(r.Offices, Office{Classification: OfficeDistrict})
+		office = &r.Offices[len(r.Offices)-1]
+	}
+	*field(office) = value
+}
+
+// overriddenFields returns fields for the NOT NULL overridden_fields column.
+func overriddenFields(fields []string) []string {
+	if fields == nil {
+		return []string{}
+	}
+	return fields
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
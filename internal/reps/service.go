@@ -2,22 +2,55 @@ package reps
 
 import (
 	"database/sql"
-	"encoding/json"
 	"fmt"
-	"net/http"
 	"strconv"
 	"strings"
-	"time"
+
+	"github.com/lib/pq"
+	"github.com/yourdatasucks/lettersmith/internal/openstates"
 )
 
-func (s *Service) GetUserRepresentatives(userZip string) ([]Representative, error) {
+// representativeColumns are the columns scanRepresentative reads, in order.
+const representativeColumns = `id, name, title, state, district, party, email, phone,
+		office_address, website, external_id, COALESCE(level, ''), COALESCE(chamber, ''),
+		jurisdiction, division_id, to_char(term_start, 'YYYY-MM-DD'), to_char(term_end, 'YYYY-MM-DD'), photo_url,
+		zip_codes, source, overridden_fields, active, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRepresentative(row rowScanner) (Representative, error) {
+	var rep Representative
+	err := row.Scan(
+		&rep.ID, &rep.Name, &rep.Title, &rep.State, &rep.District, &rep.Party,
+		&rep.Email, &rep.Phone, &rep.OfficeAddress, &rep.Website, &rep.ExternalID,
+		&rep.Level, &rep.Chamber, &rep.Jurisdiction, &rep.DivisionID, &rep.TermStart, &rep.TermEnd, &rep.PhotoURL,
+		pq.Array(&rep.ZipCodes), &rep.Source, pq.Array(&rep.Overridden), &rep.Active, &rep.CreatedAt, &rep.UpdatedAt,
+	)
+	return rep, err
+}
+
+// GetUserRepresentatives returns the active representatives of userZip: the
+// senators of its state and the legislators of the districts it overlaps, see
+// ResolveDistricts. Where a ZIP code is split, the district in chosen is used
+// and without a choice the representatives of every overlapping district are
+// returned. Manual recipients without a state are returned for every ZIP
+// code, and representatives limited to some ZIP codes only for those.
+func (s *Service) GetUserRepresentatives(userZip string, chosen Districts) ([]Representative, error) {
+	districts, err := s.ResolveDistricts(userZip, chosen)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-		SELECT id, name, title, state, district, party, email, phone, 
-		       office_address, website, external_id, created_at, updated_at
-		FROM representatives 
-		WHERE state = (
-			SELECT state FROM zip_coordinates WHERE zip_code = $1 LIMIT 1
+		SELECT ` + representativeColumns + `
+		FROM representatives
+		WHERE active AND (
+			state = (SELECT state FROM zip_coordinates WHERE zip_code = $1 LIMIT 1)
+			OR (source = 'manual' AND state = '')
 		)
+		AND (zip_codes IS NULL OR $1 = ANY(zip_codes))
 		ORDER BY title, name
 	`
 
@@ -29,86 +62,117 @@ func (s *Service) GetUserRepresentatives(userZip string) ([]Representative, erro
 
 	var representatives []Representative
 	for rows.Next() {
-		var rep Representative
-		err := rows.Scan(
-			&rep.ID, &rep.Name, &rep.Title, &rep.State, &rep.District, &rep.Party,
-			&rep.Email, &rep.Phone, &rep.OfficeAddress, &rep.Website, &rep.ExternalID,
-			&rep.CreatedAt, &rep.UpdatedAt,
-		)
+		rep, err := scanRepresentative(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan representative: %w", err)
 		}
-		representatives = append(representatives, rep)
+		if districts.Includes(rep) {
+			representatives = append(representatives, rep)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
+	if err := s.loadContacts(representatives); err != nil {
+		return nil, err
+	}
 	return representatives, nil
 }
 
-func (s *Service) SyncFromOpenStates(latitude, longitude float64, apiKey, userState string) error {
-	url := fmt.Sprintf("https://v3.openstates.org/people.geo?lat=%f&lng=%f", latitude, longitude)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+// fromOpenStates maps an OpenStates person to a representative. Their state
+// and level come from the division and jurisdiction they serve; userState is
+// only used when OpenStates gives neither.
+func fromOpenStates(osRep openstates.Person, userState string) Representative {
+	rep := Representative{
+		Name:       osRep.Name,
+		State:      userState,
+		Party:      optionalString(osRep.Party),
+		ExternalID: optionalString(osRep.ID),
+		PhotoURL:   optionalString(osRep.Image),
+		Level:      LevelState,
+		Source:     SourceOpenStates,
 	}
 
-	req.Header.Set("X-API-KEY", apiKey)
-	req.Header.Set("User-Agent", "Lettersmith/1.0")
+	var jurisdictionID string
+	if osRep.Jurisdiction != nil {
+		jurisdictionID = osRep.Jurisdiction.ID
+		rep.Jurisdiction = optionalString(jurisdictionID)
+		rep.Level = jurisdictionLevel(osRep.Jurisdiction.Classification, jurisdictionID)
+	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to call OpenStates API: %w", err)
+	var divisionID string
+	if osRep.CurrentRole != nil {
+		divisionID = osRep.CurrentRole.DivisionID
+		rep.DivisionID = optionalString(divisionID)
+	}
+	if state := ocdState(divisionID); state != "" {
+		rep.State = state
+	} else if state := ocdState(jurisdictionID); state != "" {
+		rep.State = state
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("OpenStates API returned status %d", resp.StatusCode)
+	if osRep.CurrentRole != nil {
+		rep.Title = osRep.CurrentRole.Title
+		rep.District = optionalString(osRep.CurrentRole.District)
+		switch osRep.CurrentRole.OrgClassification {
+		case ChamberUpper, "legislature":
+			// Nebraska's unicameral legislature is made up of senators.
+			rep.Chamber = ChamberUpper
+		case ChamberLower:
+			rep.Chamber = ChamberLower
+		}
 	}
 
-	var apiResponse OpenStatesResponse
-	if err := json.NewDecoder(resp.Body).Decode(&apiResponse); err != nil {
-		return fmt.Errorf("failed to decode API response: %w", err)
+	for _, office := range osRep.Offices {
+		classification := office.Classification
+		if classification == "" {
+			classification = OfficeDistrict
+		}
+		rep.Offices = append(rep.Offices, Office{
+			Classification: classification,
+			Name:           office.Name,
+			Address:        office.Address,
+			Phone:          office.Voice,
+			Fax:            office.Fax,
+		})
 	}
 
-	for _, osRep := range apiResponse.Results {
-		if err := s.upsertRepresentative(osRep, userState); err != nil {
-			return fmt.Errorf("failed to store representative %s: %w", osRep.Name, err)
+	for _, link := range osRep.Links {
+		if link.URL != "" {
+			rep.Links = append(rep.Links, Link{URL: link.URL, Note: link.Note})
 		}
 	}
 
-	return nil
+	rep.addEmail(osRep.Email)
+	rep.flattenContacts()
+	return rep
 }
 
-func (s *Service) upsertRepresentative(osRep OpenStatesRep, userState string) error {
-	var district, email, website, phone, address string
-
-	party := osRep.Party
-
-	if osRep.CurrentRole != nil {
-		district = osRep.CurrentRole.District
+// upsertRepresentative inserts rep or updates the row with its external ID,
+// replacing its offices, links and emails. Fields an administrator overrode
+// keep their stored values. It marks the representative as seen and active,
+// and records what changed in the changelog. A manual representative with
+// the same external ID is left as it is.
+func upsertRepresentative(tx *sql.Tx, rep Representative) error {
+	previous, err := scanRepresentative(tx.QueryRow(`
+		SELECT `+representativeColumns+` FROM representatives WHERE external_id = $1 FOR UPDATE
+	`, rep.ExternalID))
+	existed := err == nil
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to read representative: %w", err)
 	}
-
-	email = osRep.Email
-
-	if len(osRep.Links) > 0 {
-		website = osRep.Links[0].URL
+	if existed && previous.Source == SourceManual {
+		return nil
 	}
-
-	if len(osRep.Offices) > 0 {
-		phone = osRep.Offices[0].Phone
-		address = osRep.Offices[0].Address
-	}
-
-	state := userState
-	title := ""
-	if osRep.CurrentRole != nil {
-		title = osRep.CurrentRole.Title
+	if existed && len(previous.Overridden) > 0 {
+		rep.applyOverrides(previous)
 	}
 
 	query := `
-		INSERT INTO representatives (name, title, state, district, party, email, phone, office_address, website, external_id)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		INSERT INTO representatives (name, title, state, district, party, email, phone, office_address, website,
+			external_id, level, chamber, jurisdiction, division_id, term_start, term_end, photo_url, zip_codes, source, active, last_seen_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, TRUE, CURRENT_TIMESTAMP)
 		ON CONFLICT (external_id) DO UPDATE SET
 			name = EXCLUDED.name,
 			title = EXCLUDED.title,
@@ -119,65 +183,49 @@ func (s *Service) upsertRepresentative(osRep OpenStatesRep, userState string) er
 			phone = EXCLUDED.phone,
 			office_address = EXCLUDED.office_address,
 			website = EXCLUDED.website,
+			level = EXCLUDED.level,
+			chamber = EXCLUDED.chamber,
+			jurisdiction = EXCLUDED.jurisdiction,
+			division_id = EXCLUDED.division_id,
+			term_start = EXCLUDED.term_start,
+			term_end = EXCLUDED.term_end,
+			photo_url = EXCLUDED.photo_url,
+			zip_codes = EXCLUDED.zip_codes,
+			active = TRUE,
+			last_seen_at = CURRENT_TIMESTAMP,
 			updated_at = CURRENT_TIMESTAMP
+		RETURNING id
 	`
 
-	_, err := s.db.Exec(query, osRep.Name, title, state,
-		nullString(district), nullString(party), nullString(email),
-		nullString(phone), nullString(address), nullString(website),
-		nullString(osRep.ID))
-
-	return err
-}
-
-func (s *Service) UpdateRepresentative(id int, updates map[string]interface{}) error {
-	setParts := []string{}
-	args := []interface{}{}
-	argIndex := 1
-
-	allowedFields := map[string]bool{
-		"name": true, "title": true, "district": true, "party": true,
-		"email": true, "phone": true, "office_address": true, "website": true,
-	}
-
-	for field, value := range updates {
-		if !allowedFields[field] {
-			continue
-		}
-		setParts = append(setParts, fmt.Sprintf("%s = $%d", field, argIndex))
-		args = append(args, value)
-		argIndex++
-	}
-
-	if len(setParts) == 0 {
-		return fmt.Errorf("no valid fields to update")
-	}
-
-	setParts = append(setParts, fmt.Sprintf("updated_at = $%d", argIndex))
-	args = append(args, time.Now())
-	argIndex++
-
-	query := fmt.Sprintf("UPDATE representatives SET %s WHERE id = $%d",
-		strings.Join(setParts, ", "), argIndex)
-	args = append(args, id)
-
-	result, err := s.db.Exec(query, args...)
+	var id int
+	err = tx.QueryRow(query, rep.Name, rep.Title, rep.State,
+		rep.District, rep.Party, rep.Email, rep.Phone, rep.OfficeAddress, rep.Website,
+		rep.ExternalID, nullString(rep.Level), nullString(rep.Chamber),
+		rep.Jurisdiction, rep.DivisionID, rep.TermStart, rep.TermEnd, rep.PhotoURL, pq.Array(rep.ZipCodes), rep.Source).Scan(&id)
 	if err != nil {
-		return fmt.Errorf("failed to update representative: %w", err)
+		return err
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+	if err := saveContacts(tx, id, rep); err != nil {
+		return err
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("representative not found")
+	switch {
+	case !existed:
+		return recordChange(tx, id, ChangeAdded, nil)
+	case !previous.Active:
+		return recordChange(tx, id, ChangeReturned, diffRepresentatives(previous, rep))
+	default:
+		if fields := diffRepresentatives(previous, rep); len(fields) > 0 {
+			return recordChange(tx, id, ChangeUpdated, fields)
+		}
 	}
-
 	return nil
 }
 
+// UpdatableFields are the fields UpdateRepresentative and imports change.
+var UpdatableFields = []string{"name", "title", "district", "party", "email", "phone", "office_address", "website"}
+
 func (s *Service) DeleteRepresentative(id int) error {
 	query := "DELETE FROM representatives WHERE id = $1"
 	result, err := s.db.Exec(query, id)
@@ -198,19 +246,9 @@ func (s *Service) DeleteRepresentative(id int) error {
 }
 
 func (s *Service) GetRepresentativeByID(id int) (*Representative, error) {
-	query := `
-		SELECT id, name, title, state, district, party, email, phone, 
-		       office_address, website, external_id, created_at, updated_at
-		FROM representatives WHERE id = $1
-	`
-
-	var rep Representative
-	err := s.db.QueryRow(query, id).Scan(
-		&rep.ID, &rep.Name, &rep.Title, &rep.State, &rep.District, &rep.Party,
-		&rep.Email, &rep.Phone, &rep.OfficeAddress, &rep.Website, &rep.ExternalID,
-		&rep.CreatedAt, &rep.UpdatedAt,
-	)
+	query := `SELECT ` + representativeColumns + ` FROM representatives WHERE id = $1`
 
+	rep, err := scanRepresentative(s.db.QueryRow(query, id))
 	if err == sql.ErrNoRows {
 		return nil, fmt.Errorf("representative not found")
 	}
@@ -218,7 +256,11 @@ func (s *Service) GetRepresentativeByID(id int) (*Representative, error) {
 		return nil, fmt.Errorf("failed to get representative: %w", err)
 	}
 
-	return &rep, nil
+	representatives := []Representative{rep}
+	if err := s.loadContacts(representatives); err != nil {
+		return nil, err
+	}
+	return &representatives[0], nil
 }
 
 func nullString(s string) interface{} {
@@ -228,6 +270,15 @@ func nullString(s string) interface{} {
 	return s
 }
 
+// optionalString returns nil for an empty string, for the nullable fields of
+// Representative.
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
 func ExtractIDFromPath(path string) (int, error) {
 	parts := strings.Split(strings.Trim(path, "/"), "/")
 	if len(parts) < 3 {
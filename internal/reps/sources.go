@@ -0,0 +1,222 @@
+package reps
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/yourdatasucks/lettersmith/internal/openstates"
+)
+
+// Location is where representatives are looked up for: a user's ZIP code
+// and the coordinates of their address or ZIP code.
+type Location struct {
+	ZipCode   string
+	State     string
+	Latitude  float64
+	Longitude float64
+}
+
+// RepresentativeSource supplies the representatives serving a location.
+// Every representative it returns needs an external ID that is stable across
+// syncs and unique among sources; Name is stored as their source.
+type RepresentativeSource interface {
+	Name() string
+	Representatives(ctx context.Context, location Location) ([]Representative, error)
+}
+
+// SyncFromSource stores the representatives src returns for location and
+// returns how many there were.
+func (s *Service) SyncFromSource(ctx context.Context, src RepresentativeSource, location Location) (int, error) {
+	representatives, err := src.Representatives(ctx, location)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", src.Name(), err)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, rep := range representatives {
+		if rep.ExternalID == nil {
+			return 0, fmt.Errorf("%s: representative %s has no external ID", src.Name(), rep.Name)
+		}
+		rep.Source = src.Name()
+		if err := upsertRepresentative(tx, rep); err != nil {
+			return 0, fmt.Errorf("failed to store representative %s: %w", rep.Name, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit representatives: %w", err)
+	}
+	return len(representatives), nil
+}
+
+// OpenStatesSource finds the legislators at a location with OpenStates.
+// Members of Congress are skipped unless IncludeFederal is set, so that they
+// are not duplicated when another source provides them.
+type OpenStatesSource struct {
+	Client         *openstates.Client
+	IncludeFederal bool
+}
+
+func (OpenStatesSource) Name() string { return SourceOpenStates }
+
+func (src OpenStatesSource) Representatives(ctx context.Context, location Location) ([]Representative, error) {
+	people, err := src.Client.PeopleGeo(ctx, location.Latitude, location.Longitude)
+	if err != nil {
+		return nil, err
+	}
+
+	var representatives []Representative
+	for _, person := range people {
+		rep := fromOpenStates(person, location.State)
+		if rep.Level == LevelFederal && !src.IncludeFederal {
+			continue
+		}
+		representatives = append(representatives, rep)
+	}
+	return representatives, nil
+}
+
+// SourceConfig describes a source in the sources file: a CSV or JSON file of
+// representatives ("file") or a JSON API ("http").
+type SourceConfig struct {
+	// Name is stored as the source of its representatives and prefixes
+	// their external IDs, so it must not change once synced.
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Path is the file of a file source, CSV when it ends in .csv and JSON
+	// otherwise.
+	Path string `json:"path,omitempty"`
+	// URL is the endpoint of an HTTP source. {zip}, {state}, {lat} and {lng}
+	// are replaced by the location being synced.
+	URL string `json:"url,omitempty"`
+	// Headers are sent with every request to an HTTP source, with
+	// environment variables like ${TOKEN} expanded.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Results is the dotted path of the list of records in a JSON response
+	// or file, or "" when it is the list itself.
+	Results string       `json:"results,omitempty"`
+	Fields  FieldMapping `json:"fields,omitempty"`
+	// State and Level are used for records without one. Level defaults to
+	// local.
+	State string `json:"state,omitempty"`
+	Level string `json:"level,omitempty"`
+}
+
+var sourceNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,19}$`)
+
+// LoadSources reads the list of file and HTTP sources in a JSON file.
+func LoadSources(path string) ([]RepresentativeSource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sources file: %w", err)
+	}
+
+	var configs []SourceConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("invalid sources file %s: %w", path, err)
+	}
+
+	names := map[string]bool{}
+	sources := make([]RepresentativeSource, 0, len(configs))
+	for _, config := range configs {
+		switch {
+		case !sourceNamePattern.MatchString(config.Name):
+			return nil, fmt.Errorf("invalid source name %q: use up to 20 lowercase letters, digits, - and _", config.Name)
+		case config.Name == SourceOpenStates || config.Name == SourceCongress || config.Name == SourceManual:
+			return nil, fmt.Errorf("source name %q is reserved", config.Name)
+		case names[config.Name]:
+			return nil, fmt.Errorf("source %q is listed twice", config.Name)
+		}
+		names[config.Name] = true
+
+		if err := config.Fields.check(); err != nil {
+			return nil, fmt.Errorf("source %s: %w", config.Name, err)
+		}
+		if config.Level == "" {
+			config.Level = LevelLocal
+		}
+
+		var source RepresentativeSource
+		switch config.Type {
+		case "file":
+			if config.Path == "" {
+				return nil, fmt.Errorf("source %s: path is required", config.Name)
+			}
+			source = &FileSource{config: config}
+		case "http":
+			if config.URL == "" {
+				return nil, fmt.Errorf("source %s: url is required", config.Name)
+			}
+			source = newHTTPSource(config)
+		default:
+			return nil, fmt.Errorf("source %s: unknown type %q, use file or http", config.Name, config.Type)
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+// fromRecords maps the records of a file or HTTP source to representatives.
+// Records without a state are in the source's state or else in
+// fallbackState. Records without an external ID get one from their state,
+// title and name, and every ID is prefixed with the source's name.
+func (config SourceConfig) fromRecords(records []map[string]interface{}, fallbackState string) ([]Representative, error) {
+	representatives := make([]Representative, 0, len(records))
+	for i, record := range records {
+		rep, err := config.Fields.representative(record)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", i+1, err)
+		}
+		if rep.State == "" {
+			rep.State = config.State
+		}
+		if rep.State == "" {
+			rep.State = fallbackState
+		}
+		if rep.Level == "" {
+			rep.Level = config.Level
+		}
+		if err := rep.normalize(); err != nil {
+			return nil, fmt.Errorf("record %d: %w", i+1, err)
+		}
+		if rep.State == "" {
+			return nil, fmt.Errorf("record %d: state is required, in the record or the source", i+1)
+		}
+
+		id := stringValue(rep.ExternalID)
+		if id == "" {
+			id = slug(rep.State + " " + rep.Title + " " + rep.Name)
+			if max := maxExternalIDLength - len(config.Name) - 1; len(id) > max {
+				id = strings.TrimRight(id[:max], "-")
+			}
+		}
+		id = config.Name + "/" + id
+		if len(id) > maxExternalIDLength {
+			return nil, fmt.Errorf("record %d: external ID %q is longer than %d characters", i+1, id, maxExternalIDLength)
+		}
+		rep.ExternalID = &id
+		rep.flattenContacts()
+		representatives = append(representatives, rep)
+	}
+	return representatives, nil
+}
+
+// maxExternalIDLength is the size of the external_id column.
+const maxExternalIDLength = 100
+
+// slug turns text into lowercase words joined by dashes, for external IDs.
+func slug(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	})
+	return strings.Join(words, "-")
+}
@@ -17,38 +17,57 @@ type Representative struct {
 	OfficeAddress *string   `json:"office_address,omitempty"`
 	Website       *string   `json:"website,omitempty"`
 	ExternalID    *string   `json:"external_id,omitempty"`
+	Level         string    `json:"level,omitempty"`   // federal, state or local
+	Chamber       string    `json:"chamber,omitempty"` // upper or lower
+	Jurisdiction  *string   `json:"jurisdiction,omitempty"`
+	DivisionID    *string   `json:"division_id,omitempty"`
+	TermStart     *string   `json:"term_start,omitempty"` // YYYY-MM-DD
+	TermEnd       *string   `json:"term_end,omitempty"`
+	PhotoURL      *string   `json:"photo_url,omitempty"`
+	Offices       []Office  `json:"offices"`
+	Links         []Link    `json:"links"`
+	Emails        []string  `json:"emails"`
+	ZipCodes      []string  `json:"zip_codes,omitempty"` // only offered in these ZIP codes when set
+	Source        string    `json:"source"`              // openstates, congress, manual or a configured source
+	Overridden    []string  `json:"overridden_fields,omitempty"`
+	Active        bool      `json:"active"`
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
 }
 
-type OpenStatesResponse struct {
-	Results []OpenStatesRep `json:"results"`
+// Office is one of a representative's offices. Email, Phone and
+// OfficeAddress of Representative are taken from the preferred one, see
+// PreferredOffice.
+type Office struct {
+	Classification string `json:"classification"` // capitol or district
+	Name           string `json:"name,omitempty"`
+	Address        string `json:"address,omitempty"`
+	Phone          string `json:"phone,omitempty"`
+	Fax            string `json:"fax,omitempty"`
+	Email          string `json:"email,omitempty"`
 }
 
-type OpenStatesRep struct {
-	ID          string             `json:"id"`
-	Name        string             `json:"name"`
-	Party       string             `json:"party"`
-	CurrentRole *OpenStatesRole    `json:"current_role"`
-	Email       string             `json:"email"`
-	Links       []OpenStatesLink   `json:"links"`
-	Offices     []OpenStatesOffice `json:"offices"`
+type Link struct {
+	URL  string `json:"url"`
+	Note string `json:"note,omitempty"`
 }
 
-type OpenStatesRole struct {
-	Title             string `json:"title"`
-	OrgClassification string `json:"org_classification"`
-	District          string `json:"district"`
-}
+const (
+	LevelFederal = "federal"
+	LevelState   = "state"
+	LevelLocal   = "local"
 
-type OpenStatesLink struct {
-	URL string `json:"url"`
-}
+	ChamberUpper = "upper"
+	ChamberLower = "lower"
 
-type OpenStatesOffice struct {
-	Address string `json:"address"`
-	Phone   string `json:"phone"`
-}
+	OfficeCapitol  = "capitol"
+	OfficeDistrict = "district"
+
+	SourceOpenStates = "openstates"
+	SourceCongress   = "congress"
+	// Manual representatives are added by hand and never changed by a sync.
+	SourceManual = "manual"
+)
 
 type Service struct {
 	db *sql.DB
@@ -0,0 +1,76 @@
+package secrets
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces a secret in redacted output.
+const Redacted = "[REDACTED]"
+
+// minRedactLength keeps very short values, which would match ordinary text,
+// from being redacted.
+const minRedactLength = 6
+
+// Redactor is an io.Writer that masks known secret values before passing
+// output on, so it can sit behind the standard logger.
+type Redactor struct {
+	out io.Writer
+
+	mu       sync.RWMutex
+	replacer *strings.Replacer
+}
+
+// NewRedactor returns a Redactor writing to out. It masks nothing until Set
+// is called.
+func NewRedactor(out io.Writer) *Redactor {
+	return &Redactor{out: out}
+}
+
+// Set replaces the secret values to mask.
+func (r *Redactor) Set(values []string) {
+	var secrets []string
+	for _, value := range values {
+		if len(value) >= minRedactLength {
+			secrets = append(secrets, value)
+		}
+	}
+	// Longest first, so a secret containing another is masked whole.
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+
+	var replacer *strings.Replacer
+	if len(secrets) > 0 {
+		pairs := make([]string, 0, len(secrets)*2)
+		for _, secret := range secrets {
+			pairs = append(pairs, secret, Redacted)
+		}
+		replacer = strings.NewReplacer(pairs...)
+	}
+
+	r.mu.Lock()
+	r.replacer = replacer
+	r.mu.Unlock()
+}
+
+// Redact masks every known secret in s.
+func (r *Redactor) Redact(s string) string {
+	r.mu.RLock()
+	replacer := r.replacer
+	r.mu.RUnlock()
+
+	if replacer == nil {
+		return s
+	}
+	return replacer.Replace(s)
+}
+
+// Write masks secrets in p and writes the result. It reports len(p) on
+// success, since callers wrote p, not the redacted text.
+func (r *Redactor) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.out, r.Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,151 @@
+// Package secrets encrypts API keys and passwords at rest and keeps them out
+// of logs.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Environment variables holding the master key, either directly or as the
+// path of a file containing it.
+const (
+	MasterKeyEnv     = "SECRETS_MASTER_KEY"
+	MasterKeyFileEnv = "SECRETS_MASTER_KEY_FILE"
+)
+
+// prefix marks an encrypted value. The version allows the format to change
+// without breaking values already written.
+const prefix = "enc:v1:"
+
+// ErrNoKey is returned when a secret must be encrypted or decrypted but no
+// master key is configured.
+var ErrNoKey = errors.New("no master key configured: set " + MasterKeyEnv + " or " + MasterKeyFileEnv)
+
+// Box encrypts and decrypts secrets with AES-256-GCM. A nil Box has no key:
+// it refuses to encrypt and can only pass through values that are not
+// encrypted.
+type Box struct {
+	aead cipher.AEAD
+}
+
+// NewBox returns a Box using a 32-byte master key.
+func NewBox(key []byte) (*Box, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("master key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+// LoadBox reads the master key from SECRETS_MASTER_KEY or the file named by
+// SECRETS_MASTER_KEY_FILE. It returns a nil Box when neither is set.
+func LoadBox(getenv func(string) string) (*Box, error) {
+	encoded := getenv(MasterKeyEnv)
+	if path := getenv(MasterKeyFileEnv); encoded == "" && path != "" {
+		value, err := ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read master key: %w", err)
+		}
+		encoded = value
+	}
+	if encoded == "" {
+		return nil, nil
+	}
+
+	key, err := decodeKey(encoded)
+	if err != nil {
+		return nil, err
+	}
+	return NewBox(key)
+}
+
+// GenerateKey returns a new random master key, base64-encoded.
+func GenerateKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// decodeKey accepts a key as 64 hex digits or as base64.
+func decodeKey(encoded string) ([]byte, error) {
+	if len(encoded) == 64 {
+		if key, err := hex.DecodeString(encoded); err == nil {
+			return key, nil
+		}
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("master key must be base64 or hex encoded")
+	}
+	return key, nil
+}
+
+// IsEncrypted reports whether value was produced by Encrypt.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// Encrypt encrypts the secret stored under name. The name is authenticated
+// along with the value, so an encrypted value only decrypts under the name it
+// was written for.
+func (b *Box) Encrypt(name, plaintext string) (string, error) {
+	if b == nil {
+		return "", ErrNoKey
+	}
+
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), []byte(name))
+	return prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt returns the plaintext of a value produced by Encrypt for the same
+// name. Values that are not encrypted are returned unchanged.
+func (b *Box) Decrypt(name, value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if b == nil {
+		return "", fmt.Errorf("%s is encrypted: %w", name, ErrNoKey)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, prefix))
+	if err != nil || len(sealed) < b.aead.NonceSize() {
+		return "", fmt.Errorf("%s is not a valid encrypted value", name)
+	}
+	nonce, ciphertext := sealed[:b.aead.NonceSize()], sealed[b.aead.NonceSize():]
+	plaintext, err := b.aead.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s: wrong master key or corrupted value", name)
+	}
+	return string(plaintext), nil
+}
+
+// ReadFile reads a secret from a file such as a Docker secret, dropping the
+// trailing newline most editors add.
+func ReadFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
@@ -0,0 +1,214 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testKey() []byte {
+	return bytes.Repeat([]byte{7}, 32)
+}
+
+func testBox(t *testing.T) *Box {
+	t.Helper()
+	box, err := NewBox(testKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return box
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	box := testBox(t)
+
+	for _, plaintext := range []string{"sk-test-123456", "", "pässwörd with spaces"} {
+		encrypted, err := box.Encrypt("OPENAI_API_KEY", plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !IsEncrypted(encrypted) {
+			t.Errorf("Encrypt(%q) = %q, want the %s prefix", plaintext, encrypted, prefix)
+		}
+		if plaintext != "" && strings.Contains(encrypted, plaintext) {
+			t.Errorf("Encrypt(%q) = %q contains the plaintext", plaintext, encrypted)
+		}
+
+		decrypted, err := box.Decrypt("OPENAI_API_KEY", encrypted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decrypted != plaintext {
+			t.Errorf("Decrypt = %q, want %q", decrypted, plaintext)
+		}
+	}
+}
+
+func TestEncryptUsesFreshNonce(t *testing.T) {
+	box := testBox(t)
+	a, _ := box.Encrypt("KEY", "same value")
+	b, _ := box.Encrypt("KEY", "same value")
+	if a == b {
+		t.Error("encrypting the same value twice gave the same ciphertext")
+	}
+}
+
+func TestDecryptChecks(t *testing.T) {
+	box := testBox(t)
+	encrypted, err := box.Encrypt("SMTP_PASSWORD", "hunter22")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherKey := bytes.Repeat([]byte{8}, 32)
+	otherBox, err := NewBox(otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		box   *Box
+		key   string
+		value string
+	}{
+		{"wrong name", box, "SENDGRID_API_KEY", encrypted},
+		{"wrong key", otherBox, "SMTP_PASSWORD", encrypted},
+		{"tampered", box, "SMTP_PASSWORD", encrypted[:len(encrypted)-4] + "AAAA"},
+		{"not base64", box, "SMTP_PASSWORD", prefix + "!!!"},
+		{"too short", box, "SMTP_PASSWORD", prefix + base64.StdEncoding.EncodeToString([]byte("abc"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := tt.box.Decrypt(tt.key, tt.value); err == nil {
+				t.Errorf("Decrypt = %q, want an error", got)
+			}
+		})
+	}
+}
+
+func TestNilBox(t *testing.T) {
+	var box *Box
+
+	if got, err := box.Decrypt("KEY", "plain value"); err != nil || got != "plain value" {
+		t.Errorf("Decrypt of a plain value = %q, %v; want it unchanged", got, err)
+	}
+	if _, err := box.Encrypt("KEY", "value"); !errors.Is(err, ErrNoKey) {
+		t.Errorf("Encrypt error = %v, want ErrNoKey", err)
+	}
+
+	encrypted, err := testBox(t).Encrypt("KEY", "value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := box.Decrypt("KEY", encrypted); !errors.Is(err, ErrNoKey) {
+		t.Errorf("Decrypt of an encrypted value error = %v, want ErrNoKey", err)
+	}
+}
+
+func TestNewBoxKeyLength(t *testing.T) {
+	for _, n := range []int{0, 16, 31, 33, 64} {
+		if _, err := NewBox(make([]byte, n)); err == nil {
+			t.Errorf("NewBox with a %d-byte key succeeded", n)
+		}
+	}
+}
+
+func TestLoadBox(t *testing.T) {
+	key := testKey()
+	keyFile := filepath.Join(t.TempDir(), "master.key")
+	if err := os.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantBox bool
+		wantErr bool
+	}{
+		{"unset", nil, false, false},
+		{"base64", map[string]string{MasterKeyEnv: base64.StdEncoding.EncodeToString(key)}, true, false},
+		{"hex", map[string]string{MasterKeyEnv: hex.EncodeToString(key)}, true, false},
+		{"file", map[string]string{MasterKeyFileEnv: keyFile}, true, false},
+		{"missing file", map[string]string{MasterKeyFileEnv: keyFile + ".missing"}, false, true},
+		{"short base64", map[string]string{MasterKeyEnv: base64.StdEncoding.EncodeToString(key[:16])}, false, true},
+		{"short hex", map[string]string{MasterKeyEnv: hex.EncodeToString(key[:16])}, false, true},
+		{"not encoded", map[string]string{MasterKeyEnv: "not a key!"}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			box, err := LoadBox(func(name string) string { return tt.env[name] })
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadBox error = %v, want error %t", err, tt.wantErr)
+			}
+			if (box != nil) != tt.wantBox {
+				t.Errorf("LoadBox box = %v, want a box %t", box, tt.wantBox)
+			}
+		})
+	}
+}
+
+func TestGenerateKey(t *testing.T) {
+	encoded, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadBox(func(name string) string {
+		if name == MasterKeyEnv {
+			return encoded
+		}
+		return ""
+	}); err != nil {
+		t.Errorf("generated key %q does not load: %v", encoded, err)
+	}
+}
+
+func TestRedactor(t *testing.T) {
+	tests := []struct {
+		name    string
+		secrets []string
+		in      string
+		want    string
+	}{
+		{"nothing set", nil, "key sk-abcdef", "key sk-abcdef"},
+		{"masks every occurrence", []string{"sk-abcdef"}, "sk-abcdef and sk-abcdef", "[REDACTED] and [REDACTED]"},
+		{"longest first", []string{"secret", "secret-extended"}, "value secret-extended", "value [REDACTED]"},
+		{"shorter one alone", []string{"secret", "secret-extended"}, "value secret", "value [REDACTED]"},
+		{"ignores short values", []string{"abc", "12345"}, "abc 12345", "abc 12345"},
+		{"ignores empty values", []string{""}, "text", "text"},
+		{"minimum length", []string{"123456"}, "pin 123456", "pin [REDACTED]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			r := NewRedactor(&out)
+			r.Set(tt.secrets)
+
+			n, err := r.Write([]byte(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != len(tt.in) {
+				t.Errorf("Write returned %d, want %d", n, len(tt.in))
+			}
+			if out.String() != tt.want {
+				t.Errorf("wrote %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactorSetReplaces(t *testing.T) {
+	r := NewRedactor(&bytes.Buffer{})
+	r.Set([]string{"old-secret"})
+	r.Set([]string{"new-secret"})
+	if got := r.Redact("old-secret new-secret"); got != "old-secret [REDACTED]" {
+		t.Errorf("Redact = %q, want only the new secret masked", got)
+	}
+}
@@ -0,0 +1,253 @@
+// Package targeting picks which representatives a letter should go to with
+// predictable, explainable rules keyed on the issue text and the
+// representative's title, chamber and level of government.
+package targeting
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/yourdatasucks/lettersmith/internal/reps"
+)
+
+const (
+	ModeAI    = "ai"
+	ModeRules = "rules"
+
+	LevelFederal = reps.LevelFederal
+	LevelState   = reps.LevelState
+
+	ChamberUpper = reps.ChamberUpper
+	ChamberLower = reps.ChamberLower
+)
+
+//go:embed default_rules.json
+var defaultRules []byte
+
+// Rule sends issues mentioning any of Keywords to the representatives that
+// match every non-empty selector. Levels and Chambers are listed in order of
+// preference.
+type Rule struct {
+	Name        string   `json:"name"`
+	Keywords    []string `json:"keywords"`
+	Levels      []string `json:"levels,omitempty"`
+	Chambers    []string `json:"chambers,omitempty"`
+	Titles      []string `json:"titles,omitempty"`
+	Explanation string   `json:"explanation"`
+
+	patterns []*regexp.Regexp
+}
+
+type RuleSet struct {
+	Rules  []Rule `json:"rules"`
+	Source string `json:"source"`
+}
+
+// Selection is the outcome of applying a rule set to an issue. The first
+// representative is the one a single letter goes to.
+type Selection struct {
+	Rule            string                `json:"rule"`
+	Keyword         string                `json:"keyword,omitempty"`
+	Explanation     string                `json:"explanation"`
+	Representatives []reps.Representative `json:"representatives"`
+}
+
+// Parse decodes and validates a JSON rule set.
+func Parse(data []byte, source string) (*RuleSet, error) {
+	var rs RuleSet
+	if err := json.Unmarshal(data, &rs); err != nil {
+		return nil, fmt.Errorf("invalid targeting rules JSON: %w", err)
+	}
+	rs.Source = source
+
+	if len(rs.Rules) == 0 {
+		return nil, fmt.Errorf("targeting rules must contain at least one rule")
+	}
+
+	seen := make(map[string]bool)
+	for i := range rs.Rules {
+		rule := &rs.Rules[i]
+		if rule.Name == "" {
+			return nil, fmt.Errorf("targeting rule %d has no name", i+1)
+		}
+		if seen[rule.Name] {
+			return nil, fmt.Errorf("duplicate targeting rule %q", rule.Name)
+		}
+		seen[rule.Name] = true
+
+		if len(rule.Keywords) == 0 {
+			return nil, fmt.Errorf("targeting rule %q has no keywords", rule.Name)
+		}
+		for _, level := range rule.Levels {
+			if level != LevelFederal && level != LevelState {
+				return nil, fmt.Errorf("targeting rule %q: unknown level %q (expected federal or state)", rule.Name, level)
+			}
+		}
+		for _, chamber := range rule.Chambers {
+			if chamber != ChamberUpper && chamber != ChamberLower {
+				return nil, fmt.Errorf("targeting rule %q: unknown chamber %q (expected upper or lower)", rule.Name, chamber)
+			}
+		}
+
+		for _, keyword := range rule.Keywords {
+			keyword = strings.TrimSpace(keyword)
+			if keyword == "" {
+				return nil, fmt.Errorf("targeting rule %q has an empty keyword", rule.Name)
+			}
+			// Keywords match whole words, allowing a plural suffix.
+			rule.patterns = append(rule.patterns, regexp.MustCompile(`(?i)\b`+regexp.QuoteMeta(keyword)+`(s|es)?\b`))
+		}
+	}
+
+	return &rs, nil
+}
+
+func DefaultRuleSet() (*RuleSet, error) {
+	return Parse(defaultRules, "embedded")
+}
+
+// Select applies the rules in order. The first rule whose keywords appear in
+// the issue and whose selectors match at least one representative wins. When
+// no rule applies, every representative is returned in a stable order.
+func (rs *RuleSet) Select(mainIssue, specificConcern, requestedAction string, representatives []reps.Representative) *Selection {
+	text := strings.Join([]string{mainIssue, specificConcern, requestedAction}, "\n")
+
+	for _, rule := range rs.Rules {
+		keyword := rule.match(text)
+		if keyword == "" {
+			continue
+		}
+
+		matched := rule.filter(representatives)
+		if len(matched) == 0 {
+			continue
+		}
+
+		return &Selection{
+			Rule:            rule.Name,
+			Keyword:         keyword,
+			Explanation:     rule.Explanation,
+			Representatives: matched,
+		}
+	}
+
+	fallback := make([]reps.Representative, len(representatives))
+	copy(fallback, representatives)
+	sort.SliceStable(fallback, func(i, j int) bool {
+		if fallback[i].Title != fallback[j].Title {
+			return fallback[i].Title < fallback[j].Title
+		}
+		return fallback[i].Name < fallback[j].Name
+	})
+
+	return &Selection{
+		Rule:            "default",
+		Explanation:     "No targeting rule matched this issue.",
+		Representatives: fallback,
+	}
+}
+
+func (r *Rule) match(text string) string {
+	for i, pattern := range r.patterns {
+		if pattern.MatchString(text) {
+			return r.Keywords[i]
+		}
+	}
+	return ""
+}
+
+// filter returns the representatives matching every selector, ordered by the
+// rule's level and chamber preferences, then title and name.
+func (r *Rule) filter(representatives []reps.Representative) []reps.Representative {
+	var matched []reps.Representative
+	for _, rep := range representatives {
+		if len(r.Levels) > 0 && !contains(r.Levels, Level(rep)) {
+			continue
+		}
+		if len(r.Chambers) > 0 && !contains(r.Chambers, Chamber(rep)) {
+			continue
+		}
+		if len(r.Titles) > 0 && !titleMatches(r.Titles, rep.Title) {
+			continue
+		}
+		matched = append(matched, rep)
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		if li, lj := indexOf(r.Levels, Level(a)), indexOf(r.Levels, Level(b)); li != lj {
+			return li < lj
+		}
+		if ci, cj := indexOf(r.Chambers, Chamber(a)), indexOf(r.Chambers, Chamber(b)); ci != cj {
+			return ci < cj
+		}
+		if a.Title != b.Title {
+			return a.Title < b.Title
+		}
+		return a.Name < b.Name
+	})
+
+	return matched
+}
+
+var federalDistrict = regexp.MustCompile(`^[A-Z]{2}(-(\d+|AL))?$`)
+
+// Level reports whether a representative serves in Congress or a state
+// legislature. Representatives synced without a level are judged by the
+// title and the district format OpenStates uses for members of Congress.
+func Level(rep reps.Representative) string {
+	if rep.Level != "" {
+		return rep.Level
+	}
+	title := strings.ToLower(rep.Title)
+	if strings.Contains(title, "u.s.") || strings.Contains(title, "united states") || strings.Contains(title, "congress") {
+		return LevelFederal
+	}
+	if rep.District != nil && federalDistrict.MatchString(*rep.District) {
+		return LevelFederal
+	}
+	return LevelState
+}
+
+func Chamber(rep reps.Representative) string {
+	if rep.Chamber != "" {
+		return rep.Chamber
+	}
+	if strings.Contains(strings.ToLower(rep.Title), "senator") {
+		return ChamberUpper
+	}
+	return ChamberLower
+}
+
+func titleMatches(titles []string, title string) bool {
+	title = strings.ToLower(title)
+	for _, t := range titles {
+		if strings.Contains(title, strings.ToLower(t)) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// indexOf ranks value by its position in list; values not listed sort last.
+func indexOf(list []string, value string) int {
+	for i, v := range list {
+		if v == value {
+			return i
+		}
+	}
+	return len(list)
+}
@@ -0,0 +1,82 @@
+package targeting
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// ConfigKey is the configurations row holding rules edited through the API.
+const ConfigKey = "targeting_rules"
+
+type Store struct {
+	db   *sql.DB
+	file string
+}
+
+func NewStore(db *sql.DB, file string) *Store {
+	return &Store{db: db, file: file}
+}
+
+// Active resolves the rule set in order of precedence: rules saved in the
+// database, the rules file, then the embedded defaults.
+func (s *Store) Active() (*RuleSet, error) {
+	if s.db != nil {
+		var value string
+		err := s.db.QueryRow(`SELECT value FROM configurations WHERE key = $1`, ConfigKey).Scan(&value)
+		switch {
+		case err == nil:
+			return Parse([]byte(value), "database")
+		case err != sql.ErrNoRows:
+			return nil, fmt.Errorf("failed to load targeting rules: %w", err)
+		}
+	}
+
+	if s.file != "" {
+		data, err := os.ReadFile(s.file)
+		if err == nil {
+			return Parse(data, "file")
+		}
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read targeting rules file: %w", err)
+		}
+	}
+
+	return DefaultRuleSet()
+}
+
+// Save validates and stores a rule set in the database, overriding the file
+// and embedded rules.
+func (s *Store) Save(data []byte) (*RuleSet, error) {
+	rs, err := Parse(data, "database")
+	if err != nil {
+		return nil, err
+	}
+
+	normalized, err := json.Marshal(struct {
+		Rules []Rule `json:"rules"`
+	}{rs.Rules})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode targeting rules: %w", err)
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO configurations (key, value, description)
+		VALUES ($1, $2, 'Representative targeting rules')
+		ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value
+	`, ConfigKey, string(normalized))
+	if err != nil {
+		return nil, fmt.Errorf("failed to save targeting rules: %w", err)
+	}
+
+	return rs, nil
+}
+
+// Reset removes rules saved in the database.
+func (s *Store) Reset() error {
+	if _, err := s.db.Exec(`DELETE FROM configurations WHERE key = $1`, ConfigKey); err != nil {
+		return fmt.Errorf("failed to reset targeting rules: %w", err)
+	}
+	return nil
+}
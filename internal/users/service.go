@@ -0,0 +1,290 @@
+package users
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yourdatasucks/lettersmith/internal/ai"
+	"github.com/yourdatasucks/lettersmith/internal/auth"
+	"github.com/yourdatasucks/lettersmith/internal/reps"
+)
+
+// ScheduleJobType is the scheduled_jobs entry holding a user's daily send time.
+const ScheduleJobType = "daily_letters"
+
+type User struct {
+	ID      int    `json:"id"`
+	Email   string `json:"email"`
+	Role    string `json:"role"`
+	Name    string `json:"name"`
+	ZipCode string `json:"zip_code"`
+	// StreetAddress is optional. When set, Latitude and Longitude are where
+	// it was geocoded and are used instead of the ZIP code centroid.
+	StreetAddress  string    `json:"street_address,omitempty"`
+	Latitude       *float64  `json:"latitude,omitempty"`
+	Longitude      *float64  `json:"longitude,omitempty"`
+	Signature      string    `json:"signature,omitempty"`
+	Tone           string    `json:"tone,omitempty"`
+	MaxLength      int       `json:"max_length,omitempty"`
+	SendCopyToSelf bool      `json:"send_copy_to_self"`
+	Schedule       *Schedule `json:"schedule,omitempty"`
+	// Districts are chosen when the ZIP code is split between districts.
+	Districts reps.Districts `json:"districts"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+type Schedule struct {
+	SendTime  string     `json:"send_time"` // HH:MM
+	Timezone  string     `json:"timezone"`
+	Enabled   bool       `json:"enabled"`
+	NextRunAt *time.Time `json:"next_run_at,omitempty"`
+}
+
+type Service struct {
+	db *sql.DB
+}
+
+func NewService(db *sql.DB) *Service {
+	return &Service{db: db}
+}
+
+const selectUsers = `
+	SELECT u.id, u.email, u.role, u.name, u.zip_code, COALESCE(u.street_address, ''), u.latitude, u.longitude,
+	       COALESCE(u.signature, ''), COALESCE(u.tone, ''),
+	       COALESCE(u.max_length, 0), u.send_copy_to_self, COALESCE(u.congressional_district, ''),
+	       COALESCE(u.state_upper_district, ''), COALESCE(u.state_lower_district, ''), u.created_at, u.updated_at,
+	       to_char(j.schedule_time, 'HH24:MI'), j.timezone, j.enabled, j.next_run_at
+	FROM users u
+	LEFT JOIN scheduled_jobs j ON j.user_id = u.id AND j.job_type = '` + ScheduleJobType + `'
+`
+
+func (s *Service) List() ([]User, error) {
+	return s.query(selectUsers + " ORDER BY u.name")
+}
+
+func (s *Service) Get(id int) (*User, error) {
+	return s.one(selectUsers+" WHERE u.id = $1", id)
+}
+
+func (s *Service) GetByEmail(email string) (*User, error) {
+	return s.one(selectUsers+" WHERE lower(u.email) = lower($1)", email)
+}
+
+func (s *Service) Create(u *User) error {
+	if err := validate(u); err != nil {
+		return err
+	}
+
+	err := s.db.QueryRow(`
+		INSERT INTO users (email, name, zip_code, signature, tone, max_length, send_copy_to_self, role,
+		                   congressional_district, state_upper_district, state_lower_district,
+		                   street_address, latitude, longitude)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING id, created_at, updated_at
+	`, u.Email, u.Name, u.ZipCode, nullString(u.Signature), nullString(u.Tone), nullInt(u.MaxLength), u.SendCopyToSelf, u.Role,
+		nullString(u.Districts.Congressional), nullString(u.Districts.StateUpper), nullString(u.Districts.StateLower),
+		nullString(u.StreetAddress), u.Latitude, u.Longitude,
+	).Scan(&u.ID, &u.CreatedAt, &u.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	if u.Schedule != nil {
+		return s.SetSchedule(u.ID, *u.Schedule)
+	}
+	return nil
+}
+
+func (s *Service) Update(u *User) error {
+	if err := validate(u); err != nil {
+		return err
+	}
+	if u.Role != auth.RoleAdmin {
+		if err := s.keepAnAdmin(u.ID); err != nil {
+			return err
+		}
+	}
+
+	result, err := s.db.Exec(`
+		UPDATE users SET email = $2, name = $3, zip_code = $4, signature = $5, tone = $6,
+		                 max_length = $7, send_copy_to_self = $8, role = $9, congressional_district = $10,
+		                 state_upper_district = $11, state_lower_district = $12, street_address = $13,
+		                 latitude = $14, longitude = $15
+		WHERE id = $1
+	`, u.ID, u.Email, u.Name, u.ZipCode, nullString(u.Signature), nullString(u.Tone), nullInt(u.MaxLength), u.SendCopyToSelf, u.Role,
+		nullString(u.Districts.Congressional), nullString(u.Districts.StateUpper), nullString(u.Districts.StateLower),
+		nullString(u.StreetAddress), u.Latitude, u.Longitude)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("user %d not found", u.ID)
+	}
+
+	if u.Schedule != nil {
+		return s.SetSchedule(u.ID, *u.Schedule)
+	}
+	return nil
+}
+
+func (s *Service) Delete(id int) error {
+	if err := s.keepAnAdmin(id); err != nil {
+		return err
+	}
+
+	result, err := s.db.Exec(`DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("user %d not found", id)
+	}
+	return nil
+}
+
+// SetSchedule stores the user's daily send time in scheduled_jobs and
+// computes the next run.
+func (s *Service) SetSchedule(userID int, schedule Schedule) error {
+	loc, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		return fmt.Errorf("invalid timezone %q: %w", schedule.Timezone, err)
+	}
+	sendTime, err := time.Parse("15:04", schedule.SendTime)
+	if err != nil {
+		return fmt.Errorf("invalid send time %q (expected HH:MM): %w", schedule.SendTime, err)
+	}
+
+	now := time.Now().In(loc)
+	next := time.Date(now.Year(), now.Month(), now.Day(), sendTime.Hour(), sendTime.Minute(), 0, 0, loc)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO scheduled_jobs (user_id, job_type, schedule_time, timezone, enabled, next_run_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id, job_type) DO UPDATE SET
+			schedule_time = EXCLUDED.schedule_time,
+			timezone = EXCLUDED.timezone,
+			enabled = EXCLUDED.enabled,
+			next_run_at = EXCLUDED.next_run_at
+	`, userID, ScheduleJobType, schedule.SendTime, schedule.Timezone, schedule.Enabled, next)
+	if err != nil {
+		return fmt.Errorf("failed to save schedule: %w", err)
+	}
+
+	return nil
+}
+
+// keepAnAdmin refuses to demote or delete userID when it is the only
+// administrator left.
+func (s *Service) keepAnAdmin(userID int) error {
+	var others int
+	err := s.db.QueryRow(`
+		SELECT COUNT(*) FROM users WHERE role = $1 AND id <> $2
+	`, auth.RoleAdmin, userID).Scan(&others)
+	if err != nil {
+		return fmt.Errorf("failed to count administrators: %w", err)
+	}
+
+	var isAdmin bool
+	err = s.db.QueryRow(`SELECT role = $1 FROM users WHERE id = $2`, auth.RoleAdmin, userID).Scan(&isAdmin)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to look up user: %w", err)
+	}
+
+	if isAdmin && others == 0 {
+		return fmt.Errorf("at least one administrator must remain")
+	}
+	return nil
+}
+
+func (s *Service) one(query string, args ...interface{}) (*User, error) {
+	list, err := s.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("user not found")
+	}
+	return &list[0], nil
+}
+
+func (s *Service) query(query string, args ...interface{}) ([]User, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	var list []User
+	for rows.Next() {
+		var u User
+		var sendTime, timezone sql.NullString
+		var enabled sql.NullBool
+		var nextRun sql.NullTime
+		if err := rows.Scan(&u.ID, &u.Email, &u.Role, &u.Name, &u.ZipCode, &u.StreetAddress, &u.Latitude,
+			&u.Longitude, &u.Signature, &u.Tone, &u.MaxLength, &u.SendCopyToSelf, &u.Districts.Congressional,
+			&u.Districts.StateUpper, &u.Districts.StateLower, &u.CreatedAt, &u.UpdatedAt,
+			&sendTime, &timezone, &enabled, &nextRun); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		if sendTime.Valid {
+			u.Schedule = &Schedule{SendTime: sendTime.String, Timezone: timezone.String, Enabled: enabled.Bool}
+			if nextRun.Valid {
+				u.Schedule.NextRunAt = &nextRun.Time
+			}
+		}
+		list = append(list, u)
+	}
+
+	return list, rows.Err()
+}
+
+func validate(u *User) error {
+	u.Email = strings.TrimSpace(u.Email)
+	u.Name = strings.TrimSpace(u.Name)
+	u.ZipCode = strings.TrimSpace(u.ZipCode)
+	u.StreetAddress = strings.TrimSpace(u.StreetAddress)
+
+	if u.Email == "" || u.Name == "" || u.ZipCode == "" {
+		return fmt.Errorf("missing required fields: email, name, zip_code")
+	}
+	if !strings.Contains(u.Email, "@") {
+		return fmt.Errorf("invalid email address %q", u.Email)
+	}
+	if len(u.ZipCode) != 5 || strings.Trim(u.ZipCode, "0123456789") != "" {
+		return fmt.Errorf("invalid ZIP code %q (expected 5 digits)", u.ZipCode)
+	}
+	if u.Role == "" {
+		u.Role = auth.RoleWriter
+	}
+	if !auth.ValidRole(u.Role) {
+		return fmt.Errorf("invalid role %q (expected admin, writer or viewer)", u.Role)
+	}
+	if u.MaxLength < 0 || u.MaxLength > 2000 {
+		return fmt.Errorf("max_length must be between 0 and 2000")
+	}
+	// The signature reaches the prompt like the advocacy fields do.
+	if findings := ai.DetectInjection(map[string]string{"signature": u.Signature}); len(findings) > 0 {
+		return fmt.Errorf("signature contains instruction-like content (%s: %q)", findings[0].Pattern, findings[0].Match)
+	}
+	return nil
+}
+
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func nullInt(i int) interface{} {
+	if i == 0 {
+		return nil
+	}
+	return i
+}
@@ -0,0 +1,18 @@
+-- Versioned, user-editable prompt templates that override the embedded default
+CREATE TABLE IF NOT EXISTS prompt_templates (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    version INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    notes TEXT,
+    is_active BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(name, version)
+);
+
+-- At most one active version per template name
+CREATE UNIQUE INDEX IF NOT EXISTS idx_prompt_templates_active ON prompt_templates(name) WHERE is_active;
+
+-- Record which prompt produced each letter
+ALTER TABLE letters ADD COLUMN IF NOT EXISTS prompt_template_id INTEGER REFERENCES prompt_templates(id) ON DELETE SET NULL;
+ALTER TABLE letters ADD COLUMN IF NOT EXISTS prompt_version VARCHAR(150);
@@ -0,0 +1,9 @@
+-- MinHash signatures of sent letters for near-duplicate detection
+CREATE TABLE IF NOT EXISTS letter_fingerprints (
+    letter_id INTEGER PRIMARY KEY REFERENCES letters(id) ON DELETE CASCADE,
+    representative_id INTEGER NOT NULL REFERENCES representatives(id) ON DELETE CASCADE,
+    signature BIGINT[] NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_letter_fingerprints_representative ON letter_fingerprints(representative_id, created_at DESC);
@@ -0,0 +1,36 @@
+-- Campaigns: one advocacy ask fanned out into a letter per target representative
+CREATE TABLE IF NOT EXISTS campaigns (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    main_issue TEXT NOT NULL,
+    specific_concern TEXT NOT NULL,
+    requested_action TEXT NOT NULL,
+    target_rule VARCHAR(50) NOT NULL DEFAULT 'all', -- all, senators, house, ids
+    target_ids INTEGER[],
+    scheduled_at TIMESTAMP WITH TIME ZONE, -- NULL runs on demand
+    status VARCHAR(50) NOT NULL DEFAULT 'draft', -- draft, scheduled, running, completed, completed_with_errors
+    last_run_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS campaign_targets (
+    id SERIAL PRIMARY KEY,
+    campaign_id INTEGER NOT NULL REFERENCES campaigns(id) ON DELETE CASCADE,
+    representative_id INTEGER NOT NULL REFERENCES representatives(id) ON DELETE CASCADE,
+    letter_id INTEGER REFERENCES letters(id) ON DELETE SET NULL,
+    status VARCHAR(50) NOT NULL DEFAULT 'pending', -- pending, sent, blocked, failed
+    error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(campaign_id, representative_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_campaigns_scheduled ON campaigns(scheduled_at) WHERE status = 'scheduled';
+CREATE INDEX IF NOT EXISTS idx_campaign_targets_campaign ON campaign_targets(campaign_id);
+
+CREATE TRIGGER update_campaigns_updated_at BEFORE UPDATE ON campaigns
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER update_campaign_targets_updated_at BEFORE UPDATE ON campaign_targets
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
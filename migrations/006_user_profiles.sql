@@ -0,0 +1,9 @@
+-- Per-user profiles: letter preferences live on the user instead of in .env
+ALTER TABLE users ADD COLUMN IF NOT EXISTS signature TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tone VARCHAR(50);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS max_length INTEGER;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS send_copy_to_self BOOLEAN NOT NULL DEFAULT false;
+
+-- Campaigns belong to the user who created them
+ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS user_id INTEGER REFERENCES users(id) ON DELETE CASCADE;
+CREATE INDEX IF NOT EXISTS idx_campaigns_user_id ON campaigns(user_id);
@@ -0,0 +1,5 @@
+-- Replace the administrator flag with roles: admin, writer, viewer
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'writer';
+UPDATE users SET role = 'admin' WHERE is_admin;
+ALTER TABLE users DROP COLUMN IF EXISTS is_admin;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('admin', 'writer', 'viewer'));
@@ -0,0 +1,10 @@
+-- Members of Congress alongside state legislators: level is federal or state,
+-- chamber is upper (Senate) or lower (House). Rows synced before this
+-- migration keep NULL until the next sync.
+ALTER TABLE representatives ADD COLUMN IF NOT EXISTS level VARCHAR(20);
+ALTER TABLE representatives ADD COLUMN IF NOT EXISTS chamber VARCHAR(20);
+CREATE INDEX IF NOT EXISTS idx_representatives_level ON representatives(state, level, chamber);
+
+-- Congressional district of each ZIP centroid, looked up once and cached.
+-- At-large states and delegates use 'AL'.
+ALTER TABLE zip_coordinates ADD COLUMN IF NOT EXISTS congressional_district VARCHAR(10);
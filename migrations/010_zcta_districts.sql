@@ -0,0 +1,26 @@
+-- Districts overlapping each ZIP Code Tabulation Area, imported from the
+-- Census Bureau relationship files with: lettersmith districts import FILE
+-- land_area is the area (square meters) of the ZCTA inside the district.
+CREATE TABLE IF NOT EXISTS zcta_congressional_districts (
+    zcta VARCHAR(5) NOT NULL,
+    state VARCHAR(2) NOT NULL,
+    district VARCHAR(10) NOT NULL, -- 'AL' for at-large seats and delegates
+    name VARCHAR(255),
+    land_area BIGINT NOT NULL,
+    PRIMARY KEY (zcta, state, district)
+);
+
+CREATE TABLE IF NOT EXISTS zcta_legislative_districts (
+    zcta VARCHAR(5) NOT NULL,
+    state VARCHAR(2) NOT NULL,
+    chamber VARCHAR(20) NOT NULL, -- upper, lower
+    district VARCHAR(50) NOT NULL,
+    name VARCHAR(255),
+    land_area BIGINT NOT NULL,
+    PRIMARY KEY (zcta, state, chamber, district)
+);
+
+-- The district a user lives in when their ZIP code is split between several
+ALTER TABLE users ADD COLUMN IF NOT EXISTS congressional_district VARCHAR(10);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS state_upper_district VARCHAR(50);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS state_lower_district VARCHAR(50);
@@ -0,0 +1,16 @@
+-- Geocoded street addresses, keyed by the normalized address
+CREATE TABLE IF NOT EXISTS address_geocodes (
+    address TEXT PRIMARY KEY,
+    matched_address TEXT NOT NULL,
+    latitude DECIMAL(10, 8) NOT NULL,
+    longitude DECIMAL(11, 8) NOT NULL,
+    state VARCHAR(2) NOT NULL,
+    zip_code VARCHAR(10) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Optional street address of each user and where it was found, used instead
+-- of the ZIP code centroid for representative lookups
+ALTER TABLE users ADD COLUMN IF NOT EXISTS street_address TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS latitude DECIMAL(10, 8);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS longitude DECIMAL(11, 8);
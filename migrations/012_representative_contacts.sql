@@ -0,0 +1,38 @@
+-- Where and for how long each representative serves, and their portrait.
+-- Jurisdiction is an OCD jurisdiction ID such as
+-- 'ocd-jurisdiction/country:us/state:ca/government'.
+ALTER TABLE representatives ADD COLUMN IF NOT EXISTS jurisdiction VARCHAR(255);
+ALTER TABLE representatives ADD COLUMN IF NOT EXISTS term_start DATE;
+ALTER TABLE representatives ADD COLUMN IF NOT EXISTS term_end DATE;
+ALTER TABLE representatives ADD COLUMN IF NOT EXISTS photo_url TEXT;
+
+-- Every office of a representative, capitol and district, in source order.
+-- The email, phone and office_address columns of representatives hold the
+-- preferred one: the capitol office where there is one.
+CREATE TABLE IF NOT EXISTS representative_offices (
+    representative_id INTEGER NOT NULL REFERENCES representatives(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    classification VARCHAR(20) NOT NULL,
+    name VARCHAR(255),
+    address TEXT,
+    phone VARCHAR(50),
+    fax VARCHAR(50),
+    email VARCHAR(255),
+    PRIMARY KEY (representative_id, position)
+);
+
+-- Every web link and email address of a representative, in source order
+CREATE TABLE IF NOT EXISTS representative_links (
+    representative_id INTEGER NOT NULL REFERENCES representatives(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    url TEXT NOT NULL,
+    note VARCHAR(255),
+    PRIMARY KEY (representative_id, position)
+);
+
+CREATE TABLE IF NOT EXISTS representative_emails (
+    representative_id INTEGER NOT NULL REFERENCES representatives(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    PRIMARY KEY (representative_id, position)
+);
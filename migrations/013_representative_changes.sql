@@ -0,0 +1,17 @@
+-- Representatives no source returns any more are kept, for the letters sent
+-- to them, but marked inactive. last_seen_at is when a sync last returned
+-- them.
+ALTER TABLE representatives ADD COLUMN IF NOT EXISTS active BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE representatives ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMP WITH TIME ZONE;
+
+-- What each sync changed: representatives added, updated (with the old and
+-- new value of each changed field), departed or returned
+CREATE TABLE IF NOT EXISTS representative_changes (
+    id SERIAL PRIMARY KEY,
+    representative_id INTEGER NOT NULL REFERENCES representatives(id) ON DELETE CASCADE,
+    change VARCHAR(20) NOT NULL,
+    fields JSONB,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_representative_changes_created_at ON representative_changes(created_at DESC);
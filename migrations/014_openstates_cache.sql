@@ -0,0 +1,6 @@
+-- OpenStates API responses by request URL, reused for OPENSTATES_CACHE_TTL_HOURS
+CREATE TABLE IF NOT EXISTS openstates_cache (
+    url TEXT PRIMARY KEY,
+    body BYTEA NOT NULL,
+    fetched_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
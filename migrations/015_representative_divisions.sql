@@ -0,0 +1,17 @@
+-- The OCD division a representative serves, such as
+-- 'ocd-division/country:us/state:sc/sldu:12', which names their state even
+-- for members of Congress.
+ALTER TABLE representatives ADD COLUMN IF NOT EXISTS division_id VARCHAR(255);
+
+-- Syncs used to label everyone with the state of the user's ZIP code.
+-- Correct the rows whose jurisdiction names a state, DC or a territory;
+-- `lettersmith representatives backfill` refetches the others.
+UPDATE representatives
+SET state = UPPER(SUBSTRING(jurisdiction FROM '/(?:state|district|territory):([a-z]{2})(?:/|$)'))
+WHERE jurisdiction ~ '/(state|district|territory):[a-z]{2}(/|$)';
+
+UPDATE representatives SET level = 'federal'
+WHERE jurisdiction = 'ocd-jurisdiction/country:us/government';
+
+UPDATE representatives SET level = 'local'
+WHERE jurisdiction ~ '/(place|county):';
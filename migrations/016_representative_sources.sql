@@ -0,0 +1,12 @@
+-- Where each representative came from: 'openstates' and 'congress' rows are
+-- kept up to date by syncs, 'manual' ones are added by hand (city councils,
+-- agency heads, companies) and never touched by a sync. A manual recipient
+-- without a state is offered to every user.
+ALTER TABLE representatives ADD COLUMN IF NOT EXISTS source VARCHAR(20);
+
+UPDATE representatives SET source = 'congress' WHERE source IS NULL AND external_id LIKE 'bioguide/%';
+UPDATE representatives SET source = 'openstates' WHERE source IS NULL AND external_id LIKE 'ocd-person/%';
+UPDATE representatives SET source = 'manual' WHERE source IS NULL;
+
+ALTER TABLE representatives ALTER COLUMN source SET DEFAULT 'manual';
+ALTER TABLE representatives ALTER COLUMN source SET NOT NULL;
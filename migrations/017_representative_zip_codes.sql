@@ -0,0 +1,4 @@
+-- Local officials from file and HTTP sources serve part of a state: the ZIP
+-- codes they are offered to. NULL means the whole state, as for legislators
+-- whose districts are matched separately.
+ALTER TABLE representatives ADD COLUMN IF NOT EXISTS zip_codes TEXT[];
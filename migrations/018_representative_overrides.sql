@@ -0,0 +1,3 @@
+-- Fields of synced representatives corrected by an administrator, by edit or
+-- import. Syncs keep the stored value of these fields instead of the source's.
+ALTER TABLE representatives ADD COLUMN IF NOT EXISTS overridden_fields TEXT[] NOT NULL DEFAULT '{}';
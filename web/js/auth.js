@@ -0,0 +1,71 @@
+// Shared by every page: sends the CSRF token with state-changing requests,
+// returns to the sign-in page when the session has expired, adds a sign-out
+// link to the navigation and hides what the user's role cannot use.
+(function() {
+    const CSRF_COOKIE = 'lettersmith_csrf';
+    const SAFE_METHODS = ['GET', 'HEAD', 'OPTIONS'];
+    const originalFetch = window.fetch.bind(window);
+
+    function readCookie(name) {
+        const match = document.cookie.split('; ').find(row => row.startsWith(name + '='));
+        return match ? decodeURIComponent(match.split('=')[1]) : '';
+    }
+
+    window.fetch = async function(input, init = {}) {
+        const method = (init.method || 'GET').toUpperCase();
+        if (!SAFE_METHODS.includes(method)) {
+            const headers = new Headers(init.headers || {});
+            headers.set('X-CSRF-Token', readCookie(CSRF_COOKIE));
+            init = { ...init, headers };
+        }
+
+        const response = await originalFetch(input, init);
+        const onLoginPage = window.location.pathname.endsWith('login.html');
+        if (response.status === 401 && !onLoginPage) {
+            window.location.href = '/login.html';
+        }
+        return response;
+    };
+
+    async function signOut(e) {
+        e.preventDefault();
+        await fetch('/api/auth/logout', { method: 'POST' });
+        window.location.href = '/login.html';
+    }
+
+    // Configuration is admin-only, so other roles are sent to the page they
+    // can use and the tab is hidden.
+    async function applyRole(nav) {
+        const response = await fetch('/api/auth/status');
+        if (!response.ok) {
+            return;
+        }
+        const status = await response.json();
+        if (!status.user || status.user.role === 'admin') {
+            return;
+        }
+
+        const path = window.location.pathname;
+        if (path === '/' || path.endsWith('index.html')) {
+            window.location.href = status.user.role === 'writer' ? '/generate.html' : '/status.html';
+            return;
+        }
+        nav.querySelectorAll('a[href="index.html"]').forEach(tab => tab.classList.add('hidden'));
+    }
+
+    document.addEventListener('DOMContentLoaded', function() {
+        const nav = document.querySelector('.nav-tabs');
+        if (!nav) {
+            return;
+        }
+
+        const link = document.createElement('a');
+        link.href = '#';
+        link.className = 'nav-tab nav-tab-signout';
+        link.textContent = 'Sign Out';
+        link.addEventListener('click', signOut);
+        nav.appendChild(link);
+
+        applyRole(nav).catch(error => console.error('Failed to load account role:', error));
+    });
+})();
@@ -76,6 +76,7 @@ function showResult(data) {
             <div class="selected-rep-card">
                 <strong>${data.letter.selected_representative.title} ${data.letter.selected_representative.name}</strong>
                 <span class="rep-details">${data.letter.selected_representative.state}${data.letter.selected_representative.party ? ` - ${data.letter.selected_representative.party}` : ''}</span>
+                ${data.letter.selected_representative.office_address ? `<span class="rep-details">${data.letter.selected_representative.office_address}</span>` : ''}
                 <div class="selection-reasoning">
                     <em>${data.ai_selection.reasoning}</em>
                 </div>
@@ -90,8 +91,11 @@ function showResult(data) {
             <pre>${data.letter.content}</pre>
         </div>
         
+        ${renderLintFindings(data.lint)}
+
         <div class="letter-actions">
-            <button class="btn btn-primary" onclick="copyToClipboard()">📋 Copy Letter</button>
+            <button class="btn btn-primary" onclick="sendLetter(this)" ${data.lint && data.lint.blocking ? 'disabled title="Fix the problems above before sending"' : ''}>📨 Send Letter</button>
+            <button class="btn btn-secondary" onclick="copyToClipboard()">📋 Copy Letter</button>
             <button class="btn btn-secondary" onclick="downloadLetter()">💾 Download as Text</button>
         </div>
         
@@ -108,8 +112,61 @@ function showResult(data) {
     
     container.classList.remove('hidden');
     
-    // Store letter content for copy/download functions
+    // Store letter content for copy/download/send functions
     window.currentLetter = data.letter.content;
+    window.currentLetterData = data.letter;
+}
+
+function renderLintFindings(lint) {
+    if (!lint || !lint.findings || lint.findings.length === 0) {
+        return '';
+    }
+
+    const items = lint.findings.map(f => `<li class="lint-${f.severity}"><strong>${f.severity.toUpperCase()}</strong> ${f.message}</li>`).join('');
+
+    return `
+        <div class="${lint.blocking ? 'error-message' : 'info-message'}">
+            <h4>${lint.blocking ? '🚫 This letter cannot be sent yet' : '⚠️ Review before sending'}</h4>
+            <ul>${items}</ul>
+        </div>
+    `;
+}
+
+function sendLetter(button) {
+    const letter = window.currentLetterData;
+    if (!letter) {
+        showNotification('No letter to send', 'error');
+        return;
+    }
+
+    button.disabled = true;
+
+    fetch('/api/letters/send', {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json',
+        },
+        body: JSON.stringify({
+            subject: letter.subject,
+            content: letter.content,
+            representative_id: letter.selected_representative.id,
+            metadata: letter.metadata
+        })
+    })
+    .then(response => response.json())
+    .then(data => {
+        if (data.error) {
+            button.disabled = false;
+            showNotification(data.error, 'error');
+        } else {
+            showNotification(`Letter sent to ${data.sent_to}`, 'success');
+        }
+    })
+    .catch(error => {
+        button.disabled = false;
+        console.error('Error:', error);
+        showNotification('Failed to send letter', 'error');
+    });
 }
 
 function showError(message) {
@@ -0,0 +1,73 @@
+document.addEventListener('DOMContentLoaded', async function() {
+    document.getElementById('login-form').addEventListener('submit', handleLogin);
+    document.getElementById('bootstrap-form').addEventListener('submit', handleBootstrap);
+
+    try {
+        const response = await fetch('/api/auth/status');
+        const status = await response.json();
+
+        if (status.authenticated) {
+            window.location.href = '/';
+            return;
+        }
+        if (status.bootstrap_required) {
+            document.getElementById('login-section').classList.add('hidden');
+            document.getElementById('bootstrap-section').classList.remove('hidden');
+        }
+    } catch (error) {
+        showAuthError('Could not reach the server: ' + error.message);
+    }
+});
+
+async function handleLogin(e) {
+    e.preventDefault();
+    const formData = new FormData(e.target);
+
+    await submitAuth('/api/auth/login', {
+        email: formData.get('email')?.trim(),
+        password: formData.get('password')
+    });
+}
+
+async function handleBootstrap(e) {
+    e.preventDefault();
+    const formData = new FormData(e.target);
+
+    if (formData.get('password') !== formData.get('password-confirm')) {
+        showAuthError('Passwords do not match');
+        return;
+    }
+
+    await submitAuth('/api/auth/bootstrap', {
+        bootstrap_token: formData.get('bootstrap-token')?.trim(),
+        name: formData.get('name')?.trim(),
+        email: formData.get('email')?.trim(),
+        zip_code: formData.get('zip-code')?.trim(),
+        password: formData.get('password')
+    });
+}
+
+async function submitAuth(url, body) {
+    try {
+        const response = await fetch(url, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(body)
+        });
+        const data = await response.json();
+
+        if (!response.ok) {
+            showAuthError(data.error || `HTTP ${response.status}`);
+            return;
+        }
+        window.location.href = '/';
+    } catch (error) {
+        showAuthError(error.message);
+    }
+}
+
+function showAuthError(message) {
+    const container = document.getElementById('auth-error');
+    container.textContent = message;
+    container.classList.remove('hidden');
+}
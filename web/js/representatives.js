@@ -1,4 +1,11 @@
 let representatives = [];
+let districts = null;
+
+const districtLabels = {
+    congressional: 'Congressional district',
+    state_upper: 'State senate district',
+    state_lower: 'State house district'
+};
 
 async function loadRepresentatives() {
     const loading = document.getElementById('loading');
@@ -18,7 +25,9 @@ async function loadRepresentatives() {
         }
 
         representatives = data.representatives || [];
+        districts = data.districts || null;
         renderRepresentatives();
+        renderDistrictChoice();
 
         loading.classList.add('content-hidden');
         content.classList.remove('content-hidden');
@@ -41,7 +50,7 @@ function renderRepresentatives() {
         container.innerHTML = `
             <div class="empty-state">
                 <p>No representatives found.</p>
-                <p><small>Click "Sync from OpenStates" to fetch your representatives.</small></p>
+                <p><small>Click "Sync Representatives" to fetch your representatives.</small></p>
             </div>
         `;
         return;
@@ -56,11 +65,16 @@ function renderRepresentatives() {
                     <button class="btn-small btn-delete" onclick="deleteRepresentative(${rep.id})">🗑️</button>
                 </div>
                 
+                ${rep.photo_url ? `<img class="rep-photo" src="${rep.photo_url}" alt="${rep.name}">` : ''}
                 <h3>${rep.name}</h3>
                 <p><strong>Title:</strong> 
                     <span class="view-mode">${rep.title}</span>
                     <input class="edit-mode" type="text" data-field="title" value="${rep.title}">
                 </p>
+                ${rep.level ? `<p><strong>Level:</strong> ${formatLevel(rep)}</p>` : ''}
+                ${rep.source === 'manual' ? '<p><small><em>Added manually: syncs leave it as it is.</em></small></p>' : ''}
+                ${rep.overridden_fields && rep.overridden_fields.length > 0 ? `<p><small><em>Corrected ${rep.overridden_fields.map(field => field.replace('_', ' ')).join(', ')}: syncs keep these values.</em></small></p>` : ''}
+                ${rep.term_start || rep.term_end ? `<p><strong>Term:</strong> ${formatTerm(rep)}</p>` : ''}
                 
                 ${rep.party ? `<p><strong>Party:</strong> 
                     <span class="view-mode">${rep.party}</span>
@@ -110,6 +124,8 @@ function renderRepresentatives() {
                     <textarea class="edit-mode" data-field="office_address"></textarea>
                 </p>`}
                 
+                ${renderContacts(rep)}
+                
                                  <div class="edit-actions">
                      <button class="btn-save" onclick="saveRepresentative(${rep.id})">✅ Save</button>
                      <button class="btn-cancel" onclick="cancelEdit(${rep.id})">❌ Cancel</button>
@@ -125,6 +141,181 @@ function renderRepresentatives() {
     container.innerHTML = html;
 }
 
+// renderDistrictChoice offers a choice for every kind of district the ZIP code
+// is split across, largest share of the ZIP code first.
+function renderDistrictChoice() {
+    const section = document.getElementById('district-choice');
+    const container = document.getElementById('district-choice-container');
+    const kinds = districts ? Object.keys(districtLabels).filter(kind => (districts[kind] || []).length > 1) : [];
+
+    if (kinds.length === 0) {
+        section.classList.add('content-hidden');
+        return;
+    }
+
+    container.innerHTML = kinds.map(kind => `
+        <div class="form-group">
+            <label for="district-${kind}">${districtLabels[kind]}</label>
+            <select id="district-${kind}" data-kind="${kind}">
+                <option value="">Not sure (show all)</option>
+                ${districts[kind].map(d => `
+                    <option value="${d.district}" ${districts.chosen[kind] === d.district ? 'selected' : ''}>
+                        ${d.name || d.district} (${Math.round(d.land_share * 100)}% of ZIP ${districts.zip_code})
+                    </option>
+                `).join('')}
+            </select>
+        </div>
+    `).join('');
+    section.classList.remove('content-hidden');
+}
+
+async function loadAddress() {
+    try {
+        const response = await fetch('/api/users/me');
+        if (!response.ok) return;
+        showAddress(await response.json());
+    } catch (error) {
+        console.error('Load address error:', error);
+    }
+}
+
+function showAddress(user) {
+    document.getElementById('street-address').value = user.street_address || '';
+    document.getElementById('street-address-status').textContent = user.latitude !== undefined
+        ? `Located at ${user.latitude.toFixed(5)}, ${user.longitude.toFixed(5)}. Sync again to update your representatives.`
+        : 'Not set: your ZIP code is used.';
+}
+
+async function saveAddress() {
+    try {
+        const response = await fetch('/api/users/me', {
+            method: 'PUT',
+            headers: {
+                'Content-Type': 'application/json'
+            },
+            body: JSON.stringify({ street_address: document.getElementById('street-address').value.trim() })
+        });
+        const data = await response.json();
+
+        if (!response.ok) {
+            throw new Error(data.error || 'Failed to save address');
+        }
+
+        showAddress(data);
+        showNotification('Address saved!', 'success');
+    } catch (error) {
+        console.error('Save address error:', error);
+        showNotification(`Failed to save address: ${error.message}`, 'error');
+    }
+}
+
+async function saveDistricts() {
+    const chosen = { ...districts.chosen };
+    document.querySelectorAll('#district-choice-container select').forEach(select => {
+        chosen[select.dataset.kind] = select.value;
+    });
+
+    try {
+        const response = await fetch('/api/users/me', {
+            method: 'PUT',
+            headers: {
+                'Content-Type': 'application/json'
+            },
+            body: JSON.stringify({ districts: chosen })
+        });
+        const data = await response.json();
+
+        if (!response.ok) {
+            throw new Error(data.error || 'Failed to save districts');
+        }
+
+        showNotification('Districts saved!', 'success');
+        loadRepresentatives();
+    } catch (error) {
+        console.error('Save districts error:', error);
+        showNotification(`Failed to save districts: ${error.message}`, 'error');
+    }
+}
+
+function formatTerm(rep) {
+    return `${rep.term_start || '?'} to ${rep.term_end || '?'}`;
+}
+
+// renderContacts lists every office, email and link beyond the preferred
+// ones shown in the fields above.
+function renderContacts(rep) {
+    const offices = rep.offices || [];
+    const emails = rep.emails || [];
+    const links = rep.links || [];
+    if (offices.length + emails.length + links.length <= 1) {
+        return '';
+    }
+
+    const officeItems = offices.map(office => {
+        const label = office.name || (office.classification === 'capitol' ? 'Capitol office' : 'District office');
+        const details = [office.address, office.phone && `Phone: ${office.phone}`, office.fax && `Fax: ${office.fax}`, office.email]
+            .filter(Boolean).join(' · ');
+        return `<li><strong>${label}:</strong> ${details}</li>`;
+    });
+    const emailItems = emails.map(email => `<li><a href="mailto:${email}">${email}</a></li>`);
+    const linkItems = links.map(link => `<li><a href="${link.url}" target="_blank">${link.note || link.url}</a></li>`);
+
+    return `
+        <details class="view-mode rep-contacts">
+            <summary>All contacts</summary>
+            <ul>${officeItems.concat(emailItems, linkItems).join('')}</ul>
+        </details>
+    `;
+}
+
+// formatLevel describes where a representative serves, e.g. "Federal, upper chamber".
+function formatLevel(rep) {
+    const level = rep.level.charAt(0).toUpperCase() + rep.level.slice(1);
+    return rep.chamber ? `${level}, ${rep.chamber} chamber` : level;
+}
+
+const changeLabels = {
+    added: 'New',
+    updated: 'Updated',
+    departed: 'Left office',
+    returned: 'Returned'
+};
+
+async function loadChanges() {
+    const container = document.getElementById('changes-container');
+    try {
+        const response = await fetch('/api/representatives/changes?limit=20');
+        const data = await response.json();
+        if (!response.ok) {
+            throw new Error(data.error || 'Failed to fetch changes');
+        }
+        renderChanges(data.changes || []);
+    } catch (error) {
+        console.error('Error:', error);
+        container.innerHTML = `<p class="status-error">❌ ${error.message}</p>`;
+    }
+}
+
+function renderChanges(changes) {
+    const container = document.getElementById('changes-container');
+    if (changes.length === 0) {
+        container.innerHTML = '<p class="empty-state">No changes recorded yet.</p>';
+        return;
+    }
+
+    container.innerHTML = `<ul class="rep-changes">${changes.map(change => {
+        const fields = Object.entries(change.fields || {})
+            .map(([field, value]) => `${field.replace('_', ' ')}: ${value.old || '—'} → ${value.new || '—'}`)
+            .join('; ');
+        return `<li>
+            <strong>${changeLabels[change.change] || change.change}:</strong>
+            ${change.title} ${change.name} (${change.state})
+            ${fields ? `<br><small>${fields}</small>` : ''}
+            <small class="rep-updated">${new Date(change.created_at).toLocaleDateString()}</small>
+        </li>`;
+    }).join('')}</ul>`;
+}
+
 async function syncRepresentatives() {
     const syncBtn = document.getElementById('sync-btn');
     const syncStatus = document.getElementById('sync-status');
@@ -132,10 +323,10 @@ async function syncRepresentatives() {
     syncBtn.disabled = true;
     syncBtn.textContent = '⏳ Syncing...';
     syncStatus.classList.remove('content-hidden');
-    syncStatus.innerHTML = '<p>🔄 Fetching representatives from OpenStates API...</p>';
+    syncStatus.innerHTML = '<p>🔄 Fetching representatives...</p>';
 
     try {
-        const response = await fetch('/api/representatives', {
+        const response = await fetch('/api/representatives/sync', {
             method: 'POST'
         });
         const data = await response.json();
@@ -145,26 +336,123 @@ async function syncRepresentatives() {
         }
 
         representatives = data.representatives || [];
+        districts = data.districts || districts;
         renderRepresentatives();
+        renderDistrictChoice();
         
         syncStatus.innerHTML = `<p class="status-success">✅ Successfully synced ${data.count} representatives!</p>`;
+        loadChanges();
 
     } catch (error) {
         console.error('Sync error:', error);
         syncStatus.innerHTML = `<p class="status-error">❌ Sync failed: ${error.message}</p>`;
     } finally {
         syncBtn.disabled = false;
-        syncBtn.textContent = '🔄 Sync from OpenStates';
+        syncBtn.textContent = '🔄 Sync Representatives';
         setTimeout(() => {
             syncStatus.classList.add('content-hidden');
         }, 5000);
     }
 }
 
+// addRepresentative stores a recipient that no source knows about, such as a
+// city council member.
+async function addRepresentative() {
+    const fields = ['name', 'title', 'state', 'level', 'email', 'phone', 'office_address', 'website'];
+    const rep = {};
+    fields.forEach(field => {
+        const value = document.getElementById(`new-rep-${field}`).value.trim();
+        if (value) {
+            rep[field] = value;
+        }
+    });
+
+    try {
+        const response = await fetch('/api/representatives', {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json'
+            },
+            body: JSON.stringify(rep)
+        });
+        const data = await response.json();
+
+        if (!response.ok) {
+            throw new Error(data.error || 'Failed to add recipient');
+        }
+
+        fields.forEach(field => {
+            document.getElementById(`new-rep-${field}`).value = '';
+        });
+        showNotification(`${data.name} added!`, 'success');
+        loadRepresentatives();
+        loadChanges();
+    } catch (error) {
+        console.error('Add recipient error:', error);
+        showNotification(`Failed to add recipient: ${error.message}`, 'error');
+    }
+}
+
+// importRepresentatives sends the chosen file to the import endpoint, as a
+// dry run when preview is set, and shows what it changes.
+async function importRepresentatives(preview) {
+    const file = document.getElementById('import-file').files[0];
+    if (!file) {
+        showNotification('Choose a CSV or JSON file first', 'error');
+        return;
+    }
+
+    const format = file.name.toLowerCase().endsWith('.csv') ? 'csv' : 'json';
+    try {
+        const response = await fetch(`/api/representatives/import?format=${format}&dry_run=${preview}`, {
+            method: 'POST',
+            headers: {
+                'Content-Type': format === 'csv' ? 'text/csv' : 'application/json'
+            },
+            body: await file.text()
+        });
+        const data = await response.json();
+
+        if (data.error) {
+            throw new Error(data.error);
+        }
+
+        renderImportResult(data);
+        if (!preview && response.ok) {
+            showNotification('Import applied!', 'success');
+            loadRepresentatives();
+            loadChanges();
+        }
+    } catch (error) {
+        console.error('Import error:', error);
+        showNotification(`Import failed: ${error.message}`, 'error');
+    }
+}
+
+function renderImportResult(result) {
+    const describe = change => {
+        const fields = Object.entries(change.fields || {})
+            .map(([field, value]) => `${field.replace('_', ' ')}: ${value.old ? `${value.old} → ` : ''}${value.new}`)
+            .join('; ');
+        const kept = change.override ? '<br><small><em>Kept over later syncs</em></small>' : '';
+        return `<li><strong>Row ${change.row}:</strong> ${change.name}<br><small>${fields}</small>${kept}</li>`;
+    };
+
+    const applied = !result.dry_run && result.errors.length === 0;
+    const heading = result.dry_run ? 'Preview' : (applied ? 'Imported' : 'Not imported');
+    const [add, update] = applied ? ['added', 'updated'] : ['to add', 'to update'];
+    document.getElementById('import-result').innerHTML = `
+        <h3>${heading}: ${result.inserts.length} ${add}, ${result.updates.length} ${update}, ${result.unchanged} unchanged</h3>
+        ${result.errors.length > 0 ? `<ul class="rep-changes">${result.errors.map(error =>
+            `<li class="status-error"><strong>Row ${error.row}:</strong> ${error.error}</li>`).join('')}</ul>` : ''}
+        <ul class="rep-changes">${result.inserts.concat(result.updates).map(describe).join('')}</ul>
+    `;
+}
+
 function showError(message) {
     document.getElementById('error-message').innerHTML = `
         <p>${message}</p>
-        <p><small>Make sure USER_ZIP_CODE and OPENSTATES_API_KEY are configured in your .env file.</small></p>
+        <p><small>Make sure your profile has a ZIP code and an OpenStates API key or congress-legislators file is configured.</small></p>
     `;
     document.getElementById('error-display').classList.remove('content-hidden');
 }
@@ -325,8 +613,15 @@ function showNotification(message, type = 'success') {
 document.addEventListener('DOMContentLoaded', function() {
     // Load data when page loads
     loadRepresentatives();
+    loadAddress();
+    loadChanges();
     
     // Set up event listeners
     document.getElementById('sync-btn').addEventListener('click', syncRepresentatives);
     document.getElementById('refresh-btn').addEventListener('click', loadRepresentatives);
+    document.getElementById('save-districts-btn').addEventListener('click', saveDistricts);
+    document.getElementById('save-address-btn').addEventListener('click', saveAddress);
+    document.getElementById('add-rep-btn').addEventListener('click', addRepresentative);
+    document.getElementById('import-preview-btn').addEventListener('click', () => importRepresentatives(true));
+    document.getElementById('import-btn').addEventListener('click', () => importRepresentatives(false));
 }); 
\ No newline at end of file
@@ -191,6 +191,25 @@ function renderMissingComponents(missing, isInitialLoad = false) {
     });
 }
 
+function renderConfigurationIssues(issues) {
+    const container = document.getElementById('configuration-issues');
+    const list = document.getElementById('configuration-issues-list');
+
+    if (!issues || issues.length === 0) {
+        container.classList.add('content-hidden');
+        return;
+    }
+
+    container.classList.remove('content-hidden');
+    list.innerHTML = '';
+
+    issues.forEach(issue => {
+        const li = document.createElement('li');
+        li.textContent = `${issue.key}: ${issue.message}`;
+        list.appendChild(li);
+    });
+}
+
 function showError(message) {
     const loadingIndicator = document.getElementById('loading-indicator');
     loadingIndicator.innerHTML = `
@@ -246,6 +265,7 @@ async function updateStatus(isInitialLoad = false) {
         renderOverallStatus(statusData, statusData.summary, isInitialLoad);
         renderServices(statusData.services, isInitialLoad);
         renderMissingComponents(statusData.missing_components, isInitialLoad);
+        renderConfigurationIssues(statusData.configuration_issues);
 
         // Hide loading on initial load
         if (isInitialLoad) {